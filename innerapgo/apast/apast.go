@@ -7,28 +7,86 @@ package apast
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 )
 
 type Package struct {
+	Name string
+	// Import path of the package, or "main" for the main package.
+	Path string
 	Funcs map[string]*FuncDecl
-	// All methods are attached to their corresponding types.
+	// All methods are attached to their corresponding types. Types are
+	// keyed by qualified name, and include the types of all other packages,
+	// since values can have types from packages that aren't imported
+	// directly.
 	Types map[string]*TypeDecl
+	// The init functions, in the order they should run. These aren't in
+	// Funcs, since there can be more than one and they can't be referenced.
+	InitFuncs []*FuncDecl
+	// Names of the package-level variables.
+	Vars map[string]bool
+	// Assignments that initialize the package-level variables, in the
+	// order they should run, which puts each one after the variables it
+	// depends on.
+	VarInits []Stmt
 }
 
 type TypeDecl struct {
+	// Type parameters for generic types, or nil if the type isn't generic.
+	TypeParams []*TypeParamDecl
+	// Type expression for the underlying type, e.g. float64 for
+	// `type Celsius float64`.
+	Underlying Expr
 	Methods map[string]*MethodDecl
+	// The package that declared the type.
+	Package *Package
+}
+
+// Declared types are referred to by their qualified name, which is the import
+// path of their package followed by the type name, like `example.com/geom.Vec`.
+// Type names can't contain dots, so the qualified name is split at the last
+// one.
+func QualifiedName(path string, name string) string {
+	return path + "." + name
+}
+
+func SplitQualifiedName(qualifiedName string) (path string, name string) {
+	i := strings.LastIndex(qualifiedName, ".")
+	if i < 0 {
+		return "", qualifiedName
+	}
+	return qualifiedName[:i], qualifiedName[i + 1:]
 }
 
 type MethodDecl struct {
 	// Variable name for the receiver param.
 	ReceiverName string
 	IsPointer bool
+	// Names given to the receiver type's type parameters, e.g. T for
+	// `func (s *Stack[T]) Push(v T)`.
+	ReceiverTypeParams []string
 	Func *FuncDecl
 }
 
 type FuncDecl struct {
 	Body       Stmt
 	ParamNames []string
+	// Names of the results, or nil if the results are unnamed.
+	ResultNames []string
+	Type *FuncTypeExpr
+	// Type parameters for generic functions, or nil if the function isn't
+	// generic.
+	TypeParams []*TypeParamDecl
+	// The package that declared the function, whose names the body refers
+	// to.
+	Package *Package
+}
+
+type TypeParamDecl struct {
+	Name string
+	// Type expression for the constraint, which is always an interface.
+	Constraint Expr
 }
 
 type Stmt interface {
@@ -42,6 +100,18 @@ type ExprStmt struct {
 type AssignStmt struct {
 	Lhs []Expr
 	Rhs []Expr
+	// Runtime names of the local variables that the assignment declares,
+	// like x in `x, err := f()` when err is already declared. Each time a
+	// declaration runs, e.g. in a loop, it declares a new variable.
+	Declared []string
+}
+
+// Assignment with an operator, like `x += 2` or `x++`. Unlike an equivalent
+// AssignStmt, the left side is only evaluated once.
+type OpAssignStmt struct {
+	Lhs Expr
+	Op Expr
+	Rhs Expr
 }
 
 type BlockStmt struct {
@@ -76,6 +146,7 @@ type ReturnStmt struct {
 
 func (*ExprStmt) apstmtNode() {}
 func (*AssignStmt) apstmtNode() {}
+func (*OpAssignStmt) apstmtNode() {}
 func (*BlockStmt) apstmtNode() {}
 func (*EmptyStmt) apstmtNode() {}
 func (*IfStmt) apstmtNode() {}
@@ -90,12 +161,28 @@ type Expr interface {
 type FuncCallExpr struct {
 	Func Expr
 	Args []Expr
+	// True if the last argument is a slice passed as variadic arguments,
+	// like `f(nums...)`.
+	HasEllipsis bool
 }
 
 type IdentExpr struct {
 	Name string
 }
 
+// A package-level function or variable, which may be in another package, like
+// `config.Load`.
+type QualifiedIdentExpr struct {
+	Package *Package
+	Name string
+}
+
+// A predeclared function like len or append, which isn't shadowed by any other
+// declaration.
+type BuiltinExpr struct {
+	Name string
+}
+
 type LiteralExpr struct {
 	Val interface{}
 }
@@ -103,6 +190,9 @@ type LiteralExpr struct {
 type IndexExpr struct {
 	E Expr
 	Index Expr
+	// For maps, the zero value of the element type, which is the result for
+	// missing keys. This is nil if the map type isn't known at compile time.
+	ElemZero Expr
 }
 
 type FieldAccessExpr struct {
@@ -110,6 +200,21 @@ type FieldAccessExpr struct {
 	Name string
 }
 
+// A function literal, which can refer to the local variables of the enclosing
+// function. Those are shared with the enclosing function rather than copied,
+// and CapturedVars holds their runtime names.
+type FuncLitExpr struct {
+	Func *FuncDecl
+	CapturedVars []string
+}
+
+// Method expression, like `T.Method` or `(*T).Method`. Type is a type
+// expression.
+type MethodExpr struct {
+	Type Expr
+	Name string
+}
+
 type SliceLiteralExpr struct {
 	Type Expr
 	Vals []Expr
@@ -120,19 +225,189 @@ type ArrayLiteralExpr struct {
 	Vals []Expr
 }
 
+type MapLiteralExpr struct {
+	Type Expr
+	Keys []Expr
+	Vals []Expr
+}
+
 type StructLiteralExpr struct {
+	// Qualified name of the struct type, or "" for anonymous structs.
 	TypeName string
+	// Type arguments if the struct type is generic, like int for
+	// `Pair[int]{1, 2}`.
+	TypeArgs []Expr
+	// The struct type for anonymous structs, since there's no declaration
+	// to look it up in.
+	StructType *StructTypeExpr
 	InitialValues map[string]Expr
 }
 
+// Literal of a native struct type, like `image.Point{X: 1}`. Fields that aren't
+// in InitialValues are left as zero values.
+type NativeStructLiteralExpr struct {
+	Type reflect.Type
+	InitialValues map[string]Expr
+}
+
+// Conversion to a type, like `Celsius(x)`. Type is a type expression.
+type ConversionExpr struct {
+	Type Expr
+	E Expr
+}
+
+// Type assertion, like `x.(Shape)`. Type is a type expression.
+type TypeAssertExpr struct {
+	E Expr
+	Type Expr
+}
+
+// The two-value form of a type assertion or map index, like `v, ok := m[k]`,
+// which also gives whether the assertion succeeded or the key was present.
+type CommaOkExpr struct {
+	E Expr
+}
+
+// Explicit instantiation of a generic function, like `Map[int, string]`. Any
+// type arguments that are left off are inferred when the function is called.
+type InstantiateExpr struct {
+	Func Expr
+	TypeArgs []Expr
+}
+
+// The zero value of a type that's only known at runtime, like `var x T` where
+// T is a type parameter.
+type ZeroValueExpr struct {
+	Type Expr
+}
+
+// An untyped constant used as an operand or argument, like the 2.0 in `n / 2.0`
+// or the 100 in `time.Sleep(100)`. The constant takes on the type of the other
+// operand or the parameter, which may be a named type like Celsius.
+type UntypedConstExpr struct {
+	E Expr
+}
+
+// Pointer to the result of an expression, like `&x` or `&Point{}`.
+type AddressExpr struct {
+	E Expr
+}
+
+// Pointer indirection, like `*p`.
+type DerefExpr struct {
+	E Expr
+}
+
+// Types are represented as expressions, like in go/ast. A type name is an
+// IdentExpr, which has the qualified name for declared types.
+
+type SliceTypeExpr struct {
+	Elem Expr
+}
+
+type ArrayTypeExpr struct {
+	Len int
+	Elem Expr
+}
+
+type StructTypeExpr struct {
+	Fields []*FieldDecl
+}
+
+type FieldDecl struct {
+	// For embedded fields, the name is the name of the type.
+	Name string
+	Type Expr
+	Embedded bool
+	// The struct tag, like `json:"name"`, or "" if there isn't one.
+	Tag string
+}
+
+// Function signature, which is also used as the type expression for func types.
+type FuncTypeExpr struct {
+	ParamTypes []Expr
+	ResultTypes []Expr
+	// If true, the last param type is the slice type holding the variadic
+	// arguments, e.g. []int for `nums ...int`.
+	IsVariadic bool
+}
+
+type PointerTypeExpr struct {
+	Elem Expr
+}
+
+type MapTypeExpr struct {
+	Key Expr
+	Elem Expr
+}
+
+type InterfaceTypeExpr struct {
+	MethodNames []string
+	// Signatures of the methods, in the same order, used by method
+	// expressions like `Shape.Area`.
+	MethodTypes []*FuncTypeExpr
+	// Type expressions for embedded interfaces.
+	Embedded []Expr
+	// Type sets for constraint interfaces, like `~int | ~float64`. A type
+	// satisfies the constraint if it's in every union.
+	Unions []*TypeUnion
+}
+
+type TypeUnion struct {
+	Terms []*TypeTerm
+}
+
+type TypeTerm struct {
+	// True for terms like ~int, which also include all types with that
+	// underlying type.
+	Tilde bool
+	Type Expr
+}
+
+// Generic type with type arguments, like `Stack[int]`.
+type InstantiatedTypeExpr struct {
+	Name string
+	TypeArgs []Expr
+}
+
+// Type that only exists as a native Go type, like time.Duration from a native
+// package. The evaluator also uses these when the type of a value needs to be
+// described, e.g. when inferring type arguments.
+type NativeTypeExpr struct {
+	Type reflect.Type
+}
+
 func (*FuncCallExpr) apexprNode() {}
 func (*IdentExpr) apexprNode() {}
+func (*QualifiedIdentExpr) apexprNode() {}
+func (*BuiltinExpr) apexprNode() {}
 func (*LiteralExpr) apexprNode() {}
 func (*IndexExpr) apexprNode() {}
 func (*FieldAccessExpr) apexprNode() {}
+func (*MethodExpr) apexprNode() {}
+func (*FuncLitExpr) apexprNode() {}
 func (*SliceLiteralExpr) apexprNode() {}
 func (*ArrayLiteralExpr) apexprNode() {}
+func (*MapLiteralExpr) apexprNode() {}
 func (*StructLiteralExpr) apexprNode() {}
+func (*NativeStructLiteralExpr) apexprNode() {}
+func (*ConversionExpr) apexprNode() {}
+func (*TypeAssertExpr) apexprNode() {}
+func (*CommaOkExpr) apexprNode() {}
+func (*InstantiateExpr) apexprNode() {}
+func (*ZeroValueExpr) apexprNode() {}
+func (*UntypedConstExpr) apexprNode() {}
+func (*AddressExpr) apexprNode() {}
+func (*DerefExpr) apexprNode() {}
+func (*SliceTypeExpr) apexprNode() {}
+func (*ArrayTypeExpr) apexprNode() {}
+func (*StructTypeExpr) apexprNode() {}
+func (*FuncTypeExpr) apexprNode() {}
+func (*PointerTypeExpr) apexprNode() {}
+func (*MapTypeExpr) apexprNode() {}
+func (*InterfaceTypeExpr) apexprNode() {}
+func (*InstantiatedTypeExpr) apexprNode() {}
+func (*NativeTypeExpr) apexprNode() {}
 
 func (e *FuncCallExpr) String() string {
 	return fmt.Sprintf("FuncCall{%s,%s}", e.Func, e.Args)
@@ -7,128 +7,880 @@ import (
 	"github.com/alangpierce/apgo/apruntime"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 type CompileCtx struct {
+	// Positions of the files being compiled, for error messages.
+	Fset *token.FileSet
+	// Native packages, by import path.
 	NativePackages map[string]*apruntime.NativePackage
-	ActiveVars map[string]bool
+	// Interpreted packages that have already been compiled, by import path.
+	Packages map[string]*apast.Package
+	// The package being compiled.
+	Package *apast.Package
+	// Packages imported by the file being compiled, by the name they're
+	// imported as.
+	Imports map[string]*importedPackage
+	// Packages imported by the file with `import .`, whose exported names
+	// can be used directly.
+	DotImports []*importedPackage
+	// Local variables in scope, mapped to the names they're stored under at
+	// runtime. Variables that shadow another name get a unique runtime name,
+	// since all locals of a function are stored together.
+	ActiveVars map[string]string
+	// Local variables declared in the innermost scope, which `:=` assigns
+	// to rather than shadowing.
+	ScopeVars map[string]bool
+	// Runtime names used so far in the function being compiled.
+	UsedVarNames map[string]bool
+	// Types of local variables, by runtime name, when they're known at
+	// compile time; see staticType.
+	VarTypes map[string]ast.Expr
+	// Types are keyed by qualified name and include the types of every
+	// package compiled with this context. Type names within the stored
+	// definitions are resolved to qualified names too, so that they mean
+	// the same thing when used from another package.
 	StructDefs map[string]*ast.StructType
+	// Underlying types of all named types that aren't declared directly as
+	// structs, e.g. `float64` for `type Celsius float64`.
+	TypeDefs map[string]ast.Expr
+	// Type parameter names of generic types, e.g. [K, V] for
+	// `type Pair[K, V any] struct {...}`.
+	GenericTypes map[string][]string
+	Types map[string]*apast.TypeDecl
+	// Names of all package-level functions.
+	Funcs map[string]bool
+	// Names of generic functions, which are needed to tell an instantiation
+	// like `Max[int]` apart from an index expression.
+	GenericFuncs map[string]bool
+	// Type parameters that are in scope in the function being compiled.
+	TypeParams map[string]bool
+	// Package-level constants of every package compiled with this context,
+	// keyed by qualified name like types.
+	Consts map[string]*constDecl
+	// Constants declared within the function being compiled, which are
+	// scoped like local variables.
+	LocalConsts map[string]*constValue
+	// The value of iota in the constant declaration being evaluated, or -1
+	// outside of constant declarations.
+	Iota int
+	// Types of package-level variables, and result types of functions and
+	// methods with a single result, when they're known at compile time; see
+	// staticType. These are keyed by qualified name, with the qualified type
+	// name for methods, like `main.Point.Dist`, and have type names resolved.
+	GlobalTypes map[string]ast.Expr
+	ResultTypes map[string]ast.Expr
 }
 
-func CompilePackage(ctx *CompileCtx, pack *ast.Package) *apast.Package {
-	// Compile and populate structs.
-	for _, file := range pack.Files {
+func NewCompileCtx(fset *token.FileSet, nativePackages map[string]*apruntime.NativePackage) *CompileCtx {
+	return &CompileCtx{
+		Fset: fset,
+		NativePackages: nativePackages,
+		Packages: make(map[string]*apast.Package),
+		Imports: make(map[string]*importedPackage),
+		DotImports: []*importedPackage{},
+		ActiveVars: make(map[string]string),
+		ScopeVars: make(map[string]bool),
+		UsedVarNames: make(map[string]bool),
+		VarTypes: make(map[string]ast.Expr),
+		StructDefs: make(map[string]*ast.StructType),
+		TypeDefs: make(map[string]ast.Expr),
+		GenericTypes: make(map[string][]string),
+		Types: make(map[string]*apast.TypeDecl),
+		Funcs: make(map[string]bool),
+		GenericFuncs: make(map[string]bool),
+		TypeParams: make(map[string]bool),
+		Consts: make(map[string]*constDecl),
+		LocalConsts: make(map[string]*constValue),
+		Iota: -1,
+		GlobalTypes: make(map[string]ast.Expr),
+		ResultTypes: make(map[string]ast.Expr),
+	}
+}
+
+// CompileError is a problem with the code being compiled, like an undefined
+// name.
+type CompileError struct {
+	Pos token.Position
+	Msg string
+}
+
+func (err *CompileError) Error() string {
+	return fmt.Sprint(err.Pos, ": ", err.Msg)
+}
+
+// Abort compilation with an error at the given node. CompilePackage recovers
+// from this and returns the error.
+func compileError(ctx *CompileCtx, node ast.Node, msg string) {
+	panic(&CompileError{
+		ctx.Fset.Position(node.Pos()),
+		msg,
+	})
+}
+
+// Compile a package with the given import path. Like in Go, the main package
+// has the path "main" no matter where it's loaded from.
+func CompilePackage(ctx *CompileCtx, pack *ast.Package, path string) (result *apast.Package, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if compileErr, ok := r.(*CompileError); ok {
+				result, err = nil, compileErr
+			} else {
+				// Other panics come from unsupported code, like a
+				// statement that isn't implemented.
+				result, err = nil, fmt.Errorf("%s: %v", path, r)
+			}
+		}
+	}()
+
+	// Files are compiled in order of their names, which determines the
+	// order of the init functions.
+	fileNames := []string{}
+	for fileName := range pack.Files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	files := []*ast.File{}
+	for _, fileName := range fileNames {
+		files = append(files, pack.Files[fileName])
+	}
+
+	// Functions and variables are resolved within each package, so they're
+	// reset for each one.
+	if pack.Name == "main" {
+		path = "main"
+	}
+	ctx.Package = &apast.Package{
+		Name: pack.Name,
+		Path: path,
+		Funcs: make(map[string]*apast.FuncDecl),
+		Types: ctx.Types,
+		InitFuncs: []*apast.FuncDecl{},
+		Vars: make(map[string]bool),
+		VarInits: []apast.Stmt{},
+	}
+	ctx.Funcs = make(map[string]bool)
+	ctx.GenericFuncs = make(map[string]bool)
+
+	// Populate the compile context first, since types and functions can
+	// refer to ones declared later.
+	for _, file := range files {
 		for _, decl := range file.Decls {
-			if decl, ok := decl.(*ast.GenDecl); ok {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok == token.CONST {
+					declareConsts(ctx, file, decl)
+					continue
+				}
 				for _, spec := range decl.Specs {
 					compileGenDecl(ctx, spec)
 				}
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Name.Name != "init" {
+					ctx.Funcs[decl.Name.Name] = true
+				}
+				if decl.Recv == nil && decl.Type.TypeParams != nil {
+					ctx.GenericFuncs[decl.Name.Name] = true
+				}
+			}
+		}
+	}
+
+	// Now that all type names are known, resolve the names within the type
+	// definitions, then compile and populate types.
+	for _, file := range files {
+		compileImports(ctx, file)
+		for _, spec := range getTypeSpecs(file) {
+			compileTypeSpec(ctx, spec)
+		}
+	}
+
+	for _, file := range files {
+		compileImports(ctx, file)
+		for _, decl := range file.Decls {
+			recordStaticTypes(ctx, decl)
+		}
+	}
+
+	// Constants are evaluated at compile time. Ones from other packages
+	// have already been evaluated by the time they can be imported.
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.CONST {
+				evaluateConsts(ctx, genDecl)
 			}
 		}
 	}
 
-	// TODO: This code is slightly weird in that it doesn't populate types
-	// with zero methods. For now that shouldn't matter, but it may be good
-	// to make more consistent at some point.
-	funcs := make(map[string]*apast.FuncDecl)
-	types := make(map[string]*apast.TypeDecl)
-	for _, file := range pack.Files {
+	result = ctx.Package
+	for _, file := range files {
+		compileImports(ctx, file)
 		for _, decl := range file.Decls {
 			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok == token.VAR {
+					ctx.ActiveVars = make(map[string]string)
+					ctx.LocalConsts = make(map[string]*constValue)
+					result.VarInits = append(result.VarInits, compileVarDecl(ctx, decl, false)...)
+				}
 			case *ast.FuncDecl:
-				if decl.Recv == nil {
-					funcs[decl.Name.Name] = compileFuncDecl(ctx, decl)
+				if decl.Recv == nil && decl.Name.Name == "init" {
+					result.InitFuncs = append(result.InitFuncs, compileFuncDecl(ctx, decl))
+				} else if decl.Recv == nil {
+					result.Funcs[decl.Name.Name] = compileFuncDecl(ctx, decl)
 				} else {
 					methodDecl, typeName := compileMethodDecl(ctx, decl)
-					if _, ok := types[typeName]; !ok {
-						types[typeName] = &apast.TypeDecl{
-							make(map[string]*apast.MethodDecl),
-						}
-					}
-					types[typeName].Methods[decl.Name.Name] = methodDecl
+					ctx.Types[typeName].Methods[decl.Name.Name] = methodDecl
 				}
 			}
 		}
 	}
-	return &apast.Package{
-		funcs,
-		types,
+	result.VarInits = sortVarInits(result)
+	return result, nil
+}
+
+// A package imported by the file being compiled, which is either interpreted
+// or native.
+type importedPackage struct {
+	pack *apast.Package
+	nativePackage *apruntime.NativePackage
+}
+
+// Set up the packages imported by a file, which can only be referred to within
+// that file. Blank imports are only for the package's side effects, which
+// happen when the interpreter initializes it, so they aren't recorded.
+func compileImports(ctx *CompileCtx, file *ast.File) {
+	ctx.Imports = make(map[string]*importedPackage)
+	ctx.DotImports = []*importedPackage{}
+	for _, spec := range file.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			panic(err)
+		}
+		imported := &importedPackage{
+			ctx.Packages[path],
+			ctx.NativePackages[path],
+		}
+		var name string
+		if imported.pack != nil {
+			name = imported.pack.Name
+		} else if imported.nativePackage != nil {
+			name = imported.nativePackage.Name
+		} else {
+			compileError(ctx, spec.Path, fmt.Sprint("could not import ", spec.Path.Value, " (no native or interpreted package with that path is registered)"))
+		}
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		switch name {
+		case "_":
+		case ".":
+			ctx.DotImports = append(ctx.DotImports, imported)
+		default:
+			ctx.Imports[name] = imported
+		}
+	}
+}
+
+// Find the dot-imported package that has a function, variable or constant with
+// the given name, if any.
+func findDotImport(ctx *CompileCtx, name string) *importedPackage {
+	if !ast.IsExported(name) {
+		return nil
+	}
+	for _, imported := range ctx.DotImports {
+		pack := imported.pack
+		if pack != nil && (pack.Funcs[name] != nil || pack.Vars[name] || isPackageConst(ctx, pack, name)) {
+			return imported
+		}
+		if imported.nativePackage != nil && hasNativeMember(imported.nativePackage, name) {
+			return imported
+		}
+	}
+	return nil
+}
+
+func hasNativeMember(nativePackage *apruntime.NativePackage, name string) bool {
+	_, isFunc := nativePackage.Funcs[name]
+	_, isConst := nativePackage.Consts[name]
+	_, isVar := nativePackage.Vars[name]
+	return isFunc || isConst || isVar
+}
+
+// Record the resolved definition of a declared type, and compile it.
+func compileTypeSpec(ctx *CompileCtx, spec *ast.TypeSpec) {
+	typeName := apast.QualifiedName(ctx.Package.Path, spec.Name.Name)
+	ctx.TypeParams = make(map[string]bool)
+	if spec.TypeParams != nil {
+		for _, name := range getFieldNames(spec.TypeParams) {
+			ctx.TypeParams[name] = true
+		}
+	}
+	if structType, ok := spec.Type.(*ast.StructType); ok {
+		ctx.StructDefs[typeName] = resolveTypeNames(ctx, structType).(*ast.StructType)
+	} else {
+		ctx.TypeDefs[typeName] = resolveTypeNames(ctx, spec.Type)
+	}
+	ctx.Types[typeName] = &apast.TypeDecl{
+		compileTypeParams(ctx, spec.TypeParams),
+		compileTypeExpr(ctx, spec.Type),
+		make(map[string]*apast.MethodDecl),
+		ctx.Package,
+	}
+}
+
+func getTypeSpecs(file *ast.File) []*ast.TypeSpec {
+	typeSpecs := []*ast.TypeSpec{}
+	for _, decl := range file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
+			for _, spec := range genDecl.Specs {
+				typeSpecs = append(typeSpecs, spec.(*ast.TypeSpec))
+			}
+		}
+	}
+	return typeSpecs
+}
+
+// Record the types of package-level variables and the result types of functions
+// and methods, for staticType. Variables declared without a type get the static
+// type of their value, if it's known.
+func recordStaticTypes(ctx *CompileCtx, decl ast.Decl) {
+	switch decl := decl.(type) {
+	case *ast.GenDecl:
+		if decl.Tok != token.VAR {
+			return
+		}
+		for _, spec := range decl.Specs {
+			spec := spec.(*ast.ValueSpec)
+			for i, name := range spec.Names {
+				varType := spec.Type
+				if varType == nil && len(spec.Values) == len(spec.Names) {
+					varType = staticType(ctx, spec.Values[i])
+				}
+				if varType != nil {
+					ctx.GlobalTypes[apast.QualifiedName(ctx.Package.Path, name.Name)] = resolveTypeNames(ctx, varType)
+				}
+			}
+		}
+	case *ast.FuncDecl:
+		results := decl.Type.Results
+		if results == nil || len(results.List) != 1 || len(results.List[0].Names) > 1 || decl.Type.TypeParams != nil {
+			return
+		}
+		name := apast.QualifiedName(ctx.Package.Path, decl.Name.Name)
+		if decl.Recv != nil {
+			typeName, _, receiverTypeParams := getMethodReceiverType(decl)
+			if len(receiverTypeParams) > 0 {
+				return
+			}
+			name = apast.QualifiedName(apast.QualifiedName(ctx.Package.Path, typeName), decl.Name.Name)
+		}
+		ctx.ResultTypes[name] = resolveTypeNames(ctx, results.List[0].Type)
 	}
 }
 
 // For now, this just populates the compile context with the given declaration,
-// if necessary.
+// if necessary. Constants are handled separately by declareConsts.
 func compileGenDecl(ctx *CompileCtx, spec ast.Spec) {
 	switch spec := spec.(type) {
 	case *ast.TypeSpec:
+		typeName := apast.QualifiedName(ctx.Package.Path, spec.Name.Name)
 		if structType, ok := spec.Type.(*ast.StructType); ok {
-			ctx.StructDefs[spec.Name.Name] = structType
+			ctx.StructDefs[typeName] = structType
+		} else {
+			ctx.TypeDefs[typeName] = spec.Type
+		}
+		if spec.TypeParams != nil {
+			ctx.GenericTypes[typeName] = getFieldNames(spec.TypeParams)
+		}
+	case *ast.ValueSpec:
+		for _, name := range spec.Names {
+			if name.Name != "_" {
+				ctx.Package.Vars[name.Name] = true
+			}
+		}
+	}
+}
+
+func getFieldNames(fieldList *ast.FieldList) []string {
+	names := []string{}
+	for _, field := range fieldList.List {
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
+
+// Compile a type parameter list, or return nil if there isn't one.
+func compileTypeParams(ctx *CompileCtx, fieldList *ast.FieldList) []*apast.TypeParamDecl {
+	if fieldList == nil {
+		return nil
+	}
+	typeParams := []*apast.TypeParamDecl{}
+	for _, field := range fieldList.List {
+		constraint := compileConstraint(ctx, field.Type)
+		for _, name := range field.Names {
+			typeParams = append(typeParams, &apast.TypeParamDecl{
+				name.Name,
+				constraint,
+			})
+		}
+	}
+	return typeParams
+}
+
+// Constraints are interfaces, but can also be written as a type set directly,
+// like `[T ~int | ~float64]`, which is short for an interface with that type
+// set.
+func compileConstraint(ctx *CompileCtx, constraint ast.Expr) apast.Expr {
+	if isInterfaceType(ctx, constraint) {
+		return compileTypeExpr(ctx, constraint)
+	}
+	return &apast.InterfaceTypeExpr{
+		[]string{},
+		[]*apast.FuncTypeExpr{},
+		[]apast.Expr{},
+		[]*apast.TypeUnion{compileTypeUnion(ctx, constraint)},
+	}
+}
+
+func compileTypeUnion(ctx *CompileCtx, expr ast.Expr) *apast.TypeUnion {
+	terms := []*apast.TypeTerm{}
+	for {
+		binaryExpr, ok := expr.(*ast.BinaryExpr)
+		if !ok || binaryExpr.Op != token.OR {
+			break
+		}
+		terms = append(compileTypeUnion(ctx, binaryExpr.Y).Terms, terms...)
+		expr = binaryExpr.X
+	}
+	term := &apast.TypeTerm{}
+	if unaryExpr, ok := expr.(*ast.UnaryExpr); ok && unaryExpr.Op == token.TILDE {
+		term.Tilde = true
+		expr = unaryExpr.X
+	}
+	term.Type = compileTypeExpr(ctx, expr)
+	return &apast.TypeUnion{
+		append([]*apast.TypeTerm{term}, terms...),
+	}
+}
+
+// Turn a var declaration into assignment to the zero value. For example,
+// `var x, y int` becomes `x, y = 0, 0` Initial values are converted to the
+// declared type, if any, so `var x float64 = 1` becomes `x = float64(1)`.
+// Each spec becomes its own assignment, since later specs can refer to earlier
+// ones. Local variables are declared in the current scope, and package-level
+// variables are assigned by name.
+func compileVarDecl(ctx *CompileCtx, decl *ast.GenDecl, isLocal bool) []apast.Stmt {
+	result := []apast.Stmt{}
+	for _, spec := range decl.Specs {
+		switch spec := spec.(type) {
+		case *ast.ValueSpec:
+			varsToInit := []apast.Expr{}
+			zeroTerms := []apast.Expr{}
+			varTypes := make([]ast.Expr, len(spec.Names))
+			for i := range spec.Names {
+				if spec.Type != nil {
+					varTypes[i] = spec.Type
+				} else if len(spec.Values) == len(spec.Names) {
+					varTypes[i] = staticType(ctx, spec.Values[i])
+				}
+			}
+			if len(spec.Values) == 0 {
+				for range spec.Names {
+					zeroTerms = append(zeroTerms, getZeroValueExpr(ctx, spec.Type))
+				}
+			} else if len(spec.Values) != len(spec.Names) {
+				// A call returning multiple values, like
+				// `var a, b = f()`, or a comma-ok expression. The
+				// results already have the declared type.
+				zeroTerms = append(zeroTerms, compileMultiValueExpr(ctx, spec.Values[0], len(spec.Names)))
+			} else {
+				for _, value := range spec.Values {
+					if spec.Type == nil {
+						zeroTerms = append(zeroTerms, compileExpr(ctx, value))
+					} else {
+						zeroTerms = append(zeroTerms, &apast.ConversionExpr{
+							compileTypeExpr(ctx, spec.Type),
+							compileExpr(ctx, value),
+						})
+					}
+				}
+			}
+			var declared []string
+			for i, ident := range spec.Names {
+				if ident.Name == "_" {
+					varsToInit = append(varsToInit, &apast.IdentExpr{
+						"_",
+					})
+				} else if isLocal {
+					runtimeName := declareVar(ctx, ident.Name, varTypes[i])
+					declared = append(declared, runtimeName)
+					varsToInit = append(varsToInit, &apast.IdentExpr{
+						runtimeName,
+					})
+				} else {
+					varsToInit = append(varsToInit, &apast.QualifiedIdentExpr{
+						ctx.Package,
+						ident.Name,
+					})
+				}
+			}
+			result = append(result, &apast.AssignStmt{
+				varsToInit,
+				zeroTerms,
+				declared,
+			})
+		default:
+			panic("Unexpected spec")
+			return nil
 		}
 	}
+	return result
 }
 
 func compileFuncDecl(ctx *CompileCtx, funcDecl *ast.FuncDecl) *apast.FuncDecl {
 	// Clear the list of variables since it might be left over from the
 	// previous function compilation.
-	ctx.ActiveVars = make(map[string]bool)
+	ctx.ActiveVars = make(map[string]string)
+	ctx.ScopeVars = make(map[string]bool)
+	ctx.UsedVarNames = make(map[string]bool)
+	ctx.VarTypes = make(map[string]ast.Expr)
+	ctx.TypeParams = make(map[string]bool)
+	ctx.LocalConsts = make(map[string]*constValue)
 
 	// Populate all initial variables (receiver, args, outputs).
 	if funcDecl.Recv != nil {
-		ctx.ActiveVars[funcDecl.Recv.List[0].Names[0].Name] = true
-	}
-	for _, field := range funcDecl.Type.Params.List {
-		for _, name := range field.Names {
-			ctx.ActiveVars[name.Name] = true
+		recv := funcDecl.Recv.List[0]
+		if len(recv.Names) > 0 {
+			declareVar(ctx, recv.Names[0].Name, recv.Type)
+		}
+		_, _, receiverTypeParams := getMethodReceiverType(funcDecl)
+		for _, name := range receiverTypeParams {
+			ctx.TypeParams[name] = true
 		}
 	}
-	if funcDecl.Type.Results != nil {
-		for _, field := range funcDecl.Type.Results.List {
-			for _, name := range field.Names {
-				ctx.ActiveVars[name.Name] = true
-			}
+	if funcDecl.Type.TypeParams != nil {
+		for _, name := range getFieldNames(funcDecl.Type.TypeParams) {
+			ctx.TypeParams[name] = true
 		}
 	}
+	return compileFuncBody(ctx, funcDecl.Type, funcDecl.Body, compileTypeParams(ctx, funcDecl.Type.TypeParams))
+}
 
+// Compile the signature and body of a function or function literal, declaring
+// the parameters and results in the current scope.
+func compileFuncBody(ctx *CompileCtx, funcType *ast.FuncType, funcBody *ast.BlockStmt,
+		typeParams []*apast.TypeParamDecl) *apast.FuncDecl {
 	paramNames := []string{}
-	for _, param := range funcDecl.Type.Params.List {
+	for _, param := range funcType.Params.List {
 		if param.Names == nil {
 			paramNames = append(paramNames, "_")
-		} else if len(param.Names) == 1 {
-			paramNames = append(paramNames, param.Names[0].Name)
-		} else {
-			panic("Unexpected number of parameter names.")
 		}
+		paramType := param.Type
+		if ellipsis, ok := paramType.(*ast.Ellipsis); ok {
+			paramType = &ast.ArrayType{
+				Elt: ellipsis.Elt,
+			}
+		}
+		for _, name := range param.Names {
+			paramNames = append(paramNames, declareVar(ctx, name.Name, paramType))
+		}
+	}
+	if funcType.Results != nil {
+		for _, field := range funcType.Results.List {
+			for _, name := range field.Names {
+				declareVar(ctx, name.Name, field.Type)
+			}
+		}
+	}
+	compiledType := compileFuncType(ctx, funcType)
+	funcVars := ctx.ActiveVars
+	body := CompileStmt(ctx, funcBody).(*apast.BlockStmt)
+
+	// Named results start out as zero values.
+	var resultNames []string
+	if funcType.Results != nil && len(funcType.Results.List[0].Names) > 0 {
+		resultNames = []string{}
+		zeroValues := []apast.Expr{}
+		resultVars := []apast.Expr{}
+		for _, field := range funcType.Results.List {
+			for _, name := range field.Names {
+				resultNames = append(resultNames, funcVars[name.Name])
+				resultVars = append(resultVars, &apast.IdentExpr{
+					funcVars[name.Name],
+				})
+				zeroValues = append(zeroValues, getZeroValueExpr(ctx, field.Type))
+			}
+		}
+		body.Stmts = append([]apast.Stmt{
+			&apast.AssignStmt{
+				resultVars,
+				zeroValues,
+				resultNames,
+			},
+		}, body.Stmts...)
 	}
 	return &apast.FuncDecl{
-		CompileStmt(ctx, funcDecl.Body),
+		body,
 		paramNames,
+		resultNames,
+		compiledType,
+		typeParams,
+		ctx.Package,
+	}
+}
+
+// Compile a function literal. Its body is compiled in a scope within the
+// enclosing function, so the variables it declares get runtime names that don't
+// conflict with the enclosing function's variables, which it can refer to.
+func compileFuncLit(ctx *CompileCtx, funcLit *ast.FuncLit) apast.Expr {
+	capturedVars := []string{}
+	for _, runtimeName := range ctx.ActiveVars {
+		capturedVars = append(capturedVars, runtimeName)
+	}
+	sort.Strings(capturedVars)
+	outer := enterScope(ctx)
+	defer exitScope(ctx, outer)
+	return &apast.FuncLitExpr{
+		compileFuncBody(ctx, funcLit.Type, funcLit.Body, nil),
+		capturedVars,
+	}
+}
+
+func compileFuncType(ctx *CompileCtx, funcType *ast.FuncType) *apast.FuncTypeExpr {
+	paramTypes := []apast.Expr{}
+	isVariadic := false
+	for _, param := range funcType.Params.List {
+		paramType := param.Type
+		if ellipsis, ok := paramType.(*ast.Ellipsis); ok {
+			// Within the function, variadic params are slices.
+			paramType = &ast.ArrayType{
+				Elt: ellipsis.Elt,
+			}
+			isVariadic = true
+		}
+		compiledType := compileTypeExpr(ctx, paramType)
+		paramTypes = append(paramTypes, compiledType)
+		for i := 1; i < len(param.Names); i++ {
+			paramTypes = append(paramTypes, compiledType)
+		}
+	}
+	resultTypes := []apast.Expr{}
+	if funcType.Results != nil {
+		for _, result := range funcType.Results.List {
+			compiledType := compileTypeExpr(ctx, result.Type)
+			resultTypes = append(resultTypes, compiledType)
+			for i := 1; i < len(result.Names); i++ {
+				resultTypes = append(resultTypes, compiledType)
+			}
+		}
+	}
+	return &apast.FuncTypeExpr{
+		paramTypes,
+		resultTypes,
+		isVariadic,
 	}
 }
 
 func compileMethodDecl(ctx *CompileCtx, methodDecl *ast.FuncDecl) (method *apast.MethodDecl, typeName string) {
-	typeName, isPointer := getMethodReceiverType(methodDecl)
+	typeName, isPointer, typeParams := getMethodReceiverType(methodDecl)
+	funcDecl := compileFuncDecl(ctx, methodDecl)
+	// The receiver may be unnamed, like in `func (Point) Name() string`.
+	// Otherwise, it's in the function's scope, which is still active.
+	receiverName := "_"
+	if recv := methodDecl.Recv.List[0]; len(recv.Names) > 0 {
+		receiverName = ctx.ActiveVars[recv.Names[0].Name]
+	}
 	return &apast.MethodDecl{
-		ReceiverName: methodDecl.Recv.List[0].Names[0].Name,
+		ReceiverName: receiverName,
 		IsPointer: isPointer,
-		Func: compileFuncDecl(ctx, methodDecl),
-	}, typeName
+		ReceiverTypeParams: typeParams,
+		Func: funcDecl,
+	}, apast.QualifiedName(ctx.Package.Path, typeName)
 }
 
 // Get information about the receiver type. Receiver types can only be either
-// a named type or a pointer to a named type.
-func getMethodReceiverType(funcDecl *ast.FuncDecl) (typeName string, isPointer bool) {
-	field := funcDecl.Recv.List[0]
-	if fieldType, ok := field.Type.(*ast.Ident); ok {
-		return fieldType.Name, false
+// a named type or a pointer to a named type. For generic types, the receiver
+// also declares names for the type parameters, like `func (s *Stack[T])`.
+func getMethodReceiverType(funcDecl *ast.FuncDecl) (
+		typeName string, isPointer bool, typeParams []string) {
+	receiverType := funcDecl.Recv.List[0].Type
+	if starExpr, ok := receiverType.(*ast.StarExpr); ok {
+		receiverType = starExpr.X
+		isPointer = true
+	}
+	receiverName, typeArgs := splitTypeInstance(receiverType)
+	typeIdent, ok := receiverName.(*ast.Ident)
+	if !ok {
+		panic("Unexpected receiver type.")
+	}
+	for _, typeArg := range typeArgs {
+		typeParams = append(typeParams, typeArg.(*ast.Ident).Name)
+	}
+	return typeIdent.Name, isPointer, typeParams
+}
+
+// Split a possibly-instantiated type name like `Pair[K, V]` or `geom.Pair[K, V]`
+// into the name and the type arguments. Returns a nil name if the expression
+// isn't of that form.
+func splitTypeInstance(expr ast.Expr) (ast.Expr, []ast.Expr) {
+	switch expr := expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		return expr, nil
+	case *ast.IndexExpr:
+		if isTypeName(expr.X) {
+			return expr.X, []ast.Expr{expr.Index}
+		}
+	case *ast.IndexListExpr:
+		if isTypeName(expr.X) {
+			return expr.X, expr.Indices
+		}
+	}
+	return nil, nil
+}
+
+func isTypeName(expr ast.Expr) bool {
+	switch expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the qualified name of the declared type that a type name refers to,
+// if any. Names within stored type definitions have already been resolved, so
+// they're qualified names themselves.
+func resolveTypeName(ctx *CompileCtx, typeName ast.Expr) (string, bool) {
+	var qualifiedName string
+	switch typeName := typeName.(type) {
+	case *ast.Ident:
+		if strings.Contains(typeName.Name, ".") {
+			_, _, isNative := lookupNativeType(ctx, typeName)
+			return typeName.Name, !isNative
+		}
+		if ctx.TypeParams[typeName.Name] {
+			return "", false
+		}
+		qualifiedName = apast.QualifiedName(ctx.Package.Path, typeName.Name)
+		if !isDeclaredType(ctx, qualifiedName) && ast.IsExported(typeName.Name) {
+			// The type may be from a package imported with
+			// `import .`.
+			for _, imported := range ctx.DotImports {
+				if imported.pack == nil {
+					continue
+				}
+				dotName := apast.QualifiedName(imported.pack.Path, typeName.Name)
+				if isDeclaredType(ctx, dotName) {
+					return dotName, true
+				}
+			}
+		}
+	case *ast.SelectorExpr:
+		pack, ok := getPackage(ctx, typeName.X)
+		if !ok || !ast.IsExported(typeName.Sel.Name) {
+			return "", false
+		}
+		qualifiedName = apast.QualifiedName(pack.Path, typeName.Sel.Name)
+	default:
+		return "", false
 	}
-	if fieldType, ok := field.Type.(*ast.StarExpr); ok {
-		if underlyingType, ok := fieldType.X.(*ast.Ident); ok {
-			return underlyingType.Name, true
+	return qualifiedName, isDeclaredType(ctx, qualifiedName)
+}
+
+func isDeclaredType(ctx *CompileCtx, qualifiedName string) bool {
+	_, isStruct := ctx.StructDefs[qualifiedName]
+	_, isNamedType := ctx.TypeDefs[qualifiedName]
+	return isStruct || isNamedType
+}
+
+// Returns the native package and name of the native type that a type name
+// refers to, if any, like time.Duration. Like declared types, native types
+// within stored type definitions are referred to by qualified name.
+func lookupNativeType(ctx *CompileCtx, typeName ast.Expr) (*apruntime.NativePackage, string, bool) {
+	var candidates []*importedPackage
+	var name string
+	switch typeName := typeName.(type) {
+	case *ast.Ident:
+		if strings.Contains(typeName.Name, ".") {
+			path, name := apast.SplitQualifiedName(typeName.Name)
+			nativePackage, ok := ctx.NativePackages[path]
+			if !ok || nativePackage.Types[name] == nil {
+				return nil, "", false
+			}
+			return nativePackage, name, true
+		}
+		if ctx.TypeParams[typeName.Name] || !ast.IsExported(typeName.Name) ||
+				isDeclaredType(ctx, apast.QualifiedName(ctx.Package.Path, typeName.Name)) {
+			return nil, "", false
+		}
+		// The type may be from a package imported with `import .`.
+		candidates = ctx.DotImports
+		name = typeName.Name
+	case *ast.SelectorExpr:
+		ident, ok := typeName.X.(*ast.Ident)
+		if !ok || !refersToPackage(ctx, ident) || ctx.Imports[ident.Name] == nil {
+			return nil, "", false
+		}
+		candidates = []*importedPackage{ctx.Imports[ident.Name]}
+		name = typeName.Sel.Name
+	default:
+		return nil, "", false
+	}
+	for _, imported := range candidates {
+		if imported.nativePackage != nil && ast.IsExported(name) && imported.nativePackage.Types[name] != nil {
+			return imported.nativePackage, name, true
 		}
 	}
-	panic("Unexpected receiver type.")
+	return nil, "", false
+}
+
+// Returns the native type that a type name refers to, if any.
+func resolveNativeType(ctx *CompileCtx, typeName ast.Expr) (reflect.Type, bool) {
+	nativePackage, name, ok := lookupNativeType(ctx, typeName)
+	if !ok {
+		return nil, false
+	}
+	return nativePackage.Types[name], true
+}
+
+// Report a type from another package that can't be referred to, since it's
+// either unexported or doesn't exist.
+func packageTypeError(ctx *CompileCtx, typeName *ast.SelectorExpr) {
+	ident, _ := typeName.X.(*ast.Ident)
+	if ident != nil && refersToPackage(ctx, ident) && ctx.Imports[ident.Name] != nil && !ast.IsExported(typeName.Sel.Name) {
+		compileError(ctx, typeName.Sel, fmt.Sprint("name ", typeName.Sel.Name, " not exported by package ", typeName.X))
+	}
+	compileError(ctx, typeName, fmt.Sprint("undefined: ", typeName.X, ".", typeName.Sel.Name))
+}
+
+// Replace the names of declared types in a type expression with their
+// qualified names. Constants in array lengths are replaced with their values,
+// which can then be used from any package.
+func resolveTypeNames(ctx *CompileCtx, t ast.Expr) ast.Expr {
+	return rewriteTypeNames(t, func(typeName ast.Expr) ast.Expr {
+		if qualifiedName, ok := resolveTypeName(ctx, typeName); ok {
+			return &ast.Ident{
+				NamePos: typeName.Pos(),
+				Name: qualifiedName,
+			}
+		}
+		if nativePackage, name, ok := lookupNativeType(ctx, typeName); ok {
+			return &ast.Ident{
+				NamePos: typeName.Pos(),
+				Name: apast.QualifiedName(nativePackage.Path, name),
+			}
+		}
+		if c, ok := evalConst(ctx, typeName); ok {
+			return constLiteral(c)
+		}
+		return typeName
+	})
 }
 
 func CompileStmt(ctx *CompileCtx, stmt ast.Stmt) apast.Stmt {
@@ -138,30 +890,12 @@ func CompileStmt(ctx *CompileCtx, stmt ast.Stmt) apast.Stmt {
 	case *ast.DeclStmt:
 		switch decl := stmt.Decl.(type) {
 		case *ast.GenDecl:
-			// Turn a declaration into assignment to the zero value.
-			// For example, `var x, y int` becomes `x, y = 0, 0`
-			varsToInit := []apast.Expr{}
-			zeroTerms := []apast.Expr{}
-			for _, spec := range decl.Specs {
-				switch spec := spec.(type) {
-				case *ast.ValueSpec:
-					zeroValueExpr := getZeroValueExpr(ctx, spec.Type)
-					for _, ident := range spec.Names {
-						ctx.ActiveVars[ident.Name] = true
-						varsToInit = append(varsToInit, &apast.IdentExpr{
-							ident.Name,
-						})
-						zeroTerms = append(zeroTerms, zeroValueExpr)
-					}
-				default:
-					panic("Unexpected spec")
-					return nil
-				}
+			if decl.Tok == token.CONST {
+				declareLocalConsts(ctx, decl)
+				return &apast.EmptyStmt{}
 			}
-
-			return &apast.AssignStmt{
-				varsToInit,
-				zeroTerms,
+			return &apast.BlockStmt{
+				compileVarDecl(ctx, decl, true),
 			}
 		default:
 			panic("Unexpected declaration")
@@ -178,67 +912,72 @@ func CompileStmt(ctx *CompileCtx, stmt ast.Stmt) apast.Stmt {
 	//case *ast.SendStmt:
 	//	return nil
 	case *ast.IncDecStmt:
-		compiledLhs := compileExpr(ctx, stmt.X)
-		// TODO: This compiles into an expression that evaluates the
-		// left side twice.
-		return &apast.AssignStmt{
-			[]apast.Expr{compiledLhs},
-			[]apast.Expr{
-				&apast.FuncCallExpr{
-					&apast.LiteralExpr{
-						apruntime.IncDecOperators[stmt.Tok],
-					},
-					[]apast.Expr{
-						compiledLhs,
-						&apast.LiteralExpr{1},
-					},
-				},
+		return &apast.OpAssignStmt{
+			compileExpr(ctx, stmt.X),
+			&apast.LiteralExpr{
+				apruntime.IncDecOperators[stmt.Tok],
+			},
+			&apast.UntypedConstExpr{
+				&apast.LiteralExpr{1},
 			},
 		}
 	case *ast.AssignStmt:
 		if stmt.Tok == token.DEFINE || stmt.Tok == token.ASSIGN {
 			lhs := []apast.Expr{}
 			rhs := []apast.Expr{}
-			for _, lhsExpr := range stmt.Lhs {
-				// Note that this is a bit overkill because
-				// we're counting assignments or definitions as
-				// creating variables, but that should still be
-				// correct.
-				if lhsExpr, ok := lhsExpr.(*ast.Ident); ok {
-					ctx.ActiveVars[lhsExpr.Name] = true
+			// The right side is compiled first, since it can refer to
+			// variables that the left side shadows, like in
+			// `x := x + 1`.
+			varTypes := make([]ast.Expr, len(stmt.Lhs))
+			for i, rhsExpr := range stmt.Rhs {
+				if len(stmt.Lhs) == len(stmt.Rhs) {
+					varTypes[i] = staticType(ctx, rhsExpr)
+				}
+				if stmt.Tok == token.ASSIGN && len(stmt.Lhs) == len(stmt.Rhs) {
+					rhs = append(rhs, compileAssignedValue(ctx, rhsExpr, staticType(ctx, stmt.Lhs[i])))
+				} else {
+					rhs = append(rhs, compileMultiValueExpr(ctx, rhsExpr, len(stmt.Lhs) - len(stmt.Rhs) + 1))
 				}
-				lhs = append(lhs, compileExpr(ctx, lhsExpr))
 			}
-			for _, rhsExpr := range stmt.Rhs {
-				rhs = append(rhs, compileExpr(ctx, rhsExpr))
+			var declared []string
+			for i, lhsExpr := range stmt.Lhs {
+				if ident, ok := lhsExpr.(*ast.Ident); ok {
+					if ident.Name == "_" {
+						lhs = append(lhs, &apast.IdentExpr{"_"})
+						continue
+					}
+					if stmt.Tok == token.DEFINE {
+						isNew := !ctx.ScopeVars[ident.Name]
+						runtimeName := declareVar(ctx, ident.Name, varTypes[i])
+						if isNew {
+							declared = append(declared, runtimeName)
+						}
+						lhs = append(lhs, &apast.IdentExpr{
+							runtimeName,
+						})
+						continue
+					}
+				}
+				lhs = append(lhs, compileExpr(ctx, lhsExpr))
 			}
 			return &apast.AssignStmt{
 				lhs,
 				rhs,
+				declared,
 			}
 		} else {
 			if len(stmt.Lhs) != 1 || len(stmt.Rhs) != 1 {
 				panic("Unexpected multiple assign")
 			}
-			// TODO: We should only evaluate the left side once,
-			// e.g. array index values.
-			compiledLhs := compileExpr(ctx, stmt.Lhs[0])
 			if _, ok := apruntime.AssignBinaryOperators[stmt.Tok]; !ok {
 				panic(fmt.Sprint("Operator not implemented: ", stmt.Tok))
 			}
-			return &apast.AssignStmt{
-				[]apast.Expr{compiledLhs},
-				[]apast.Expr{
-					&apast.FuncCallExpr{
-						&apast.LiteralExpr{
-							apruntime.AssignBinaryOperators[stmt.Tok],
-						},
-						[]apast.Expr{
-							compiledLhs,
-							compileExpr(ctx, stmt.Rhs[0]),
-						},
-					},
+			return &apast.OpAssignStmt{
+				compileExpr(ctx, stmt.Lhs[0]),
+				&apast.LiteralExpr{
+					apruntime.AssignBinaryOperators[stmt.Tok],
 				},
+				compileOperand(ctx, stmt.Rhs[0], stmt.Lhs[0]),
 			}
 		}
 	//case *ast.GoStmt:
@@ -262,14 +1001,20 @@ func CompileStmt(ctx *CompileCtx, stmt ast.Stmt) apast.Stmt {
 			return nil
 		}
 	case *ast.BlockStmt:
+		outer := enterScope(ctx)
 		stmts := []apast.Stmt{}
 		for _, subStmt := range stmt.List {
 			stmts = append(stmts, CompileStmt(ctx, subStmt))
 		}
+		exitScope(ctx, outer)
 		return &apast.BlockStmt{
 			stmts,
 		}
 	case *ast.IfStmt:
+		// Variables declared in the init statement are scoped to the
+		// if statement.
+		outer := enterScope(ctx)
+		defer exitScope(ctx, outer)
 		var result apast.IfStmt
 		if stmt.Init != nil {
 			result.Init = CompileStmt(ctx, stmt.Init)
@@ -295,6 +1040,8 @@ func CompileStmt(ctx *CompileCtx, stmt ast.Stmt) apast.Stmt {
 	//case *ast.SelectStmt:
 	//	return nil
 	case *ast.ForStmt:
+		outer := enterScope(ctx)
+		defer exitScope(ctx, outer)
 		var result apast.ForStmt
 		if stmt.Init != nil {
 			result.Init = CompileStmt(ctx, stmt.Init)
@@ -321,68 +1068,359 @@ func CompileStmt(ctx *CompileCtx, stmt ast.Stmt) apast.Stmt {
 	}
 }
 
-func compileExpr(ctx *CompileCtx, expr ast.Expr) apast.Expr {
-	switch expr := expr.(type) {
-	//case *ast.BadExpr:
-	//	return nil
-	case *ast.Ident:
-		return &apast.IdentExpr{
-			expr.Name,
-		}
-	//case *ast.Ellipsis:
-	//	return nil
-	case *ast.BasicLit:
-		return &apast.LiteralExpr{
-			parseLiteral(expr.Value, expr.Kind),
+// Compile an expression that gives the given number of values. Type assertions
+// and map indexes give two values in the comma-ok form, like `v, ok := m[k]`.
+func compileMultiValueExpr(ctx *CompileCtx, expr ast.Expr, numValues int) apast.Expr {
+	compiled := compileExpr(ctx, expr)
+	if numValues != 2 {
+		return compiled
+	}
+	switch compiled.(type) {
+	case *apast.TypeAssertExpr, *apast.IndexExpr:
+		return &apast.CommaOkExpr{
+			compiled,
 		}
-	//case *ast.FuncLit:
-	//	return nil
-	case *ast.CompositeLit:
-		return compileCompositeLit(ctx, expr)
-	//case *ast.ParenExpr:
-	//	return nil
-	case *ast.SelectorExpr:
-		if leftSide, ok := expr.X.(*ast.Ident); ok {
-			if _, ok := ctx.ActiveVars[leftSide.Name]; ok {
-				return &apast.FieldAccessExpr{
-					compileExpr(ctx, leftSide),
-					expr.Sel.Name,
-				}
-			} else {
-				return compilePackageFunc(ctx, leftSide, expr.Sel)
+	default:
+		return compiled
+	}
+}
+
+// Check if an expression is an untyped constant, like `5`, `-1.5` or math.Pi.
+func isUntypedConst(ctx *CompileCtx, expr ast.Expr) bool {
+	c, ok := evalConst(ctx, expr)
+	return ok && c.typ == nil
+}
+
+// Start a scope for variables declared in a block. This returns the variables
+// of the enclosing scope, which should be restored at the end of the block.
+func enterScope(ctx *CompileCtx) *scope {
+	outer := &scope{
+		ctx.ActiveVars,
+		ctx.ScopeVars,
+		ctx.LocalConsts,
+	}
+	ctx.ActiveVars = make(map[string]string)
+	for name, runtimeName := range outer.vars {
+		ctx.ActiveVars[name] = runtimeName
+	}
+	ctx.ScopeVars = make(map[string]bool)
+	ctx.LocalConsts = make(map[string]*constValue)
+	for name, c := range outer.consts {
+		ctx.LocalConsts[name] = c
+	}
+	return outer
+}
+
+// The variables and constants of an enclosing scope, saved by enterScope.
+type scope struct {
+	vars map[string]string
+	declared map[string]bool
+	consts map[string]*constValue
+}
+
+func exitScope(ctx *CompileCtx, outer *scope) {
+	ctx.ActiveVars = outer.vars
+	ctx.ScopeVars = outer.declared
+	ctx.LocalConsts = outer.consts
+}
+
+// Declare a local variable in the current scope, returning the name it's
+// stored under at runtime. The type may be nil if it isn't known. Redeclaring a
+// variable from the same scope, like in `a, err := f()`, reuses the variable.
+func declareVar(ctx *CompileCtx, name string, varType ast.Expr) string {
+	if ctx.ScopeVars[name] {
+		return ctx.ActiveVars[name]
+	}
+	// Names that could mean something else at runtime, like a shadowed
+	// variable or a package-level function, get a unique suffix that
+	// can't appear in Go identifiers.
+	runtimeName := name
+	for i := 1; ctx.UsedVarNames[runtimeName] || ctx.Funcs[runtimeName]; i++ {
+		runtimeName = fmt.Sprint(name, "#", i)
+	}
+	ctx.ActiveVars[name] = runtimeName
+	ctx.ScopeVars[name] = true
+	ctx.UsedVarNames[runtimeName] = true
+	delete(ctx.LocalConsts, name)
+	if varType != nil {
+		ctx.VarTypes[runtimeName] = varType
+	}
+	return runtimeName
+}
+
+// Get the type of an expression if it's easy to tell at compile time, or nil
+// otherwise. We don't type check the code, so this only handles common cases
+// like variables declared with a type, composite literals and field accesses.
+func staticType(ctx *CompileCtx, expr ast.Expr) ast.Expr {
+	if c, ok := evalConst(ctx, expr); ok {
+		return c.typ
+	}
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if runtimeName, ok := ctx.ActiveVars[expr.Name]; ok {
+			return ctx.VarTypes[runtimeName]
+		} else if ctx.Package.Vars[expr.Name] {
+			return ctx.GlobalTypes[apast.QualifiedName(ctx.Package.Path, expr.Name)]
+		}
+	case *ast.ParenExpr:
+		return staticType(ctx, expr.X)
+	case *ast.CompositeLit:
+		return expr.Type
+	case *ast.CallExpr:
+		if resultType, ok := ctx.ResultTypes[getFuncName(ctx, expr.Fun)]; ok {
+			return resultType
+		} else if isTypeExpr(ctx, expr.Fun) {
+			return expr.Fun
+		} else if isTypeArgBuiltin(ctx, expr.Fun) && expr.Fun.(*ast.Ident).Name == "new" {
+			return &ast.StarExpr{
+				X: expr.Args[0],
+			}
+		} else if isTypeArgBuiltin(ctx, expr.Fun) {
+			return expr.Args[0]
+		}
+	case *ast.UnaryExpr:
+		if elem := staticType(ctx, expr.X); elem != nil && expr.Op == token.AND {
+			return &ast.StarExpr{
+				X: elem,
 			}
-		} else {
-			panic(fmt.Sprint("Selector not found ", expr))
+		}
+	case *ast.StarExpr:
+		if pointer, ok := underlyingType(ctx, staticType(ctx, expr.X)).(*ast.StarExpr); ok {
+			return pointer.X
+		}
+	case *ast.SelectorExpr:
+		if pack, ok := getPackage(ctx, expr.X); ok {
+			return ctx.GlobalTypes[apast.QualifiedName(pack.Path, expr.Sel.Name)]
+		}
+		structType := underlyingType(ctx, staticType(ctx, expr.X))
+		if pointer, ok := structType.(*ast.StarExpr); ok {
+			structType = underlyingType(ctx, pointer.X)
+		}
+		if structType, ok := structType.(*ast.StructType); ok {
+			return getFieldType(structType, expr.Sel.Name)
 		}
 	case *ast.IndexExpr:
+		containerType := underlyingType(ctx, staticType(ctx, expr.X))
+		if pointer, ok := containerType.(*ast.StarExpr); ok {
+			containerType = underlyingType(ctx, pointer.X)
+		}
+		switch containerType := containerType.(type) {
+		case *ast.MapType:
+			return containerType.Value
+		case *ast.ArrayType:
+			return containerType.Elt
+		}
+	}
+	return nil
+}
+
+// Get the qualified name of the interpreted function or method that an
+// expression refers to, like `main.f` or `main.Point.Dist` for `p.Dist`, or ""
+// if it isn't known at compile time.
+func getFuncName(ctx *CompileCtx, expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if _, isLocal := ctx.ActiveVars[expr.Name]; !isLocal && ctx.Funcs[expr.Name] {
+			return apast.QualifiedName(ctx.Package.Path, expr.Name)
+		}
+	case *ast.SelectorExpr:
+		if pack, ok := getPackage(ctx, expr.X); ok {
+			return apast.QualifiedName(pack.Path, expr.Sel.Name)
+		}
+		receiverType := staticType(ctx, expr.X)
+		if pointer, ok := receiverType.(*ast.StarExpr); ok {
+			receiverType = pointer.X
+		}
+		if typeName, ok := resolveTypeName(ctx, receiverType); ok {
+			return apast.QualifiedName(typeName, expr.Sel.Name)
+		}
+	}
+	return ""
+}
+
+// Get the type of a struct's field, or nil if there's no such field. Promoted
+// fields aren't included.
+func getFieldType(structDef *ast.StructType, name string) ast.Expr {
+	for _, field := range structDef.Fields.List {
+		for _, fieldName := range field.Names {
+			if fieldName.Name == name {
+				return field.Type
+			}
+		}
+		if field.Names == nil && getEmbeddedFieldName(field.Type) == name {
+			return field.Type
+		}
+	}
+	return nil
+}
+
+// Compile a value assigned to a location with the given static type, which is
+// nil if it isn't known. Untyped constants are converted to the type, so the 5
+// in `temps[1] = 5` is a Celsius if temps is a []Celsius, except that constants
+// assigned to interfaces keep their default type.
+func compileAssignedValue(ctx *CompileCtx, expr ast.Expr, t ast.Expr) apast.Expr {
+	compiled := compileExpr(ctx, expr)
+	if t == nil || !isUntypedConst(ctx, expr) || isInterfaceType(ctx, t) {
+		return convertNilPointer(ctx, compiled, t)
+	}
+	return &apast.ConversionExpr{
+		compileTypeExpr(ctx, t),
+		compiled,
+	}
+}
+
+// Convert nil to the static type of the location it's assigned to, if that's a
+// pointer type, so that it keeps the type it points to; see PointerValue.
+func convertNilPointer(ctx *CompileCtx, compiled apast.Expr, t ast.Expr) apast.Expr {
+	literal, ok := compiled.(*apast.LiteralExpr)
+	if !ok || literal.Val != nil || t == nil {
+		return compiled
+	}
+	if _, ok := underlyingType(ctx, t).(*ast.StarExpr); !ok {
+		return compiled
+	}
+	return &apast.ConversionExpr{
+		compileTypeExpr(ctx, t),
+		compiled,
+	}
+}
+
+// Follow named types to the types they're declared with, e.g. to
+// `map[string]int` for `type Counts map[string]int`.
+func underlyingType(ctx *CompileCtx, t ast.Expr) ast.Expr {
+	if paren, ok := t.(*ast.ParenExpr); ok {
+		return underlyingType(ctx, paren.X)
+	}
+	if t == nil || isTypeParam(ctx, t) {
+		return t
+	}
+	if typeDef, ok := getTypeDef(ctx, t); ok {
+		return underlyingType(ctx, typeDef)
+	}
+	return t
+}
+
+func compileExpr(ctx *CompileCtx, expr ast.Expr) apast.Expr {
+	if c, ok := evalConst(ctx, expr); ok {
+		return compileConst(ctx, c)
+	}
+	switch expr := expr.(type) {
+	//case *ast.BadExpr:
+	//	return nil
+	case *ast.Ident:
+		return compileIdent(ctx, expr)
+	//case *ast.Ellipsis:
+	//	return nil
+	case *ast.FuncLit:
+		return compileFuncLit(ctx, expr)
+	case *ast.CompositeLit:
+		return compileCompositeLit(ctx, expr)
+	case *ast.ParenExpr:
+		return compileExpr(ctx, expr.X)
+	case *ast.SelectorExpr:
+		if isTypeExpr(ctx, expr.X) {
+			return &apast.MethodExpr{
+				compileTypeExpr(ctx, expr.X),
+				expr.Sel.Name,
+			}
+		}
+		if leftSide, ok := expr.X.(*ast.Ident); ok && refersToPackage(ctx, leftSide) {
+			return compilePackageMember(ctx, leftSide, expr.Sel)
+		}
+		return &apast.FieldAccessExpr{
+			compileExpr(ctx, expr.X),
+			expr.Sel.Name,
+		}
+	case *ast.IndexExpr:
+		if isGenericFunc(ctx, expr.X) {
+			return &apast.InstantiateExpr{
+				compileExpr(ctx, expr.X),
+				[]apast.Expr{compileTypeExpr(ctx, expr.Index)},
+			}
+		}
+		var elemZero apast.Expr
+		if mapType, ok := underlyingType(ctx, staticType(ctx, expr.X)).(*ast.MapType); ok {
+			elemZero = getZeroValueExpr(ctx, mapType.Value)
+		}
 		return &apast.IndexExpr{
 			compileExpr(ctx, expr.X),
 			compileExpr(ctx, expr.Index),
+			elemZero,
+		}
+	case *ast.IndexListExpr:
+		// Multiple indices can only be type arguments.
+		typeArgs := []apast.Expr{}
+		for _, index := range expr.Indices {
+			typeArgs = append(typeArgs, compileTypeExpr(ctx, index))
+		}
+		return &apast.InstantiateExpr{
+			compileExpr(ctx, expr.X),
+			typeArgs,
 		}
 	//case *ast.SliceExpr:
 	//	return nil
-	//case *ast.TypeAssertExpr:
-	//	return nil
+	case *ast.TypeAssertExpr:
+		return &apast.TypeAssertExpr{
+			compileExpr(ctx, expr.X),
+			compileTypeExpr(ctx, expr.Type),
+		}
 	case *ast.CallExpr:
+		if isTypeExpr(ctx, expr.Fun) {
+			if len(expr.Args) != 1 {
+				panic("Expected exactly one argument in conversion.")
+			}
+			return &apast.ConversionExpr{
+				compileTypeExpr(ctx, expr.Fun),
+				compileExpr(ctx, expr.Args[0]),
+			}
+		}
 		compiledArgs := []apast.Expr{}
-		for _, arg := range expr.Args {
-			compiledArgs = append(compiledArgs, compileExpr(ctx, arg))
+		for i, arg := range expr.Args {
+			if i == 0 && isTypeArgBuiltin(ctx, expr.Fun) {
+				compiledArgs = append(compiledArgs, compileTypeExpr(ctx, arg))
+			} else if isUntypedConst(ctx, arg) {
+				// The constant takes on the type of the
+				// parameter, which is only known at runtime.
+				compiledArgs = append(compiledArgs, &apast.UntypedConstExpr{
+					compileExpr(ctx, arg),
+				})
+			} else {
+				compiledArgs = append(compiledArgs, compileExpr(ctx, arg))
+			}
 		}
 		return &apast.FuncCallExpr{
 			compileExpr(ctx, expr.Fun),
 			compiledArgs,
+			expr.Ellipsis.IsValid(),
+		}
+	case *ast.StarExpr:
+		return &apast.DerefExpr{
+			compileExpr(ctx, expr.X),
+		}
+	case *ast.UnaryExpr:
+		if expr.Op == token.AND {
+			return &apast.AddressExpr{
+				compileExpr(ctx, expr.X),
+			}
+		} else if op, ok := apruntime.UnaryOperators[expr.Op]; ok {
+			return &apast.FuncCallExpr{
+				&apast.LiteralExpr{
+					op,
+				},
+				[]apast.Expr{compileExpr(ctx, expr.X)},
+				false,
+			}
+		} else {
+			panic(fmt.Sprint("Operator not implemented: ", expr.Op))
 		}
-	//case *ast.StarExpr:
-	//	return nil
-	//case *ast.UnaryExpr:
-	//	return nil
 	case *ast.BinaryExpr:
 		if op, ok := apruntime.BinaryOperators[expr.Op]; ok {
 			return &apast.FuncCallExpr{
 				&apast.LiteralExpr{
 					op,
 				},
-				[]apast.Expr{compileExpr(ctx, expr.X), compileExpr(ctx, expr.Y)},
+				[]apast.Expr{compileOperand(ctx, expr.X, expr.Y), compileOperand(ctx, expr.Y, expr.X)},
+				false,
 			}
 		} else {
 			panic(fmt.Sprint("Operator not implemented: ", expr.Op))
@@ -408,16 +1446,157 @@ func compileExpr(ctx *CompileCtx, expr ast.Expr) apast.Expr {
 	}
 }
 
-func compilePackageFunc(ctx *CompileCtx, leftSide *ast.Ident, sel *ast.Ident) apast.Expr {
-	nativePackage := ctx.NativePackages[leftSide.Name]
-	if nativePackage == nil {
-		panic(fmt.Sprint("Unknown package ", leftSide.Name))
+// Compile an operand of a binary operator. An untyped constant operand takes the
+// type of the other operand, so the 2.0 in `n / 2.0` is an int if n is an int.
+// The evaluator converts it, since the other operand's type is only known at
+// runtime, except that constants compared with interface values keep their
+// default type.
+func compileOperand(ctx *CompileCtx, expr ast.Expr, other ast.Expr) apast.Expr {
+	compiled := compileExpr(ctx, expr)
+	if !isUntypedConst(ctx, expr) || isUntypedConst(ctx, other) || isInterfaceType(ctx, staticType(ctx, other)) {
+		return compiled
 	}
-	funcVal := nativePackage.Funcs[sel.Name]
-	if funcVal == nil {
-		panic(fmt.Sprint("Unknown function ", sel.Name))
+	return &apast.UntypedConstExpr{
+		compiled,
 	}
-	return &apast.LiteralExpr{funcVal}
+}
+
+var predeclaredConstants = map[string]interface{}{
+	"true": true,
+	"false": false,
+	"nil": nil,
+}
+
+var predeclaredFuncs = map[string]bool{
+	"append": true,
+	"cap": true,
+	"delete": true,
+	"len": true,
+	"make": true,
+	"new": true,
+	"panic": true,
+}
+
+// Resolve a name used as a value. Local variables shadow package-level
+// functions, which shadow predeclared names like true and len.
+func compileIdent(ctx *CompileCtx, ident *ast.Ident) apast.Expr {
+	if runtimeName, ok := ctx.ActiveVars[ident.Name]; ok {
+		return &apast.IdentExpr{
+			runtimeName,
+		}
+	} else if ctx.Funcs[ident.Name] {
+		return &apast.IdentExpr{
+			ident.Name,
+		}
+	} else if ctx.Package.Vars[ident.Name] {
+		return &apast.QualifiedIdentExpr{
+			ctx.Package,
+			ident.Name,
+		}
+	} else if imported := findDotImport(ctx, ident.Name); imported != nil {
+		return compileImportedMember(ctx, imported, ".", ident)
+	} else if val, ok := predeclaredConstants[ident.Name]; ok {
+		return &apast.LiteralExpr{val}
+	} else if predeclaredFuncs[ident.Name] {
+		return &apast.BuiltinExpr{
+			ident.Name,
+		}
+	}
+	compileError(ctx, ident, fmt.Sprint("undefined: ", ident.Name))
+	return nil
+}
+
+// Returns true if the identifier can only refer to a package, since no
+// variable, function or constant has that name.
+func refersToPackage(ctx *CompileCtx, ident *ast.Ident) bool {
+	_, isLocalConst := ctx.LocalConsts[ident.Name]
+	return ctx.ActiveVars[ident.Name] == "" && !ctx.Funcs[ident.Name] && !ctx.Package.Vars[ident.Name] &&
+		!isLocalConst && !isPackageConst(ctx, ctx.Package, ident.Name)
+}
+
+// Returns the interpreted package that the expression refers to, if any.
+func getPackage(ctx *CompileCtx, expr ast.Expr) (*apast.Package, bool) {
+	ident, ok := expr.(*ast.Ident)
+	if !ok || !refersToPackage(ctx, ident) {
+		return nil, false
+	}
+	imported, ok := ctx.Imports[ident.Name]
+	if !ok || imported.pack == nil {
+		return nil, false
+	}
+	return imported.pack, true
+}
+
+func isGenericFunc(ctx *CompileCtx, expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.SelectorExpr:
+		pack, ok := getPackage(ctx, expr.X)
+		return ok && isGenericPackageFunc(pack, expr.Sel.Name)
+	case *ast.Ident:
+		if ctx.ActiveVars[expr.Name] != "" {
+			return false
+		} else if ctx.Funcs[expr.Name] {
+			return ctx.GenericFuncs[expr.Name]
+		}
+		imported := findDotImport(ctx, expr.Name)
+		return imported != nil && imported.pack != nil && isGenericPackageFunc(imported.pack, expr.Name)
+	default:
+		return false
+	}
+}
+
+func isGenericPackageFunc(pack *apast.Package, name string) bool {
+	return pack.Funcs[name] != nil && pack.Funcs[name].TypeParams != nil
+}
+
+// Returns true if the expression is a builtin that takes a type as its first
+// argument, like `make([]int, 3)`.
+func isTypeArgBuiltin(ctx *CompileCtx, expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	if !ok || ctx.ActiveVars[ident.Name] != "" || ctx.Funcs[ident.Name] {
+		return false
+	}
+	return ident.Name == "make" || ident.Name == "new"
+}
+
+func compilePackageMember(ctx *CompileCtx, leftSide *ast.Ident, sel *ast.Ident) apast.Expr {
+	imported, ok := ctx.Imports[leftSide.Name]
+	if !ok {
+		compileError(ctx, leftSide, fmt.Sprint("undefined: ", leftSide.Name))
+	}
+	return compileImportedMember(ctx, imported, leftSide.Name, sel)
+}
+
+// Compile a reference to a function or variable of an imported package, which
+// is named in error messages the way the file refers to it. Constants are
+// compiled by compileConst instead.
+func compileImportedMember(ctx *CompileCtx, imported *importedPackage, packageName string, sel *ast.Ident) apast.Expr {
+	if pack := imported.pack; pack != nil {
+		if !ast.IsExported(sel.Name) {
+			compileError(ctx, sel, fmt.Sprint("name ", sel.Name, " not exported by package ", packageName))
+		}
+		if pack.Funcs[sel.Name] == nil && !pack.Vars[sel.Name] {
+			compileError(ctx, sel, fmt.Sprint("undefined: ", packageName, ".", sel.Name))
+		}
+		return &apast.QualifiedIdentExpr{
+			pack,
+			sel.Name,
+		}
+	}
+	nativePackage := imported.nativePackage
+	if funcVal, ok := nativePackage.Funcs[sel.Name]; ok {
+		return &apast.LiteralExpr{funcVal}
+	} else if constVal, ok := nativePackage.Consts[sel.Name]; ok {
+		return &apast.LiteralExpr{constVal}
+	} else if varPointer, ok := nativePackage.Vars[sel.Name]; ok {
+		// Native variables are accessed through their pointer, so
+		// `os.Args` becomes `*(&os.Args)`, which is also assignable.
+		return &apast.DerefExpr{
+			&apast.LiteralExpr{varPointer},
+		}
+	}
+	compileError(ctx, sel, fmt.Sprint("undefined: ", packageName, ".", sel.Name))
+	return nil
 }
 
 func compileCompositeLit(ctx *CompileCtx, expr *ast.CompositeLit) apast.Expr {
@@ -426,30 +1605,71 @@ func compileCompositeLit(ctx *CompileCtx, expr *ast.CompositeLit) apast.Expr {
 	// function.
 	switch exprType := expr.Type.(type) {
 	case *ast.ArrayType:
-		vals := []apast.Expr{}
-		for _, elt := range expr.Elts {
-			vals = append(vals, compileExpr(ctx, elt))
-		}
-		// All we really need to know is if it's a slice or an array; if
-		// it's an array, we know the length from the number of
-		// elements, so we don't need to store it.
+		vals := compileArrayElements(ctx, expr.Elts, func(elt ast.Expr) apast.Expr {
+			return compileElement(ctx, exprType.Elt, elt)
+		}, func() apast.Expr {
+			return getZeroValueExpr(ctx, exprType.Elt)
+		})
+		// The elements are filled in up to the array length, so we don't
+		// need to store it.
 		if exprType.Len == nil {
 			return &apast.SliceLiteralExpr{
-				compileExpr(ctx, exprType.Elt),
+				compileTypeExpr(ctx, exprType.Elt),
 				vals,
 			}
-		} else {
-			return &apast.ArrayLiteralExpr{
-				compileExpr(ctx, exprType.Elt),
-				vals,
+		}
+		if _, ok := exprType.Len.(*ast.Ellipsis); !ok {
+			length := compileTypeExpr(ctx, exprType).(*apast.ArrayTypeExpr).Len
+			for len(vals) < length {
+				vals = append(vals, getZeroValueExpr(ctx, exprType.Elt))
 			}
 		}
-	case *ast.Ident:
+		return &apast.ArrayLiteralExpr{
+			compileTypeExpr(ctx, exprType.Elt),
+			vals,
+		}
+	case *ast.MapType:
+		keys := []apast.Expr{}
+		vals := []apast.Expr{}
+		for _, elt := range expr.Elts {
+			kvElt, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				panic("Expected key-value pair in map literal.")
+			}
+			keys = append(keys, compileElement(ctx, exprType.Key, kvElt.Key))
+			vals = append(vals, compileElement(ctx, exprType.Value, kvElt.Value))
+		}
+		return &apast.MapLiteralExpr{
+			compileTypeExpr(ctx, exprType),
+			keys,
+			vals,
+		}
+	case *ast.StructType:
+		// Anonymous struct.
+		return compileStructLiteral(ctx, exprType, exprType, expr)
+	case *ast.ParenExpr:
+		return compileCompositeLit(ctx, &ast.CompositeLit{
+			Type: exprType.X,
+			Elts: expr.Elts,
+		})
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
 		// Struct creation.
-		if structDef, ok := ctx.StructDefs[exprType.Name]; ok {
-			return compileStructLiteral(ctx, exprType.Name, structDef, expr)
+		if nativeType, ok := resolveNativeType(ctx, exprType); ok {
+			return compileNativeCompositeLit(ctx, nativeType, expr)
+		} else if structDef := getStructDef(ctx, exprType); structDef != nil {
+			return compileStructLiteral(ctx, exprType, structDef, expr)
+		} else if underlying, ok := getTypeDef(ctx, exprType); ok {
+			// Build a literal of the underlying type and convert it,
+			// e.g. `IntList{1, 2}` becomes `IntList([]int{1, 2})`.
+			return &apast.ConversionExpr{
+				compileTypeExpr(ctx, exprType),
+				compileCompositeLit(ctx, &ast.CompositeLit{
+					Type: underlying,
+					Elts: expr.Elts,
+				}),
+			}
 		} else {
-			panic(fmt.Sprint("Unknown struct ", exprType.Name))
+			panic(fmt.Sprint("Unknown struct ", exprType))
 		}
 	default:
 		panic(fmt.Sprint("Composite literal not implemented: ", reflect.TypeOf(exprType)))
@@ -457,106 +1677,638 @@ func compileCompositeLit(ctx *CompileCtx, expr *ast.CompositeLit) apast.Expr {
 	}
 }
 
+// Compile a composite literal of a native type, like `image.Point{1, 2}` or
+// `sort.IntSlice{3, 1, 2}`. Element types come from the native type, so
+// elements can leave out their types, like `[]image.Point{{0, 0}, {2, 2}}`.
+func compileNativeCompositeLit(ctx *CompileCtx, nativeType reflect.Type, expr *ast.CompositeLit) apast.Expr {
+	switch nativeType.Kind() {
+	case reflect.Struct:
+		initialValues := make(map[string]apast.Expr)
+		for i, elt := range expr.Elts {
+			var field reflect.StructField
+			if kvElt, ok := elt.(*ast.KeyValueExpr); ok {
+				keyIdent, ok := kvElt.Key.(*ast.Ident)
+				if !ok {
+					panic("Expected identifier as struct literal key.")
+				}
+				// Promoted fields can't be set in a literal.
+				field, ok = nativeType.FieldByName(keyIdent.Name)
+				if !ok || len(field.Index) > 1 {
+					compileError(ctx, keyIdent, fmt.Sprint("unknown field ", keyIdent.Name, " in struct literal of type ", nativeType))
+				}
+				elt = kvElt.Value
+			} else if i < nativeType.NumField() {
+				field = nativeType.Field(i)
+			} else {
+				compileError(ctx, elt, fmt.Sprint("too many values in struct literal of type ", nativeType))
+			}
+			if field.PkgPath != "" {
+				compileError(ctx, elt, fmt.Sprint("cannot refer to unexported field ", field.Name, " in struct literal of type ", nativeType))
+			}
+			initialValues[field.Name] = compileExpr(ctx, elt)
+		}
+		return &apast.NativeStructLiteralExpr{
+			nativeType,
+			initialValues,
+		}
+	case reflect.Slice, reflect.Array:
+		vals := compileArrayElements(ctx, expr.Elts, func(elt ast.Expr) apast.Expr {
+			return compileNativeElement(ctx, nativeType.Elem(), elt)
+		}, func() apast.Expr {
+			return &apast.LiteralExpr{reflect.Zero(nativeType.Elem()).Interface()}
+		})
+		elemType := &apast.NativeTypeExpr{nativeType.Elem()}
+		var literal apast.Expr = &apast.SliceLiteralExpr{elemType, vals}
+		if nativeType.Kind() == reflect.Array {
+			for len(vals) < nativeType.Len() {
+				vals = append(vals, &apast.LiteralExpr{reflect.Zero(nativeType.Elem()).Interface()})
+			}
+			literal = &apast.ArrayLiteralExpr{elemType, vals}
+		}
+		// The literal has the unnamed type, e.g. []int for sort.IntSlice,
+		// so convert it.
+		return &apast.ConversionExpr{
+			&apast.NativeTypeExpr{nativeType},
+			literal,
+		}
+	case reflect.Map:
+		keys := []apast.Expr{}
+		vals := []apast.Expr{}
+		for _, elt := range expr.Elts {
+			kvElt, ok := elt.(*ast.KeyValueExpr)
+			if !ok {
+				panic("Expected key-value pair in map literal.")
+			}
+			keys = append(keys, compileNativeElement(ctx, nativeType.Key(), kvElt.Key))
+			vals = append(vals, compileNativeElement(ctx, nativeType.Elem(), kvElt.Value))
+		}
+		return &apast.ConversionExpr{
+			&apast.NativeTypeExpr{nativeType},
+			&apast.MapLiteralExpr{
+				&apast.MapTypeExpr{
+					&apast.NativeTypeExpr{nativeType.Key()},
+					&apast.NativeTypeExpr{nativeType.Elem()},
+				},
+				keys,
+				vals,
+			},
+		}
+	default:
+		compileError(ctx, expr, fmt.Sprint("invalid composite literal type ", nativeType))
+		return nil
+	}
+}
+
+// Like compileElement, but for elements of native composite literals.
+func compileNativeElement(ctx *CompileCtx, elemType reflect.Type, elt ast.Expr) apast.Expr {
+	lit, ok := elt.(*ast.CompositeLit)
+	if !ok || lit.Type != nil {
+		return compileExpr(ctx, elt)
+	}
+	if elemType.Kind() == reflect.Ptr {
+		return &apast.AddressExpr{
+			compileNativeCompositeLit(ctx, elemType.Elem(), lit),
+		}
+	}
+	return compileNativeCompositeLit(ctx, elemType, lit)
+}
+
+// Compile the elements of an array or slice literal, in index order. Elements
+// may have constant index keys, like `[]string{2: "b", "c"}`, and any skipped
+// indices are filled in with the zero value.
+func compileArrayElements(ctx *CompileCtx, elts []ast.Expr,
+		compileElt func(elt ast.Expr) apast.Expr, zeroValue func() apast.Expr) []apast.Expr {
+	vals := []apast.Expr{}
+	index := 0
+	for _, elt := range elts {
+		if kvElt, ok := elt.(*ast.KeyValueExpr); ok {
+			index = evalIntConst(ctx, kvElt.Key)
+			elt = kvElt.Value
+		}
+		for len(vals) <= index {
+			vals = append(vals, nil)
+		}
+		vals[index] = compileElt(elt)
+		index++
+	}
+	for i, val := range vals {
+		if val == nil {
+			vals[i] = zeroValue()
+		}
+	}
+	return vals
+}
+
+// Compile an element, key, or value within a composite literal. Composite
+// literals within it can leave out the type, e.g. `[]Point{{1, 2}}`, or
+// `[]*Point{{1, 2}}`, which is short for `[]*Point{&Point{1, 2}}`.
+func compileElement(ctx *CompileCtx, elemType ast.Expr, elt ast.Expr) apast.Expr {
+	lit, ok := elt.(*ast.CompositeLit)
+	if !ok || lit.Type != nil {
+		return compileExpr(ctx, elt)
+	}
+	if pointerType, ok := elemType.(*ast.StarExpr); ok {
+		return &apast.AddressExpr{
+			compileCompositeLit(ctx, &ast.CompositeLit{
+				Type: pointerType.X,
+				Elts: lit.Elts,
+			}),
+		}
+	}
+	return compileCompositeLit(ctx, &ast.CompositeLit{
+		Type: elemType,
+		Elts: lit.Elts,
+	})
+}
+
 func compileStructLiteral(
-		ctx *CompileCtx, structName string, structDef *ast.StructType,
+		ctx *CompileCtx, structType ast.Expr, structDef *ast.StructType,
 		expr *ast.CompositeLit) apast.Expr {
 	// Start out all fields with the zero value, then later replace any that
 	// are specified explicitly.
-	literalExpr, fieldNames := getStructZeroValueExpr(ctx, structName, structDef)
+	literalExpr, fieldNames := getStructZeroValueExpr(ctx, structType, structDef)
 	for i, elt := range expr.Elts {
 		if kvElt, ok := elt.(*ast.KeyValueExpr); ok {
 			if keyIdent, ok := kvElt.Key.(*ast.Ident); ok {
-				literalExpr.InitialValues[keyIdent.Name] = compileExpr(ctx, kvElt.Value)
+				literalExpr.InitialValues[keyIdent.Name] = compileAssignedValue(ctx,
+					kvElt.Value, getFieldType(structDef, keyIdent.Name))
 			} else {
 				panic("Expected identifier as struct literal key.")
 			}
 		} else {
-			literalExpr.InitialValues[fieldNames[i]] = compileExpr(ctx, elt)
+			literalExpr.InitialValues[fieldNames[i]] = compileAssignedValue(ctx,
+				elt, getFieldType(structDef, fieldNames[i]))
 		}
 	}
 
 	return literalExpr
 }
 
-// parseLiteral takes a primitive literal and returns it as a value.
-func parseLiteral(val string, kind token.Token) interface{} {
-	switch kind {
-	case token.IDENT:
-		panic("TODO")
-		return nil
-	case token.INT:
-		// Note that base 0 means that octal and hex literals are also
-		// handled. We also treat the number as an int instead of an
-		// int64 so that comparisons work right.
-		result, err := strconv.ParseInt(val, 0, 0)
-		if err != nil {
-			panic(err)
+func getZeroValueExpr(ctx *CompileCtx, t ast.Expr) apast.Expr {
+	switch t := t.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		if isTypeParam(ctx, t) {
+			// The type isn't known until runtime.
+			return &apast.ZeroValueExpr{
+				compileTypeExpr(ctx, t),
+			}
+		} else if nativeType, ok := resolveNativeType(ctx, t); ok {
+			return &apast.LiteralExpr{
+				reflect.Zero(nativeType).Interface(),
+			}
+		} else if structDef := getStructDef(ctx, t); structDef != nil {
+			result, _ := getStructZeroValueExpr(ctx, t, structDef)
+			return result
+		} else if isInterfaceType(ctx, t) {
+			return &apast.LiteralExpr{nil}
+		} else if underlying, ok := getTypeDef(ctx, t); ok {
+			return &apast.ConversionExpr{
+				compileTypeExpr(ctx, t),
+				getZeroValueExpr(ctx, underlying),
+			}
+		} else if ident, ok := t.(*ast.Ident); ok && apruntime.BasicTypes[ident.Name] != nil {
+			return &apast.LiteralExpr{
+				reflect.Zero(apruntime.BasicTypes[ident.Name]).Interface(),
+			}
+		} else if selectorExpr, ok := t.(*ast.SelectorExpr); ok {
+			packageTypeError(ctx, selectorExpr)
+			return nil
+		} else {
+			panic(fmt.Sprint("Unexpected type identifier: ", t))
 		}
-		return int(result)
-	case token.FLOAT:
-		panic("TODO")
-		return nil
-	case token.IMAG:
-		panic("TODO")
-		return nil
-	case token.CHAR:
-		panic("TODO")
+	case *ast.ParenExpr:
+		return getZeroValueExpr(ctx, t.X)
+	case *ast.StructType:
+		result, _ := getStructZeroValueExpr(ctx, t, t)
+		return result
+	case *ast.StarExpr:
+		// Nil pointers keep the type they point to, which is resolved
+		// at runtime.
+		return &apast.ZeroValueExpr{
+			compileTypeExpr(ctx, t),
+		}
+	case *ast.InterfaceType, *ast.FuncType:
+		return &apast.LiteralExpr{nil}
+	case *ast.ArrayType, *ast.MapType:
+		if arrayType, ok := t.(*ast.ArrayType); ok && arrayType.Len != nil {
+			// Elements of interpreted types, like structs, each need
+			// their own zero value, like struct fields do.
+			elemZero := getZeroValueExpr(ctx, arrayType.Elt)
+			if _, ok := elemZero.(*apast.LiteralExpr); !ok {
+				length := compileTypeExpr(ctx, arrayType).(*apast.ArrayTypeExpr).Len
+				vals := []apast.Expr{}
+				for i := 0; i < length; i++ {
+					vals = append(vals, elemZero)
+				}
+				return &apast.ArrayLiteralExpr{
+					compileTypeExpr(ctx, arrayType.Elt),
+					vals,
+				}
+			}
+		}
+		// Slices and maps are nil, and arrays of native values are
+		// zeroed by the conversion.
+		return &apast.ConversionExpr{
+			compileTypeExpr(ctx, t),
+			&apast.LiteralExpr{nil},
+		}
+	default:
+		panic(fmt.Sprint("Zero value not implemented for type ", reflect.TypeOf(t)))
+	}
+}
+
+// Returns the struct definition for the given type name, following named types
+// declared in terms of other struct types, like `type Point3 Point`. Returns nil
+// if the type isn't a struct.
+func getStructDef(ctx *CompileCtx, typeName ast.Expr) *ast.StructType {
+	typeDef, ok := getTypeDef(ctx, typeName)
+	if !ok {
 		return nil
-	case token.STRING:
-		return parseString(val)
+	}
+	switch typeDef := typeDef.(type) {
+	case *ast.StructType:
+		return typeDef
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		return getStructDef(ctx, typeDef)
 	default:
-		fmt.Print("Unrecognized kind: ", kind)
 		return nil
 	}
 }
 
-func parseString(codeString string) string {
-	strWithoutQuotes := codeString[1:len(codeString) - 1]
-	// TODO: Replace with an implementation that properly escapes
-	// everything.
-	return strings.Replace(strWithoutQuotes, "\\n", "\n", -1)
+// Returns the type that a named type was declared with. For generic types,
+// the type arguments are substituted in, e.g. `struct { items []int }` for
+// `Stack[int]`.
+func getTypeDef(ctx *CompileCtx, typeName ast.Expr) (ast.Expr, bool) {
+	name, typeArgs := splitTypeInstance(typeName)
+	qualifiedName, ok := resolveTypeName(ctx, name)
+	if !ok {
+		return nil, false
+	}
+	var typeDef ast.Expr
+	if structDef, ok := ctx.StructDefs[qualifiedName]; ok {
+		typeDef = structDef
+	} else {
+		typeDef = ctx.TypeDefs[qualifiedName]
+	}
+	if typeArgs == nil {
+		return typeDef, true
+	}
+	bindings := make(map[string]ast.Expr)
+	for i, name := range ctx.GenericTypes[qualifiedName] {
+		bindings[name] = typeArgs[i]
+	}
+	return substituteTypeParams(typeDef, bindings), true
 }
 
-func getZeroValueExpr(ctx *CompileCtx, t ast.Expr) apast.Expr {
-	// TODO: Consider using reflect.New here.
-	if t, ok := t.(*ast.Ident); ok {
-		switch t.Name {
-		case "int":
-			return &apast.LiteralExpr{0}
-		}
-		if structDef, ok := ctx.StructDefs[t.Name]; ok {
-			result, _ := getStructZeroValueExpr(ctx, t.Name, structDef)
-			return result
-		} else {
-			panic(fmt.Sprint("Unexpected type identifier: ", t.Name))
+func isTypeParam(ctx *CompileCtx, t ast.Expr) bool {
+	ident, ok := t.(*ast.Ident)
+	return ok && ctx.TypeParams[ident.Name]
+}
+
+var predeclaredInterfaces = map[string]bool{
+	"error": true,
+	"any": true,
+	"comparable": true,
+}
+
+func isInterfaceType(ctx *CompileCtx, t ast.Expr) bool {
+	switch t := t.(type) {
+	case *ast.InterfaceType:
+		return true
+	case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr, *ast.IndexListExpr:
+		if underlying, ok := getTypeDef(ctx, t); ok {
+			return isInterfaceType(ctx, underlying)
 		}
-	} else {
-		panic("Zero for non-identifier types not implemented")
+		if nativeType, ok := resolveNativeType(ctx, t); ok {
+			return nativeType.Kind() == reflect.Interface
+		}
+		ident, ok := t.(*ast.Ident)
+		return ok && !isTypeParam(ctx, ident) && predeclaredInterfaces[ident.Name]
+	case *ast.ParenExpr:
+		return isInterfaceType(ctx, t.X)
+	default:
+		return false
+	}
+}
+
+// An embedded field is named after its type, ignoring any pointer or package
+// name.
+func getEmbeddedFieldName(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.Ident:
+		_, name := apast.SplitQualifiedName(t.Name)
+		return name
+	case *ast.IndexExpr:
+		return getEmbeddedFieldName(t.X)
+	case *ast.IndexListExpr:
+		return getEmbeddedFieldName(t.X)
+	case *ast.StarExpr:
+		return getEmbeddedFieldName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	default:
+		panic(fmt.Sprint("Unexpected embedded field type: ", reflect.TypeOf(t)))
+	}
+}
+
+// Returns true if the expression refers to a type rather than a value.
+func isTypeExpr(ctx *CompileCtx, expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if _, ok := ctx.ActiveVars[expr.Name]; ok {
+			return false
+		}
+		_, isDeclaredType := resolveTypeName(ctx, expr)
+		_, isNativeType := resolveNativeType(ctx, expr)
+		_, isBasicType := apruntime.BasicTypes[expr.Name]
+		return isDeclaredType || isNativeType || isBasicType ||
+			predeclaredInterfaces[expr.Name] || ctx.TypeParams[expr.Name]
+	case *ast.SelectorExpr:
+		_, isDeclaredType := resolveTypeName(ctx, expr)
+		_, isNativeType := resolveNativeType(ctx, expr)
+		return isDeclaredType || isNativeType
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		typeName, _ := splitTypeInstance(expr)
+		qualifiedName, ok := resolveTypeName(ctx, typeName)
+		_, isGenericType := ctx.GenericTypes[qualifiedName]
+		return ok && isGenericType
+	case *ast.ParenExpr:
+		return isTypeExpr(ctx, expr.X)
+	case *ast.StarExpr:
+		return isTypeExpr(ctx, expr.X)
+	case *ast.ArrayType, *ast.StructType, *ast.InterfaceType, *ast.FuncType, *ast.MapType:
+		return true
+	default:
+		return false
+	}
+}
+
+func compileTypeExpr(ctx *CompileCtx, expr ast.Expr) apast.Expr {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if qualifiedName, ok := resolveTypeName(ctx, expr); ok {
+			return &apast.IdentExpr{
+				qualifiedName,
+			}
+		}
+		if nativeType, ok := resolveNativeType(ctx, expr); ok {
+			return &apast.NativeTypeExpr{
+				nativeType,
+			}
+		}
+		return &apast.IdentExpr{
+			expr.Name,
+		}
+	case *ast.ParenExpr:
+		return compileTypeExpr(ctx, expr.X)
+	case *ast.ArrayType:
+		if expr.Len == nil {
+			return &apast.SliceTypeExpr{
+				compileTypeExpr(ctx, expr.Elt),
+			}
+		}
+		return &apast.ArrayTypeExpr{
+			evalIntConst(ctx, expr.Len),
+			compileTypeExpr(ctx, expr.Elt),
+		}
+	case *ast.StructType:
+		fields := []*apast.FieldDecl{}
+		for _, field := range expr.Fields.List {
+			fieldType := compileTypeExpr(ctx, field.Type)
+			tag := ""
+			if field.Tag != nil {
+				tag, _ = strconv.Unquote(field.Tag.Value)
+			}
+			if len(field.Names) == 0 {
+				fields = append(fields, &apast.FieldDecl{
+					getEmbeddedFieldName(field.Type),
+					fieldType,
+					true,
+					tag,
+				})
+			}
+			for _, name := range field.Names {
+				fields = append(fields, &apast.FieldDecl{
+					name.Name,
+					fieldType,
+					false,
+					tag,
+				})
+			}
+		}
+		return &apast.StructTypeExpr{
+			fields,
+		}
+	case *ast.StarExpr:
+		return &apast.PointerTypeExpr{
+			compileTypeExpr(ctx, expr.X),
+		}
+	case *ast.MapType:
+		return &apast.MapTypeExpr{
+			compileTypeExpr(ctx, expr.Key),
+			compileTypeExpr(ctx, expr.Value),
+		}
+	case *ast.SelectorExpr:
+		if nativeType, ok := resolveNativeType(ctx, expr); ok {
+			return &apast.NativeTypeExpr{
+				nativeType,
+			}
+		}
+		qualifiedName, ok := resolveTypeName(ctx, expr)
+		if !ok {
+			packageTypeError(ctx, expr)
+		}
+		return &apast.IdentExpr{
+			qualifiedName,
+		}
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		typeName, typeArgs := splitTypeInstance(expr)
+		qualifiedName, ok := resolveTypeName(ctx, typeName)
+		if !ok {
+			panic(fmt.Sprint("Unexpected generic type: ", expr))
+		}
+		compiledTypeArgs := []apast.Expr{}
+		for _, typeArg := range typeArgs {
+			compiledTypeArgs = append(compiledTypeArgs, compileTypeExpr(ctx, typeArg))
+		}
+		return &apast.InstantiatedTypeExpr{
+			qualifiedName,
+			compiledTypeArgs,
+		}
+	case *ast.FuncType:
+		return compileFuncType(ctx, expr)
+	case *ast.InterfaceType:
+		methodNames := []string{}
+		methodTypes := []*apast.FuncTypeExpr{}
+		embedded := []apast.Expr{}
+		unions := []*apast.TypeUnion{}
+		for _, field := range expr.Methods.List {
+			if len(field.Names) == 0 {
+				// Anything other than an interface is a type set,
+				// like `~int | ~float64`.
+				if isInterfaceType(ctx, field.Type) {
+					embedded = append(embedded, compileTypeExpr(ctx, field.Type))
+				} else {
+					unions = append(unions, compileTypeUnion(ctx, field.Type))
+				}
+			}
+			for _, name := range field.Names {
+				methodNames = append(methodNames, name.Name)
+				methodTypes = append(methodTypes, compileFuncType(ctx, field.Type.(*ast.FuncType)))
+			}
+		}
+		return &apast.InterfaceTypeExpr{
+			methodNames,
+			methodTypes,
+			embedded,
+			unions,
+		}
+	default:
+		panic(fmt.Sprint("Type expression not implemented: ", reflect.TypeOf(expr)))
 	}
 }
 
 // Returns an initialization expression for the struct and a list of the fields
 // in the struct (for convenience, since some callers want to refer to field
 // names by index).
-func getStructZeroValueExpr(ctx *CompileCtx, structName string,
+func getStructZeroValueExpr(ctx *CompileCtx, structType ast.Expr,
 		structDef *ast.StructType) (
 		*apast.StructLiteralExpr, []string) {
-	fields := structDef.Fields.List
 	initialValues := make(map[string]apast.Expr)
-	fieldNames := make([]string, len(fields), len(fields))
+	fieldNames := []string{}
 
 	// Start out all fields with the zero value, then later replace any that
 	// are specified explicitly.
-	for i, field := range fields {
-		if len(field.Names) != 1 {
-			panic("Expected exactly one field name in struct def.")
+	for _, field := range structDef.Fields.List {
+		names := []string{}
+		if len(field.Names) == 0 {
+			names = append(names, getEmbeddedFieldName(field.Type))
+		}
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+		for _, fieldName := range names {
+			fieldNames = append(fieldNames, fieldName)
+			initialValues[fieldName] = getZeroValueExpr(ctx, field.Type)
 		}
-		fieldName := field.Names[0].Name
-		fieldNames[i] = fieldName
-		initialValues[fieldName] = getZeroValueExpr(ctx, field.Type)
+	}
+	// Anonymous structs have no type name.
+	name, typeArgs := splitTypeInstance(structType)
+	typeName, _ := resolveTypeName(ctx, name)
+	var compiledTypeArgs []apast.Expr
+	for _, typeArg := range typeArgs {
+		compiledTypeArgs = append(compiledTypeArgs, compileTypeExpr(ctx, typeArg))
+	}
+	var anonymousType *apast.StructTypeExpr
+	if typeName == "" {
+		anonymousType = compileTypeExpr(ctx, structDef).(*apast.StructTypeExpr)
 	}
 	return &apast.StructLiteralExpr{
-		structName,
+		typeName,
+		compiledTypeArgs,
+		anonymousType,
 		initialValues,
 	}, fieldNames
-}
\ No newline at end of file
+}
+// Replace type parameters in a type expression with the given types, e.g. to
+// get the struct definition for a generic struct type with specific type
+// arguments.
+func substituteTypeParams(t ast.Expr, bindings map[string]ast.Expr) ast.Expr {
+	return rewriteTypeNames(t, func(typeName ast.Expr) ast.Expr {
+		if ident, ok := typeName.(*ast.Ident); ok {
+			if binding, ok := bindings[ident.Name]; ok {
+				return binding
+			}
+		}
+		return typeName
+	})
+}
+
+// Replace each type name within a type expression, which is either an
+// identifier or a package selector like `geom.Point`, with the result of the
+// rewrite function.
+func rewriteTypeNames(t ast.Expr, rewrite func(typeName ast.Expr) ast.Expr) ast.Expr {
+	switch t := t.(type) {
+	case *ast.Ident, *ast.SelectorExpr:
+		return rewrite(t)
+	case *ast.ParenExpr:
+		return rewriteTypeNames(t.X, rewrite)
+	case *ast.ArrayType:
+		// The length may refer to constants, like `[N]int`.
+		return &ast.ArrayType{
+			Len: rewriteTypeNames(t.Len, rewrite),
+			Elt: rewriteTypeNames(t.Elt, rewrite),
+		}
+	case *ast.StarExpr:
+		return &ast.StarExpr{
+			X: rewriteTypeNames(t.X, rewrite),
+		}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{
+			Elt: rewriteTypeNames(t.Elt, rewrite),
+		}
+	case *ast.MapType:
+		return &ast.MapType{
+			Key: rewriteTypeNames(t.Key, rewrite),
+			Value: rewriteTypeNames(t.Value, rewrite),
+		}
+	case *ast.StructType:
+		return &ast.StructType{
+			Fields: rewriteFieldList(t.Fields, rewrite),
+		}
+	case *ast.FuncType:
+		return &ast.FuncType{
+			Params: rewriteFieldList(t.Params, rewrite),
+			Results: rewriteFieldList(t.Results, rewrite),
+		}
+	case *ast.InterfaceType:
+		return &ast.InterfaceType{
+			Methods: rewriteFieldList(t.Methods, rewrite),
+		}
+	case *ast.IndexExpr:
+		return &ast.IndexExpr{
+			X: rewriteTypeNames(t.X, rewrite),
+			Index: rewriteTypeNames(t.Index, rewrite),
+		}
+	case *ast.IndexListExpr:
+		indices := []ast.Expr{}
+		for _, index := range t.Indices {
+			indices = append(indices, rewriteTypeNames(index, rewrite))
+		}
+		return &ast.IndexListExpr{
+			X: rewriteTypeNames(t.X, rewrite),
+			Indices: indices,
+		}
+	case *ast.UnaryExpr:
+		// A term of a type set, like ~int.
+		return &ast.UnaryExpr{
+			Op: t.Op,
+			X: rewriteTypeNames(t.X, rewrite),
+		}
+	case *ast.BinaryExpr:
+		// A union of a type set, like `~int | ~float64`.
+		return &ast.BinaryExpr{
+			X: rewriteTypeNames(t.X, rewrite),
+			Op: t.Op,
+			Y: rewriteTypeNames(t.Y, rewrite),
+		}
+	default:
+		return t
+	}
+}
+
+func rewriteFieldList(fieldList *ast.FieldList, rewrite func(typeName ast.Expr) ast.Expr) *ast.FieldList {
+	if fieldList == nil {
+		return nil
+	}
+	fields := []*ast.Field{}
+	for _, field := range fieldList.List {
+		fields = append(fields, &ast.Field{
+			Names: field.Names,
+			Type: rewriteTypeNames(field.Type, rewrite),
+		})
+	}
+	return &ast.FieldList{
+		List: fields,
+	}
+}
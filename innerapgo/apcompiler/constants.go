@@ -0,0 +1,478 @@
+package apcompiler
+
+import (
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"github.com/alangpierce/apgo/apruntime"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+// Constant expressions are evaluated at compile time, like in Go, so that
+// constants can be used in array lengths and iota works, and references to
+// constants are compiled to literals.
+
+// The value of a constant expression.
+type constValue struct {
+	val constant.Value
+	// The type of a typed constant, with type names resolved to qualified
+	// names, or nil for untyped constants.
+	typ ast.Expr
+	// The type of the value at runtime, which is the default type of an
+	// untyped constant, like rune for 'a', and the underlying type of a
+	// constant of a declared type, like float64 for Celsius.
+	goType reflect.Type
+}
+
+// A package-level constant. Constants can refer to ones declared later, so
+// each one is evaluated when it's first needed.
+type constDecl struct {
+	name *ast.Ident
+	// The file declaring the constant, whose imports the value can use.
+	file *ast.File
+	// The declared type, or nil, and the value, which may be repeated from
+	// an earlier spec, like in `const (A = iota; B)`.
+	typ ast.Expr
+	value ast.Expr
+	iota int
+	// The value once it's been evaluated.
+	result *constValue
+	evaluating bool
+}
+
+var untypedLiteralTypes = map[token.Token]reflect.Type{
+	token.INT: reflect.TypeOf(0),
+	token.FLOAT: reflect.TypeOf(0.0),
+	token.IMAG: reflect.TypeOf(0i),
+	token.CHAR: reflect.TypeOf('a'),
+	token.STRING: reflect.TypeOf(""),
+}
+
+// When untyped constants of different kinds are combined, the kind that's
+// later in this list determines the kind of the result, e.g. 1 + 2.5 is a
+// floating-point constant.
+var untypedNumericRanks = map[reflect.Type]int{
+	reflect.TypeOf(0): 1,
+	reflect.TypeOf('a'): 2,
+	reflect.TypeOf(0.0): 3,
+	reflect.TypeOf(0i): 4,
+}
+
+var untypedBoolType = reflect.TypeOf(false)
+
+// Call f for each constant in a const declaration. Specs without values repeat
+// the type and values of the previous spec, and iota is the index of the spec.
+func forEachConstSpec(decl *ast.GenDecl, f func(name *ast.Ident, typ ast.Expr, value ast.Expr, iota int)) {
+	var typ ast.Expr
+	var values []ast.Expr
+	for i, spec := range decl.Specs {
+		valueSpec := spec.(*ast.ValueSpec)
+		if len(valueSpec.Values) > 0 {
+			typ, values = valueSpec.Type, valueSpec.Values
+		}
+		for j, name := range valueSpec.Names {
+			if name.Name != "_" && j < len(values) {
+				f(name, typ, values[j], i)
+			}
+		}
+	}
+}
+
+// Record the constants of a package-level const declaration.
+func declareConsts(ctx *CompileCtx, file *ast.File, decl *ast.GenDecl) {
+	forEachConstSpec(decl, func(name *ast.Ident, typ ast.Expr, value ast.Expr, iota int) {
+		ctx.Consts[apast.QualifiedName(ctx.Package.Path, name.Name)] = &constDecl{
+			name,
+			file,
+			typ,
+			value,
+			iota,
+			nil,
+			false,
+		}
+	})
+}
+
+// Evaluate all constants of a package-level const declaration, so that other
+// packages can use them.
+func evaluateConsts(ctx *CompileCtx, decl *ast.GenDecl) {
+	forEachConstSpec(decl, func(name *ast.Ident, typ ast.Expr, value ast.Expr, iota int) {
+		evaluateConstDecl(ctx, ctx.Consts[apast.QualifiedName(ctx.Package.Path, name.Name)])
+	})
+}
+
+// Declare the constants of a const declaration within a function, which are
+// scoped like local variables.
+func declareLocalConsts(ctx *CompileCtx, decl *ast.GenDecl) {
+	outerIota := ctx.Iota
+	defer func() {
+		ctx.Iota = outerIota
+	}()
+	forEachConstSpec(decl, func(name *ast.Ident, typ ast.Expr, value ast.Expr, iota int) {
+		ctx.Iota = iota
+		ctx.LocalConsts[name.Name] = evaluateConstSpec(ctx, typ, value)
+		delete(ctx.ActiveVars, name.Name)
+	})
+}
+
+// Get the value of a package-level constant, evaluating it in the scope of the
+// file it's declared in if it hasn't been evaluated yet.
+func evaluateConstDecl(ctx *CompileCtx, decl *constDecl) *constValue {
+	if decl.result != nil {
+		return decl.result
+	}
+	if decl.evaluating {
+		compileError(ctx, decl.name, fmt.Sprint("initialization cycle for ", decl.name.Name))
+	}
+	decl.evaluating = true
+	imports, dotImports := ctx.Imports, ctx.DotImports
+	activeVars, localConsts := ctx.ActiveVars, ctx.LocalConsts
+	typeParams, outerIota := ctx.TypeParams, ctx.Iota
+	defer func() {
+		ctx.Imports, ctx.DotImports = imports, dotImports
+		ctx.ActiveVars, ctx.LocalConsts = activeVars, localConsts
+		ctx.TypeParams, ctx.Iota = typeParams, outerIota
+		decl.evaluating = false
+	}()
+	compileImports(ctx, decl.file)
+	ctx.ActiveVars = make(map[string]string)
+	ctx.LocalConsts = make(map[string]*constValue)
+	ctx.TypeParams = make(map[string]bool)
+	ctx.Iota = decl.iota
+	decl.result = evaluateConstSpec(ctx, decl.typ, decl.value)
+	return decl.result
+}
+
+// Evaluate the value of a constant, converted to its declared type, if any.
+func evaluateConstSpec(ctx *CompileCtx, typ ast.Expr, value ast.Expr) *constValue {
+	result, ok := evalConst(ctx, value)
+	if !ok {
+		compileError(ctx, value, fmt.Sprint("const initializer ", types.ExprString(value), " is not a constant"))
+	}
+	if typ != nil {
+		result, ok = convertConst(ctx, result, typ)
+		if !ok {
+			compileError(ctx, typ, fmt.Sprint("invalid constant type ", types.ExprString(typ)))
+		}
+	}
+	return result
+}
+
+// Evaluate an expression if it's a constant expression, like `2 * math.Pi` or
+// `Celsius(100)`.
+func evalConst(ctx *CompileCtx, expr ast.Expr) (*constValue, bool) {
+	switch expr := expr.(type) {
+	case *ast.BasicLit:
+		return &constValue{
+			constant.MakeFromLiteral(expr.Value, expr.Kind, 0),
+			nil,
+			untypedLiteralTypes[expr.Kind],
+		}, true
+	case *ast.Ident:
+		return lookupConst(ctx, expr)
+	case *ast.ParenExpr:
+		return evalConst(ctx, expr.X)
+	case *ast.SelectorExpr:
+		ident, ok := expr.X.(*ast.Ident)
+		if !ok || !refersToPackage(ctx, ident) || ctx.Imports[ident.Name] == nil {
+			return nil, false
+		}
+		return importedConst(ctx, ctx.Imports[ident.Name], expr.Sel.Name)
+	case *ast.UnaryExpr:
+		x, ok := evalConst(ctx, expr.X)
+		if !ok {
+			return nil, false
+		}
+		switch expr.Op {
+		case token.ADD, token.SUB, token.XOR, token.NOT:
+			// Complementing an unsigned value only flips the bits of
+			// its size.
+			var prec uint
+			if isUnsignedKind(x.goType.Kind()) {
+				prec = uint(x.goType.Bits())
+			}
+			return &constValue{
+				constant.UnaryOp(expr.Op, x.val, prec),
+				x.typ,
+				x.goType,
+			}, true
+		}
+	case *ast.BinaryExpr:
+		x, xIsConst := evalConst(ctx, expr.X)
+		y, yIsConst := evalConst(ctx, expr.Y)
+		if xIsConst && yIsConst {
+			return binaryConst(expr.Op, x, y), true
+		}
+	case *ast.CallExpr:
+		if len(expr.Args) != 1 || expr.Ellipsis.IsValid() {
+			return nil, false
+		}
+		arg, ok := evalConst(ctx, expr.Args[0])
+		if !ok {
+			return nil, false
+		}
+		if isTypeExpr(ctx, expr.Fun) {
+			return convertConst(ctx, arg, expr.Fun)
+		}
+		// The length of a constant string is a constant.
+		if ident, ok := expr.Fun.(*ast.Ident); ok && ident.Name == "len" && isPredeclared(ctx, ident) &&
+				arg.val.Kind() == constant.String {
+			return &constValue{
+				constant.MakeInt64(int64(len(constant.StringVal(arg.val)))),
+				&ast.Ident{Name: "int"},
+				reflect.TypeOf(0),
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// Find the constant that a name refers to, if any. Like in compileIdent, local
+// names shadow package-level names, which shadow predeclared ones.
+func lookupConst(ctx *CompileCtx, ident *ast.Ident) (*constValue, bool) {
+	if result, ok := ctx.LocalConsts[ident.Name]; ok {
+		return result, true
+	}
+	if !isPredeclared(ctx, ident) {
+		return nil, false
+	}
+	if decl, ok := ctx.Consts[apast.QualifiedName(ctx.Package.Path, ident.Name)]; ok {
+		return evaluateConstDecl(ctx, decl), true
+	}
+	if imported := findDotImport(ctx, ident.Name); imported != nil {
+		return importedConst(ctx, imported, ident.Name)
+	}
+	switch ident.Name {
+	case "true", "false":
+		return &constValue{constant.MakeBool(ident.Name == "true"), nil, untypedBoolType}, true
+	case "iota":
+		if ctx.Iota >= 0 {
+			return &constValue{constant.MakeInt64(int64(ctx.Iota)), nil, reflect.TypeOf(0)}, true
+		}
+	}
+	return nil, false
+}
+
+// Returns true if the name doesn't refer to a local variable or a package-level
+// function or variable, so it's either a constant or a predeclared name.
+func isPredeclared(ctx *CompileCtx, ident *ast.Ident) bool {
+	_, isLocal := ctx.ActiveVars[ident.Name]
+	return !isLocal && !ctx.Funcs[ident.Name] && !ctx.Package.Vars[ident.Name]
+}
+
+// Returns true if the package-level constant exists. Constants are keyed by
+// qualified name, like types.
+func isPackageConst(ctx *CompileCtx, pack *apast.Package, name string) bool {
+	_, ok := ctx.Consts[apast.QualifiedName(pack.Path, name)]
+	return ok
+}
+
+// Get an exported constant of an imported package, if it has one with the
+// given name.
+func importedConst(ctx *CompileCtx, imported *importedPackage, name string) (*constValue, bool) {
+	if !ast.IsExported(name) {
+		return nil, false
+	}
+	if imported.pack != nil {
+		decl, ok := ctx.Consts[apast.QualifiedName(imported.pack.Path, name)]
+		if !ok {
+			return nil, false
+		}
+		return evaluateConstDecl(ctx, decl), true
+	}
+	val, ok := imported.nativePackage.Consts[name]
+	if !ok {
+		return nil, false
+	}
+	rv := reflect.ValueOf(val)
+	result := &constValue{nil, nil, rv.Type()}
+	switch kind := rv.Kind(); {
+	case kind == reflect.Bool:
+		result.val = constant.MakeBool(rv.Bool())
+	case kind == reflect.String:
+		result.val = constant.MakeString(rv.String())
+	case isSignedKind(kind):
+		result.val = constant.MakeInt64(rv.Int())
+	case isUnsignedKind(kind):
+		result.val = constant.MakeUint64(rv.Uint())
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		result.val = constant.MakeFloat64(rv.Float())
+	case kind == reflect.Complex64 || kind == reflect.Complex128:
+		result.val = constant.BinaryOp(constant.MakeFloat64(real(rv.Complex())), token.ADD,
+			constant.MakeImag(constant.MakeFloat64(imag(rv.Complex()))))
+	default:
+		return nil, false
+	}
+	// Typed constants keep their type, which is either predeclared, like
+	// int64, or from a native package, like time.Duration.
+	if !imported.nativePackage.UntypedConsts[name] {
+		result.typ = &ast.Ident{Name: rv.Type().Name()}
+		if rv.Type().PkgPath() != "" {
+			result.typ = &ast.Ident{Name: apast.QualifiedName(rv.Type().PkgPath(), rv.Type().Name())}
+		}
+	}
+	return result, true
+}
+
+// Evaluate a binary operation on two constants. The result has the type of the
+// typed operand, if there is one, since the untyped operand is converted to
+// it. Comparisons always give an untyped bool.
+func binaryConst(op token.Token, x *constValue, y *constValue) *constValue {
+	switch op {
+	case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+		return &constValue{constant.MakeBool(constant.Compare(x.val, op, y.val)), nil, untypedBoolType}
+	case token.SHL, token.SHR:
+		shift, _ := constant.Uint64Val(constant.ToInt(y.val))
+		return &constValue{constant.Shift(constant.ToInt(x.val), op, uint(shift)), x.typ, x.goType}
+	}
+	result := x
+	if x.typ == nil && (y.typ != nil || untypedNumericRanks[y.goType] > untypedNumericRanks[x.goType]) {
+		result = y
+	}
+	if op == token.QUO && isIntegerKind(result.goType.Kind()) {
+		// QUO_ASSIGN is go/constant's integer division.
+		op = token.QUO_ASSIGN
+	}
+	return &constValue{constant.BinaryOp(x.val, op, y.val), result.typ, result.goType}
+}
+
+// Convert a constant to a type, like in `Celsius(100)`. Only types with a
+// basic underlying type, like numbers and strings, can have constant values.
+func convertConst(ctx *CompileCtx, c *constValue, t ast.Expr) (*constValue, bool) {
+	goType, ok := constGoType(ctx, t)
+	if !ok {
+		return nil, false
+	}
+	val := c.val
+	switch kind := goType.Kind(); {
+	case kind == reflect.String && val.Kind() == constant.Int:
+		// Converting an integer to a string gives the UTF-8 encoding
+		// of that rune.
+		code, _ := constant.Int64Val(val)
+		val = constant.MakeString(string(rune(code)))
+	case isIntegerKind(kind):
+		val = constant.ToInt(val)
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		val = constant.ToFloat(val)
+	case kind == reflect.Complex64 || kind == reflect.Complex128:
+		val = constant.ToComplex(val)
+	}
+	return &constValue{val, resolveTypeNames(ctx, t), goType}, true
+}
+
+// Get the type that constants of the given type have at runtime, or false if
+// constants can't have the type.
+func constGoType(ctx *CompileCtx, t ast.Expr) (reflect.Type, bool) {
+	if nativeType, ok := resolveNativeType(ctx, t); ok {
+		return nativeType, isBasicKind(nativeType.Kind())
+	}
+	if isTypeParam(ctx, t) {
+		return nil, false
+	}
+	underlying := underlyingType(ctx, t)
+	if nativeType, ok := resolveNativeType(ctx, underlying); ok {
+		return nativeType, isBasicKind(nativeType.Kind())
+	}
+	if ident, ok := underlying.(*ast.Ident); ok && apruntime.BasicTypes[ident.Name] != nil {
+		return apruntime.BasicTypes[ident.Name], true
+	}
+	return nil, false
+}
+
+// Compile a constant to a literal with its runtime value. Constants of declared
+// types are converted to that type, like `Celsius(100.0)`.
+func compileConst(ctx *CompileCtx, c *constValue) apast.Expr {
+	literal := &apast.LiteralExpr{
+		constToNative(c.val, c.goType),
+	}
+	if c.typ == nil {
+		return literal
+	}
+	if _, isDeclaredType := resolveTypeName(ctx, c.typ); isDeclaredType {
+		return &apast.ConversionExpr{
+			compileTypeExpr(ctx, c.typ),
+			literal,
+		}
+	}
+	return literal
+}
+
+// Get the value of a constant as a native value of the given type.
+func constToNative(val constant.Value, t reflect.Type) interface{} {
+	result := reflect.New(t).Elem()
+	switch kind := t.Kind(); {
+	case kind == reflect.Bool:
+		result.SetBool(constant.BoolVal(val))
+	case kind == reflect.String:
+		result.SetString(constant.StringVal(val))
+	case isSignedKind(kind):
+		n, _ := constant.Int64Val(constant.ToInt(val))
+		result.SetInt(n)
+	case isUnsignedKind(kind):
+		n, _ := constant.Uint64Val(constant.ToInt(val))
+		result.SetUint(n)
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		f, _ := constant.Float64Val(constant.ToFloat(val))
+		result.SetFloat(f)
+	case kind == reflect.Complex64 || kind == reflect.Complex128:
+		c := constant.ToComplex(val)
+		re, _ := constant.Float64Val(constant.Real(c))
+		im, _ := constant.Float64Val(constant.Imag(c))
+		result.SetComplex(complex(re, im))
+	}
+	return result.Interface()
+}
+
+// Get the value of a constant expression that has to be an int, like an array
+// length.
+func evalIntConst(ctx *CompileCtx, expr ast.Expr) int {
+	c, ok := evalConst(ctx, expr)
+	if !ok {
+		compileError(ctx, expr, fmt.Sprint(types.ExprString(expr), " is not a constant"))
+	}
+	n, ok := constant.Int64Val(constant.ToInt(c.val))
+	if !ok {
+		compileError(ctx, expr, fmt.Sprint(types.ExprString(expr), " is not an integer"))
+	}
+	return int(n)
+}
+
+// Make a literal for a constant, for type expressions that are stored for use
+// in other packages, like array lengths.
+func constLiteral(c *constValue) *ast.BasicLit {
+	return &ast.BasicLit{
+		Kind: token.INT,
+		Value: c.val.ExactString(),
+	}
+}
+
+func isBasicKind(kind reflect.Kind) bool {
+	return kind == reflect.Bool || kind == reflect.String || isIntegerKind(kind) ||
+		kind == reflect.Float32 || kind == reflect.Float64 ||
+		kind == reflect.Complex64 || kind == reflect.Complex128
+}
+
+func isIntegerKind(kind reflect.Kind) bool {
+	return isSignedKind(kind) || isUnsignedKind(kind)
+}
+
+func isSignedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnsignedKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,153 @@
+package apcompiler
+
+import (
+	"github.com/alangpierce/apgo/apast"
+	"reflect"
+)
+
+// Order the initializers of the package-level variables like Go does: each
+// step picks the earliest initializer, in declaration order, that doesn't
+// depend on a variable that is still uninitialized. Initializers depend on the
+// variables they refer to, including through the functions and methods they
+// refer to.
+func sortVarInits(pack *apast.Package) []apast.Stmt {
+	deps := [][]string{}
+	for _, varInit := range pack.VarInits {
+		finder := &depFinder{pack, make(map[string]bool), make(map[*apast.FuncDecl]bool)}
+		for _, rhs := range varInit.(*apast.AssignStmt).Rhs {
+			finder.visit(rhs)
+		}
+		initDeps := []string{}
+		for name := range finder.vars {
+			initDeps = append(initDeps, name)
+		}
+		deps = append(deps, initDeps)
+	}
+
+	remaining := []int{}
+	for i := range pack.VarInits {
+		remaining = append(remaining, i)
+	}
+	result := []apast.Stmt{}
+	for len(remaining) > 0 {
+		uninitialized := make(map[string]bool)
+		for _, i := range remaining {
+			for _, name := range initializedVars(pack.VarInits[i]) {
+				uninitialized[name] = true
+			}
+		}
+		// If every initializer is waiting on another one, there's an
+		// initialization cycle, which Go rejects. Declaration order is
+		// kept in that case.
+		next := 0
+		for j, i := range remaining {
+			if !dependsOnAny(deps[i], uninitialized) {
+				next = j
+				break
+			}
+		}
+		result = append(result, pack.VarInits[remaining[next]])
+		remaining = append(remaining[:next:next], remaining[next + 1:]...)
+	}
+	return result
+}
+
+func initializedVars(varInit apast.Stmt) []string {
+	names := []string{}
+	for _, lhs := range varInit.(*apast.AssignStmt).Lhs {
+		if ident, ok := lhs.(*apast.QualifiedIdentExpr); ok {
+			names = append(names, ident.Name)
+		}
+	}
+	return names
+}
+
+func dependsOnAny(deps []string, vars map[string]bool) bool {
+	for _, name := range deps {
+		if vars[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// Finds the package-level variables that code depends on.
+type depFinder struct {
+	pack *apast.Package
+	vars map[string]bool
+	// Functions whose bodies have been visited already, which also stops
+	// recursive functions from being visited forever.
+	visitedFuncs map[*apast.FuncDecl]bool
+}
+
+var (
+	exprType = reflect.TypeOf((*apast.Expr)(nil)).Elem()
+	stmtType = reflect.TypeOf((*apast.Stmt)(nil)).Elem()
+)
+
+// Visit a statement or expression and everything within it.
+func (finder *depFinder) visit(node interface{}) {
+	switch node := node.(type) {
+	case *apast.QualifiedIdentExpr:
+		if node.Package == finder.pack {
+			finder.vars[node.Name] = true
+		}
+		return
+	case *apast.IdentExpr:
+		// Locals never have the name of a package-level function,
+		// since the compiler renames them.
+		if fn, ok := finder.pack.Funcs[node.Name]; ok {
+			finder.visitFunc(fn)
+		}
+		return
+	case *apast.FieldAccessExpr:
+		finder.visitMethods(node.Name)
+	case *apast.MethodExpr:
+		finder.visitMethods(node.Name)
+	case *apast.FuncLitExpr:
+		finder.visitFunc(node.Func)
+		return
+	}
+	finder.visitFields(reflect.ValueOf(node).Elem())
+}
+
+func (finder *depFinder) visitFunc(fn *apast.FuncDecl) {
+	if !finder.visitedFuncs[fn] {
+		finder.visitedFuncs[fn] = true
+		finder.visitFields(reflect.ValueOf(fn).Elem())
+	}
+}
+
+// The type of the receiver isn't known at compile time, so a selector might
+// refer to a method with that name on any type declared in the package.
+func (finder *depFinder) visitMethods(name string) {
+	for _, typeDecl := range finder.pack.Types {
+		if method, ok := typeDecl.Methods[name]; ok && typeDecl.Package == finder.pack {
+			finder.visitFunc(method.Func)
+		}
+	}
+}
+
+// Visit the statements and expressions held by a node's fields, including
+// ones in slices and maps. Other fields, like the package of a
+// QualifiedIdentExpr, are skipped.
+func (finder *depFinder) visitFields(val reflect.Value) {
+	switch val.Kind() {
+	case reflect.Struct:
+		for i := 0; i < val.NumField(); i++ {
+			finder.visitFields(val.Field(i))
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			finder.visitFields(val.Index(i))
+		}
+	case reflect.Map:
+		for _, key := range val.MapKeys() {
+			finder.visitFields(val.MapIndex(key))
+		}
+	case reflect.Interface, reflect.Ptr:
+		if !val.IsNil() && (val.Type().Implements(exprType) || val.Type().Implements(stmtType)) {
+			finder.visit(val.Interface())
+		}
+	}
+}
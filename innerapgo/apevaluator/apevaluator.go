@@ -2,41 +2,277 @@ package apevaluator
 
 import (
 	"github.com/alangpierce/apgo/apast"
+	"github.com/alangpierce/apgo/apruntime"
 	"reflect"
 	"fmt"
+	"runtime"
+	"strings"
 )
 
 // Creates a Go function corresponding to the given function in the package.
-func CreatePackageFuncValue(pack *apast.Package, name string) Value {
+func CreatePackageFuncValue(globals *Globals, pack *apast.Package, name string) Value {
 	return &FunctionValue{
 		pack.Funcs[name],
 		make(map[string]Value),
+		nil,
+		nil,
+		globals,
 	}
 }
 
-func EvaluateFunc(pack *apast.Package, funcValue *FunctionValue, args []Value) []Value {
-	ctx := NewContext(pack)
-	for i, argName := range funcValue.FuncDecl.ParamNames {
-		ctx.assignValue(argName, args[i])
+// Initialize the package-level variables of the package, then run its init
+// functions. The packages it imports should already be initialized.
+func InitPackage(globals *Globals, pack *apast.Package) {
+	ctx := NewContext(globals, pack)
+	for _, varInit := range pack.VarInits {
+		EvaluateStmt(ctx, varInit)
+	}
+	for _, initFunc := range pack.InitFuncs {
+		EvaluateFunc(&FunctionValue{initFunc, make(map[string]Value), nil, nil, globals}, []Value{})
+	}
+}
+
+func EvaluateFunc(funcValue *FunctionValue, args []Value) []Value {
+	ctx := funcContext(funcValue)
+	funcDecl := funcValue.FuncDecl
+	// Converting to the declared types handles untyped constants, e.g.
+	// passing 1 as a float64.
+	for i, argName := range funcDecl.ParamNames {
+		ctx.assignValue(argName, convertValue(ctx, funcDecl.Type.ParamTypes[i], args[i].Copy()))
 	}
 	for name, val := range funcValue.BoundVariables {
 		ctx.assignValue(name, val)
 	}
-	EvaluateStmt(ctx, funcValue.FuncDecl.Body)
-	return ctx.returnValues
+	EvaluateStmt(ctx, funcDecl.Body)
+	results := ctx.returnValues
+	if len(results) == 0 && len(funcDecl.ResultNames) > 0 {
+		// A bare return with named results returns their current
+		// values.
+		for _, name := range funcDecl.ResultNames {
+			results = append(results, ctx.variableLValue(name).get())
+		}
+	}
+	for i, result := range results {
+		results[i] = convertValue(ctx, funcDecl.Type.ResultTypes[i], result)
+	}
+	return results
 }
 
 // Create an intermediate method function for the given method and receiver.
-func createMethodValue(pack *apast.Package, method *apast.MethodDecl, receiver Value) Value {
-	// Do a copy if we're using pass-by-value.
-	if !method.IsPointer {
-		receiver = receiver.Copy()
+// The receiver is evaluated now rather than when the method is called: value
+// receivers are copied, and pointer receivers get the address of the target,
+// like `(&x).Method` in Go.
+func createMethodValue(ctx *Context, method *apast.MethodDecl, target ExprResult) Value {
+	var receiver Value
+	if method.IsPointer {
+		if rvalue, ok := target.(*RValue); ok {
+			// Values that aren't addressable, like map elements,
+			// get a new location.
+			target = &AllocatedLValue{
+				rvalue.get(),
+			}
+		}
+		receiver = &PointerValue{
+			target,
+			nil,
+		}
+	} else {
+		receiver = target.get().Copy()
+	}
+	return bindMethod(ctx, method, receiver, getTypeArgs(target.get()))
+}
+
+// Create the function for a method with the given receiver. Methods of generic
+// types use the type arguments of the receiver's type.
+func bindMethod(ctx *Context, method *apast.MethodDecl, receiver Value, receiverTypeArgs []apast.Expr) Value {
+	var typeArgs map[string]apast.Expr
+	if len(method.ReceiverTypeParams) > 0 {
+		typeArgs = make(map[string]apast.Expr)
+		for i, typeArg := range receiverTypeArgs {
+			typeArgs[method.ReceiverTypeParams[i]] = typeArg
+		}
 	}
 	return &FunctionValue{
 		method.Func,
 		map[string]Value {
 			method.ReceiverName: receiver,
 		},
+		nil,
+		typeArgs,
+		ctx.Globals,
+	}
+}
+
+// Create the function for a method expression like `T.Method` or
+// `(*T).Method`, which takes the receiver as its first argument. The function
+// just calls the method on its first argument, so promoted methods, pointer
+// receivers, methods of native types and interface methods, which are looked up
+// on the dynamic value, work the same way as in normal method calls.
+func createMethodExprValue(ctx *Context, receiverType apast.Expr, name string) Value {
+	namedType := receiverType
+	if pointerType, ok := namedType.(*apast.PointerTypeExpr); ok {
+		namedType = pointerType.Elem
+	}
+	if nativeType, ok := resolveType(ctx, namedType).(*apast.NativeTypeExpr); ok {
+		return createNativeMethodExprValue(ctx, receiverType, nativeType.Type, name)
+	}
+	if interfaceType := getInterfaceType(ctx.Package, resolveType(ctx, receiverType)); interfaceType != nil {
+		return createInterfaceMethodExprValue(ctx, receiverType, interfaceType, name)
+	}
+	typeName, typeArgs, _, ok := lookupNamedType(ctx, namedType)
+	if !ok {
+		panic(fmt.Sprint("Method expressions are not supported on ", receiverType))
+	}
+	sel := findSelection(ctx.Package, typeName, name)
+	if sel != nil && sel.method == nil && sel.fieldName == "" {
+		// Promoted from an embedded native type.
+		if t := embeddedNativeType(ctx.Package, typeName, sel.path); t != nil {
+			return newMethodExprFunc(ctx, receiverType, name, nativeMethodType(t, name), nil)
+		}
+	}
+	if sel == nil || sel.method == nil {
+		panic(fmt.Sprint("Method not found: ", name))
+	}
+	// The method's signature may refer to the type parameters of the
+	// receiver type.
+	var methodTypeArgs map[string]apast.Expr
+	if len(sel.path) == 0 && len(sel.method.ReceiverTypeParams) > 0 {
+		methodTypeArgs = make(map[string]apast.Expr)
+		for i, typeArg := range typeArgs {
+			methodTypeArgs[sel.method.ReceiverTypeParams[i]] = typeArg
+		}
+	}
+	return newMethodExprFunc(ctx, receiverType, name, sel.method.Func.Type, methodTypeArgs)
+}
+
+// Create a method expression on an interpreted interface type, like
+// `Shape.Area`, which calls the method of whatever value it's given.
+func createInterfaceMethodExprValue(ctx *Context, receiverType apast.Expr,
+		interfaceType *apast.InterfaceTypeExpr, name string) Value {
+	methodType := getInterfaceMethodType(ctx.Package, interfaceType, name)
+	if methodType == nil {
+		panic(fmt.Sprint("Method not found: ", name))
+	}
+	// The method's signature may refer to the type parameters of a
+	// generic interface, like `Getter[int].Get`.
+	var typeArgMap map[string]apast.Expr
+	if _, typeArgs, typeDecl, ok := lookupNamedType(ctx, receiverType); ok && len(typeDecl.TypeParams) > 0 {
+		typeArgMap = make(map[string]apast.Expr)
+		for i, typeParam := range typeDecl.TypeParams {
+			typeArgMap[typeParam.Name] = typeArgs[i]
+		}
+	}
+	return newMethodExprFunc(ctx, receiverType, name, methodType, typeArgMap)
+}
+
+// Create a method expression on a native type, like `(*bytes.Buffer).Len` or
+// `io.Writer.Write`.
+func createNativeMethodExprValue(ctx *Context, receiverType apast.Expr, t reflect.Type, name string) Value {
+	if _, ok := receiverType.(*apast.PointerTypeExpr); ok {
+		t = reflect.PtrTo(t)
+	}
+	methodType := nativeMethodType(t, name)
+	if methodType == nil {
+		panic(fmt.Sprint("Method not found: ", name))
+	}
+	return newMethodExprFunc(ctx, &apast.NativeTypeExpr{t}, name, methodType, nil)
+}
+
+// Get the signature of a method of a native type from reflection, or nil if
+// the type doesn't have the method.
+func nativeMethodType(t reflect.Type, name string) *apast.FuncTypeExpr {
+	method, ok := t.MethodByName(name)
+	if !ok {
+		return nil
+	}
+	methodType := &apast.FuncTypeExpr{nil, nil, method.Type.IsVariadic()}
+	// Methods of concrete types take the receiver as their first
+	// argument, but interface methods don't.
+	firstParam := 1
+	if t.Kind() == reflect.Interface {
+		firstParam = 0
+	}
+	for i := firstParam; i < method.Type.NumIn(); i++ {
+		methodType.ParamTypes = append(methodType.ParamTypes, &apast.NativeTypeExpr{method.Type.In(i)})
+	}
+	for i := 0; i < method.Type.NumOut(); i++ {
+		methodType.ResultTypes = append(methodType.ResultTypes, &apast.NativeTypeExpr{method.Type.Out(i)})
+	}
+	return methodType
+}
+
+// Follow a path of embedded fields and get the type of the last one if it's
+// native. Embedded fields are addressable, so concrete types are returned as
+// pointers to include their pointer methods.
+func embeddedNativeType(pack *apast.Package, typeName string, path []string) reflect.Type {
+	for i, fieldName := range path {
+		structType := getStructType(pack, typeName)
+		if structType == nil {
+			return nil
+		}
+		for _, field := range structType.Fields {
+			if field.Name != fieldName {
+				continue
+			}
+			if i < len(path) - 1 {
+				typeName, _ = getEmbeddedTypeName(field.Type)
+				break
+			}
+			fieldType := field.Type
+			if pointerType, ok := fieldType.(*apast.PointerTypeExpr); ok {
+				fieldType = pointerType.Elem
+			}
+			nativeType, ok := fieldType.(*apast.NativeTypeExpr)
+			if !ok {
+				return nil
+			}
+			if nativeType.Type.Kind() == reflect.Interface {
+				return nativeType.Type
+			}
+			return reflect.PtrTo(nativeType.Type)
+		}
+	}
+	return nil
+}
+
+// Create a function that calls the method on its first argument.
+func newMethodExprFunc(ctx *Context, receiverType apast.Expr, name string,
+		methodType *apast.FuncTypeExpr, typeArgs map[string]apast.Expr) Value {
+	paramNames := []string{"recv"}
+	args := []apast.Expr{}
+	for i := range methodType.ParamTypes {
+		paramName := fmt.Sprint("arg", i)
+		paramNames = append(paramNames, paramName)
+		args = append(args, &apast.IdentExpr{paramName})
+	}
+	return &FunctionValue{
+		&apast.FuncDecl{
+			&apast.ReturnStmt{
+				[]apast.Expr{
+					&apast.FuncCallExpr{
+						&apast.FieldAccessExpr{
+							&apast.IdentExpr{"recv"},
+							name,
+						},
+						args,
+						methodType.IsVariadic,
+					},
+				},
+			},
+			paramNames,
+			nil,
+			&apast.FuncTypeExpr{
+				append([]apast.Expr{resolveType(ctx, receiverType)}, methodType.ParamTypes...),
+				methodType.ResultTypes,
+				methodType.IsVariadic,
+			},
+			nil,
+			ctx.Package,
+		},
+		make(map[string]Value),
+		nil,
+		typeArgs,
+		ctx.Globals,
 	}
 }
 
@@ -54,17 +290,39 @@ func EvaluateStmt(ctx *Context, stmt apast.Stmt) {
 			}
 		}
 	case *apast.AssignStmt:
-		if len(stmt.Lhs) != len(stmt.Rhs) {
-			panic("Multiple assign with differing lengths not implemented.")
+		// Like in Go, index expressions and pointer indirections on the
+		// left are evaluated first, then the right side, and then the
+		// assignments are carried out from left to right. This makes
+		// swaps like `a[i], a[j] = a[j], a[i]` work.
+		for _, name := range stmt.Declared {
+			ctx.declareVar(name)
+		}
+		lvalues := []ExprResult{}
+		for _, lhs := range stmt.Lhs {
+			if ident, ok := lhs.(*apast.IdentExpr); ok {
+				// Assigning to a name always means a variable,
+				// even if the variable shadows a function.
+				lvalues = append(lvalues, ctx.variableLValue(ident.Name))
+				continue
+			}
+			lvalues = append(lvalues, evaluateExpr(ctx, lhs))
 		}
-		values := []ExprResult{}
-		for _, rhsExpr := range stmt.Rhs {
-			values = append(values, evaluateExpr(ctx, rhsExpr))
+		values := evaluateExprList(ctx, stmt.Rhs)
+		if len(lvalues) != len(values) {
+			panic("Mismatched number of values in assignment.")
 		}
 		for i, value := range values {
-			lvalue := evaluateExpr(ctx, stmt.Lhs[i])
-			lvalue.set(value.get())
+			// Structs are values, so assignment copies them.
+			lvalues[i].set(value.Copy())
 		}
+	case *apast.OpAssignStmt:
+		lvalue := evaluateExpr(ctx, stmt.Lhs)
+		op := evaluateExpr(ctx, stmt.Op).get()
+		rhs := evaluateExpr(ctx, stmt.Rhs).get()
+		if isUntypedConstExpr(stmt.Rhs) {
+			rhs = convertUntypedOperand(rhs, lvalue.get())
+		}
+		lvalue.set(callFunc(ctx, op, []Value{lvalue.get(), rhs}, false)[0])
 	case *apast.EmptyStmt:
 		// Do nothing.
 	case *apast.IfStmt:
@@ -79,6 +337,13 @@ func EvaluateStmt(ctx *Context, stmt apast.Stmt) {
 	case *apast.ForStmt:
 		// TODO: Handle scopes properly, if necessary.
 		EvaluateStmt(ctx, stmt.Init)
+		// Like in Go, each iteration has its own copy of the variables
+		// declared by the init statement, so that function literals and
+		// pointers keep the values of their iteration.
+		var loopVars []string
+		if init, ok := stmt.Init.(*apast.AssignStmt); ok {
+			loopVars = init.Declared
+		}
 		for {
 			condValue := evaluateExpr(ctx, stmt.Cond)
 			if !condValue.get().AsNative().(bool) {
@@ -89,16 +354,15 @@ func EvaluateStmt(ctx *Context, stmt apast.Stmt) {
 				ctx.shouldBreak = false
 				break
 			}
+			for _, name := range loopVars {
+				ctx.declareVar(name)
+			}
 			EvaluateStmt(ctx, stmt.Post)
 		}
 	case *apast.BreakStmt:
 		ctx.shouldBreak = true
 	case *apast.ReturnStmt:
-		returnValues := []Value{}
-		for _, result := range stmt.Results {
-			returnValues = append(returnValues, evaluateExpr(ctx, result).get())
-		}
-		ctx.returnValues = returnValues
+		ctx.returnValues = evaluateExprList(ctx, stmt.Results)
 	default:
 		panic(fmt.Sprint("Statement eval not implemented: ", reflect.TypeOf(stmt)))
 	}
@@ -115,59 +379,80 @@ func evaluateExpr(ctx *Context, expr apast.Expr) ExprResult {
 			}
 		}
 
-		f := evaluateExpr(ctx, expr.Func).get()
-		args := []Value{}
-		for _, argExpr := range expr.Args {
-			args = append(args, evaluateExpr(ctx, argExpr).get())
-		}
-		if interpretedFunc, ok := f.(*FunctionValue); ok {
-			// TODO: Support multiple return values.
-			results := EvaluateFunc(ctx.Package, interpretedFunc, args)
-			if len(results) == 1 {
-				return &RValue{
-					results[0],
-				}
-			} else {
-				return &RValue{
-					&NativeValue{nil},
-				}
+		results := evaluateFuncCall(ctx, expr)
+		if len(results) == 1 {
+			return &RValue{
+				results[0],
 			}
-		} else if nativeFunc, ok := f.(*NativeValue); ok {
-			return evaluateNativeFunc(nativeFunc, args)
 		} else {
-			panic(fmt.Sprint("Unexpected function call on ", f))
+			return &RValue{
+				&NativeValue{nil},
+			}
 		}
 
 	case *apast.IdentExpr:
 		return ctx.resolveValue(expr.Name)
+	case *apast.QualifiedIdentExpr:
+		return ctx.resolvePackageMember(expr.Package, expr.Name)
 	case *apast.IndexExpr:
-		// TODO: Handle maps.
-		arrOrSlice := evaluateExpr(ctx, expr.E).get()
+		containerResult := evaluateExpr(ctx, expr.E)
+		containerVal := containerResult.get()
 		index := evaluateExpr(ctx, expr.Index).get()
-		return &ReflectValLValue{
-			reflect.ValueOf(arrOrSlice.AsNative()).Index(index.AsNative().(int)),
+		if pointerVal, ok := containerVal.(*PointerValue); ok {
+			// Indexing a pointer to an array indexes the array.
+			containerResult = pointerVal.deref()
+			containerVal = containerResult.get()
 		}
-	case *apast.FieldAccessExpr:
-		leftSide := evaluateExpr(ctx, expr.E)
-		if sv, ok := leftSide.get().(*StructValue); ok {
-			// If it matches a method name, resolve to a method.
-			// Otherwise, resolve to a struct field.
-			if typeDecl, ok := ctx.Package.Types[sv.TypeName]; ok {
-				if method, ok := typeDecl.Methods[expr.Name]; ok {
-					return &RValue{
-						createMethodValue(ctx.Package, method, sv),
-					}
+		if namedVal, ok := containerVal.(*NamedValue); ok {
+			containerVal = namedVal.Val
+		}
+		container := reflect.ValueOf(containerVal.AsNative())
+		if container.Kind() == reflect.Ptr {
+			container = container.Elem()
+		}
+		if container.Kind() == reflect.Map {
+			var elemZero func() Value
+			if expr.ElemZero != nil {
+				elemZero = func() Value {
+					return evaluateExpr(ctx, expr.ElemZero).get()
 				}
 			}
-			if _, ok := sv.Values[expr.Name]; ok {
-				return &StructLValue{
-					sv,
-					expr.Name,
-				}
+			return &MapLValue{
+				container,
+				toMapKey(index, container.Type().Key()),
+				elemZero,
 			}
-			panic(fmt.Sprint("Field not found: ", expr.Name))
-		} else {
-			panic(fmt.Sprint("Unsupported field access on ", leftSide.get()))
+		}
+		// Arrays are values, so their elements are only assignable
+		// through the location of the array itself.
+		if nativeVal, ok := containerVal.(*NativeValue); ok && container.Kind() == reflect.Array {
+			if addr, ok := addressNative(containerResult, nativeVal); ok {
+				container = addr
+			}
+		}
+		return &ReflectValLValue{
+			container.Index(toIndex(index)),
+		}
+	case *apast.FieldAccessExpr:
+		leftSide := evaluateExpr(ctx, expr.E)
+		return evaluateSelector(ctx, leftSide, expr.Name)
+	case *apast.FuncLitExpr:
+		capturedVars := make(map[string]*VariableLValue)
+		for _, name := range expr.CapturedVars {
+			capturedVars[name] = ctx.variableLValue(name)
+		}
+		return &RValue{
+			&FunctionValue{
+				expr.Func,
+				make(map[string]Value),
+				capturedVars,
+				ctx.TypeArgs,
+				ctx.Globals,
+			},
+		}
+	case *apast.MethodExpr:
+		return &RValue{
+			createMethodExprValue(ctx, expr.Type, expr.Name),
 		}
 	case *apast.LiteralExpr:
 		return &RValue{
@@ -176,11 +461,40 @@ func evaluateExpr(ctx *Context, expr apast.Expr) ExprResult {
 			},
 		}
 	case *apast.SliceLiteralExpr:
-		typ := evaluateType(expr.Type)
+		typ := evaluateType(ctx, expr.Type)
 		result := reflect.MakeSlice(
 			reflect.SliceOf(typ), len(expr.Vals), len(expr.Vals))
 		for i, val := range expr.Vals {
-			result.Index(i).Set(reflect.ValueOf(evaluateExpr(ctx, val).get().AsNative()))
+			// Elements may be untyped constants, like the 1 in
+			// []Celsius{1}.
+			elem := convertValue(ctx, expr.Type, evaluateExpr(ctx, val).get())
+			result.Index(i).Set(toNativeValue(ctx, elem, typ))
+		}
+		return &RValue{
+			&NativeValue{
+				result.Interface(),
+			},
+		}
+	case *apast.ArrayLiteralExpr:
+		typ := evaluateType(ctx, expr.Type)
+		result := reflect.New(reflect.ArrayOf(len(expr.Vals), typ)).Elem()
+		for i, val := range expr.Vals {
+			elem := convertValue(ctx, expr.Type, evaluateExpr(ctx, val).get())
+			result.Index(i).Set(toNativeValue(ctx, elem, typ))
+		}
+		return &RValue{
+			&NativeValue{
+				result.Interface(),
+			},
+		}
+	case *apast.MapLiteralExpr:
+		mapType := resolveTypeParam(ctx, expr.Type).(*apast.MapTypeExpr)
+		typ := evaluateType(ctx, mapType)
+		result := reflect.MakeMap(typ)
+		for i, key := range expr.Keys {
+			keyVal := convertValue(ctx, mapType.Key, evaluateExpr(ctx, key).get())
+			val := convertValue(ctx, mapType.Elem, evaluateExpr(ctx, expr.Vals[i]).get())
+			result.SetMapIndex(toMapKey(keyVal, typ.Key()), toNativeValue(ctx, val, typ.Elem()))
 		}
 		return &RValue{
 			&NativeValue{
@@ -190,6 +504,8 @@ func evaluateExpr(ctx *Context, expr apast.Expr) ExprResult {
 	case *apast.StructLiteralExpr:
 		structVal := &StructValue{
 			expr.TypeName,
+			resolveTypes(ctx, expr.TypeArgs),
+			expr.StructType,
 			make(map[string]Value),
 		}
 		// Populate the initial values, which should include setting
@@ -200,35 +516,687 @@ func evaluateExpr(ctx *Context, expr apast.Expr) ExprResult {
 		return &RValue{
 			structVal,
 		}
+	case *apast.NativeStructLiteralExpr:
+		result := reflect.New(expr.Type).Elem()
+		for name, valueExpr := range expr.InitialValues {
+			field := result.FieldByName(name)
+			field.Set(toNativeValue(ctx, evaluateExpr(ctx, valueExpr).get(), field.Type()))
+		}
+		return &RValue{
+			&NativeValue{
+				result.Interface(),
+			},
+		}
+	case *apast.ConversionExpr:
+		return &RValue{
+			convertValue(ctx, expr.Type, evaluateExpr(ctx, expr.E).get()),
+		}
+	case *apast.UntypedConstExpr:
+		// The conversion happens in the operator call; see
+		// convertUntypedOperand.
+		return evaluateExpr(ctx, expr.E)
+	case *apast.InstantiateExpr:
+		fn := evaluateExpr(ctx, expr.Func).get().(*FunctionValue)
+		typeArgs := make(map[string]apast.Expr)
+		for i, typeArg := range expr.TypeArgs {
+			typeArgs[fn.FuncDecl.TypeParams[i].Name] = resolveType(ctx, typeArg)
+		}
+		result := &FunctionValue{
+			fn.FuncDecl,
+			fn.BoundVariables,
+			fn.CapturedVars,
+			typeArgs,
+			fn.Globals,
+		}
+		if len(typeArgs) == len(fn.FuncDecl.TypeParams) {
+			checkTypeArgs(result)
+		}
+		return &RValue{
+			result,
+		}
+	case *apast.ZeroValueExpr:
+		return &RValue{
+			zeroValue(ctx, expr.Type),
+		}
+	case *apast.TypeAssertExpr:
+		val := evaluateExpr(ctx, expr.E).get()
+		if !hasType(ctx, val, expr.Type) {
+			panic(fmt.Sprint("interface conversion: interface {} is ", describeType(ctx.Package, typeOfValue(ctx, val)),
+				", not ", describeType(ctx.Package, resolveType(ctx, expr.Type))))
+		}
+		return &RValue{
+			val,
+		}
+	case *apast.AddressExpr:
+		target := evaluateExpr(ctx, expr.E)
+		if rvalue, ok := target.(*RValue); ok {
+			// Composite literals can have their address taken, so
+			// they need a new location.
+			target = &AllocatedLValue{
+				rvalue.get(),
+			}
+		}
+		return &RValue{
+			&PointerValue{
+				target,
+				nil,
+			},
+		}
+	case *apast.DerefExpr:
+		val := evaluateExpr(ctx, expr.E).get()
+		if pointer, ok := val.(*PointerValue); ok {
+			return pointer.deref()
+		}
+		if nativeVal, ok := val.(*NativeValue); ok {
+			// Native pointers, like pointers to native variables.
+			rv := reflect.ValueOf(nativeVal.AsNative())
+			if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+				return &ReflectValLValue{rv.Elem()}
+			}
+		}
+		panic("runtime error: invalid memory address or nil pointer dereference")
 	default:
 		panic(fmt.Sprint("Expression eval not implemented: ", reflect.TypeOf(expr)))
 	}
 }
 
-func evaluateType(expr apast.Expr) reflect.Type {
-	switch expr := expr.(type) {
+// Evaluate a function call, returning all of its results.
+func evaluateFuncCall(ctx *Context, expr *apast.FuncCallExpr) []Value {
+	f := evaluateExpr(ctx, expr.Func).get()
+	args := evaluateExprList(ctx, expr.Args)
+	// The compiler marks the arguments that are untyped constants, which
+	// take on the type of the parameter or operand.
+	isUntyped := make([]bool, len(args))
+	if len(args) == len(expr.Args) {
+		for i, argExpr := range expr.Args {
+			isUntyped[i] = isUntypedConstExpr(argExpr)
+		}
+	}
+	switch fn := f.(type) {
+	case *FunctionValue:
+		f = instantiateFunc(ctx, fn, args, isUntyped, expr.HasEllipsis)
+	case *NativeValue:
+		if !isOperator(fn.AsNative()) {
+			convertUntypedArgs(fn, args, isUntyped, expr.HasEllipsis)
+		} else if len(args) == 2 && isUntyped[0] {
+			args[0] = convertUntypedOperand(args[0], args[1])
+		} else if len(args) == 2 && isUntyped[1] {
+			args[1] = convertUntypedOperand(args[1], args[0])
+		}
+	}
+	return callFunc(ctx, f, args, expr.HasEllipsis)
+}
+
+// Convert an untyped constant operand to the type of the other operand, which
+// the compiler has checked isn't an interface. For example, the 2.0 in `n / 2.0`
+// becomes an int if n is an int, and the 9 in `c * 9` becomes a Celsius.
+func convertUntypedOperand(val Value, other Value) Value {
+	named, isNamed := other.(*NamedValue)
+	if isNamed {
+		other = named.Val
+	}
+	nativeOther, ok := other.(*NativeValue)
+	if !ok || nativeOther.val == nil {
+		return val
+	}
+	result := fromReflectValue(reflect.ValueOf(val.AsNative()).Convert(reflect.TypeOf(nativeOther.val)))
+	if isNamed {
+		return &NamedValue{
+			named.TypeName,
+			named.TypeArgs,
+			result,
+		}
+	}
+	return result
+}
+
+// Call an interpreted or native function, including operators, with
+// already-evaluated arguments.
+func callFunc(ctx *Context, f Value, args []Value, hasEllipsis bool) []Value {
+	// Values of named function types, like `type Handler func(int) int`,
+	// are called like the function they hold.
+	if named, ok := f.(*NamedValue); ok {
+		f = named.Val
+	}
+	if interpretedFunc, ok := f.(*FunctionValue); ok {
+		interpretedFunc = instantiateFunc(ctx, interpretedFunc, args, nil, hasEllipsis)
+		if !hasEllipsis {
+			args = packVariadicArgs(interpretedFunc, args)
+		}
+		return EvaluateFunc(interpretedFunc, args)
+	} else if nativeFunc, ok := f.(*NativeValue); ok {
+		if _, ok := nativeFunc.AsNative().(apruntime.EqualityOperator); ok {
+			args = toComparableOperands(args)
+		}
+		results := evaluateNativeFunc(ctx, nativeFunc, args, hasEllipsis)
+		switch nativeFunc.AsNative().(type) {
+		case apruntime.ArithmeticOperator, apruntime.UnaryArithmeticOperator:
+			results[0] = preserveNamedType(args, results[0])
+		}
+		return results
+	} else {
+		panic(fmt.Sprint("Unexpected function call on ", f))
+	}
+}
+
+// Evaluate a list of expressions, like the right side of an assignment or the
+// arguments of a function. A single function call with multiple results
+// provides all of its results, like in `x, y := f()` or `g(f())`.
+func evaluateExprList(ctx *Context, exprs []apast.Expr) []Value {
+	if len(exprs) == 1 {
+		if funcCall, ok := exprs[0].(*apast.FuncCallExpr); ok && resolveBuiltin(ctx, funcCall) == nil {
+			return evaluateFuncCall(ctx, funcCall)
+		}
+		if commaOk, ok := exprs[0].(*apast.CommaOkExpr); ok {
+			return evaluateCommaOk(ctx, commaOk)
+		}
+	}
+	values := []Value{}
+	for _, expr := range exprs {
+		values = append(values, evaluateExpr(ctx, expr).get())
+	}
+	return values
+}
+
+// Evaluate the two-value form of a type assertion or map index. A failed
+// assertion gives the zero value of the type rather than panicking.
+func evaluateCommaOk(ctx *Context, expr *apast.CommaOkExpr) []Value {
+	switch expr := expr.E.(type) {
+	case *apast.TypeAssertExpr:
+		val := evaluateExpr(ctx, expr.E).get()
+		if !hasType(ctx, val, expr.Type) {
+			return []Value{zeroValue(ctx, expr.Type), &NativeValue{false}}
+		}
+		return []Value{val, &NativeValue{true}}
+	case *apast.IndexExpr:
+		lvalue := evaluateExpr(ctx, expr).(*MapLValue)
+		return []Value{lvalue.get(), &NativeValue{lvalue.isPresent()}}
+	default:
+		panic(fmt.Sprint("Unexpected comma-ok expression: ", reflect.TypeOf(expr)))
+	}
+}
+
+// Arithmetic on values of a named type produces a value of the same type, but
+// operators are native functions that only see the underlying values, so the
+// type needs to be attached to the result again.
+func preserveNamedType(args []Value, result Value) Value {
+	for _, arg := range args {
+		if namedArg, ok := arg.(*NamedValue); ok {
+			return &NamedValue{
+				namedArg.TypeName,
+				namedArg.TypeArgs,
+				result,
+			}
+		}
+	}
+	return result
+}
+
+// Returns true if the value has the given type, for the purpose of a type
+// assertion. For interfaces, this means that the value implements the
+// interface.
+func hasType(ctx *Context, val Value, typeExpr apast.Expr) bool {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if interfaceType := getInterfaceType(ctx.Package, typeExpr); interfaceType != nil {
+		return !isNil(val) && implementsInterface(ctx.Package, val, interfaceType)
+	}
+	if typeName, typeArgs, _, ok := lookupNamedType(ctx, typeExpr); ok {
+		valTypeName, ok := getTypeName(val)
+		return ok && valTypeName == typeName && typeListsEqual(getTypeArgs(val), typeArgs)
+	}
+	switch typeExpr := typeExpr.(type) {
+	case *apast.PointerTypeExpr:
+		if pointer, ok := val.(*PointerValue); ok && pointer.Target == nil {
+			return typeExprsEqual(pointer.Elem, resolveType(ctx, typeExpr.Elem))
+		} else if ok {
+			return hasType(ctx, pointer.Target.get(), typeExpr.Elem)
+		}
+	}
+	if _, ok := getTypeName(val); ok {
+		return false
+	}
+	if _, ok := val.(*NativeValue); !ok || isNil(val) {
+		return false
+	}
+	return reflect.TypeOf(val.AsNative()) == evaluateType(ctx, typeExpr)
+}
+
+// Convert the value to the given type, as in `T(val)`.
+func convertValue(ctx *Context, typeExpr apast.Expr, val Value) Value {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if getInterfaceType(ctx.Package, typeExpr) != nil {
+		// Interface values just hold the underlying value, which is
+		// copied like in any other conversion.
+		return val.Copy()
+	}
+	if typeName, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		if sv, ok := val.(*StructValue); ok {
+			result := sv.Copy().(*StructValue)
+			result.TypeName = typeName
+			result.TypeArgs = typeArgs
+			return result
+		}
+		if namedVal, ok := val.(*NamedValue); ok {
+			val = namedVal.Val
+		}
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		underlyingVal := convertValue(typeCtx, typeDecl.Underlying, val)
+		if namedVal, ok := underlyingVal.(*NamedValue); ok {
+			// The underlying type was itself a named type.
+			underlyingVal = namedVal.Val
+		}
+		return &NamedValue{
+			typeName,
+			typeArgs,
+			underlyingVal,
+		}
+	}
+	typ := evaluateType(ctx, typeExpr)
+	if pointerType, ok := typeExpr.(*apast.PointerTypeExpr); ok && typ == valueType && isNil(val) {
+		return nilPointer(ctx, pointerType)
+	}
+	if typ == valueType {
+		// Pointers, functions, etc. are kept as interpreted values.
+		return val.Copy()
+	}
+	if fn, ok := val.(*FunctionValue); ok && typ.Kind() == reflect.Func {
+		return &NativeValue{makeNativeFunc(fn, typ).Interface()}
+	}
+	native := val.AsNative()
+	if native == nil {
+		return fromReflectValue(reflect.Zero(typ))
+	}
+	return fromReflectValue(reflect.ValueOf(native).Convert(typ))
+}
+
+// Get the value of an index as an int. Indices can have any integer type, like
+// a byte.
+func toIndex(index Value) int {
+	return int(reflect.ValueOf(index.AsNative()).Convert(intType).Int())
+}
+
+func isUntypedConstExpr(expr apast.Expr) bool {
+	_, ok := expr.(*apast.UntypedConstExpr)
+	return ok
+}
+
+// Get the zero value of a type at runtime. Zero values are usually computed at
+// compile time, but this is needed for types involving type parameters.
+func zeroValue(ctx *Context, typeExpr apast.Expr) Value {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if getInterfaceType(ctx.Package, typeExpr) != nil {
+		return &NativeValue{nil}
+	}
+	if typeName, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		switch underlyingVal := zeroValue(typeCtx, typeDecl.Underlying).(type) {
+		case *StructValue:
+			underlyingVal.TypeName = typeName
+			underlyingVal.TypeArgs = typeArgs
+			return underlyingVal
+		case *NamedValue:
+			// The underlying type was itself a named type.
+			return &NamedValue{typeName, typeArgs, underlyingVal.Val}
+		default:
+			return &NamedValue{typeName, typeArgs, underlyingVal}
+		}
+	}
+	if structType, ok := typeExpr.(*apast.StructTypeExpr); ok {
+		structVal := &StructValue{
+			"",
+			nil,
+			structType,
+			make(map[string]Value),
+		}
+		for _, field := range structType.Fields {
+			structVal.Values[field.Name] = zeroValue(ctx, field.Type)
+		}
+		return structVal
+	}
+	if pointerType, ok := typeExpr.(*apast.PointerTypeExpr); ok {
+		return nilPointer(ctx, pointerType)
+	}
+	typ := evaluateType(ctx, typeExpr)
+	if typ == valueType {
+		// Functions, etc.
+		return &NativeValue{nil}
+	}
+	zero := reflect.New(typ).Elem()
+	if arrayType, ok := typeExpr.(*apast.ArrayTypeExpr); ok && typ.Elem() == valueType {
+		for i := 0; i < typ.Len(); i++ {
+			zero.Index(i).Set(toReflectValue(zeroValue(ctx, arrayType.Elem), valueType))
+		}
+	}
+	return fromReflectValue(zero)
+}
+
+// Get a nil pointer of the given type. Pointers to interpreted values keep the
+// type they point to, so that methods can be called on nil pointers.
+func nilPointer(ctx *Context, pointerType *apast.PointerTypeExpr) Value {
+	if evaluateType(ctx, pointerType) != valueType {
+		return &NativeValue{nil}
+	}
+	return &PointerValue{nil, resolveType(ctx, pointerType.Elem)}
+}
+
+func evaluateType(ctx *Context, expr apast.Expr) reflect.Type {
+	switch expr := resolveTypeParam(ctx, expr).(type) {
 	case *apast.IdentExpr:
-		if expr.Name == "int" {
-			return reflect.TypeOf(0)
+		if _, ok := ctx.Package.Types[expr.Name]; ok {
+			return valueType
+		} else if _, ok := predeclaredInterfaces[expr.Name]; ok {
+			return valueType
+		} else if basicType, ok := apruntime.BasicTypes[expr.Name]; ok {
+			return basicType
 		} else {
 			panic(fmt.Sprint("Type not implemented: ", expr.Name))
 		}
+	case *apast.SliceTypeExpr:
+		return reflect.SliceOf(evaluateType(ctx, expr.Elem))
+	case *apast.ArrayTypeExpr:
+		return reflect.ArrayOf(expr.Len, evaluateType(ctx, expr.Elem))
+	case *apast.MapTypeExpr:
+		keyType := evaluateType(ctx, expr.Key)
+		if containsValueType(keyType) {
+			// Keys containing interpreted values, including arrays
+			// of them, are stored as comparable keys; see toMapKey.
+			keyType = interfaceType
+		}
+		return reflect.MapOf(keyType, evaluateType(ctx, expr.Elem))
+	case *apast.NativeTypeExpr:
+		return expr.Type
+	case *apast.PointerTypeExpr:
+		// Pointers to types from native packages, like *bytes.Buffer,
+		// are native pointers so that they can be passed to native code.
+		if elem, ok := resolveTypeParam(ctx, expr.Elem).(*apast.NativeTypeExpr); ok && elem.Type.PkgPath() != "" {
+			return reflect.PtrTo(elem.Type)
+		}
+		return valueType
+	case *apast.InterfaceTypeExpr, *apast.FuncTypeExpr,
+			*apast.InstantiatedTypeExpr, *apast.StructTypeExpr:
+		return valueType
 	default:
 		panic(fmt.Sprint("Type expression not implemented: ", reflect.TypeOf(expr)))
 	}
 }
 
-func evaluateNativeFunc(nativeFunc *NativeValue, args []Value) ExprResult {
-	argVals := []reflect.Value{}
-	for _, arg := range args {
-		argVals = append(argVals, reflect.ValueOf(arg.AsNative()))
+// Pack any variadic arguments into a slice, like Go does. This is skipped when
+// a slice is passed directly, as in `f(nums...)`.
+func packVariadicArgs(fn *FunctionValue, args []Value) []Value {
+	funcDecl := fn.FuncDecl
+	if !funcDecl.Type.IsVariadic {
+		return args
 	}
-	resultVals := reflect.ValueOf(nativeFunc.AsNative()).Call(argVals)
-	if len(resultVals) == 1 {
-		return &RValue{
-			&NativeValue{resultVals[0].Interface()},
+	numFixed := len(funcDecl.ParamNames) - 1
+	sliceType := evaluateType(funcContext(fn), funcDecl.Type.ParamTypes[numFixed])
+	// With no variadic arguments, the slice is nil.
+	slice := reflect.Zero(sliceType)
+	if len(args) > numFixed {
+		numVariadic := len(args) - numFixed
+		slice = reflect.MakeSlice(sliceType, numVariadic, numVariadic)
+		for i, arg := range args[numFixed:] {
+			slice.Index(i).Set(toReflectValue(arg, sliceType.Elem()))
 		}
+	}
+	return append(args[:numFixed:numFixed], &NativeValue{slice.Interface()})
+}
+
+// Convert a slice value to the given native slice type, converting elements if
+// necessary, e.g. from []Value to []interface{}.
+func convertSliceToNative(ctx *Context, slice Value, sliceType reflect.Type) reflect.Value {
+	sliceVal := reflect.ValueOf(slice.AsNative())
+	if !sliceVal.IsValid() {
+		return reflect.Zero(sliceType)
+	}
+	if sliceVal.Type().AssignableTo(sliceType) {
+		return sliceVal
+	}
+	result := reflect.MakeSlice(sliceType, sliceVal.Len(), sliceVal.Len())
+	for i := 0; i < sliceVal.Len(); i++ {
+		elem := fromReflectValue(sliceVal.Index(i))
+		result.Index(i).Set(toNativeValue(ctx, elem, sliceType.Elem()))
+	}
+	return result
+}
+
+// Replace a slice passed with an ellipsis, like `f(args...)`, with its elements.
+func spreadArgs(args []Value) []Value {
+	lastIndex := len(args) - 1
+	result := args[:lastIndex:lastIndex]
+	slice := reflect.ValueOf(args[lastIndex].AsNative())
+	for i := 0; slice.IsValid() && i < slice.Len(); i++ {
+		result = append(result, fromReflectValue(slice.Index(i)))
+	}
+	return result
+}
+
+func isOperator(f interface{}) bool {
+	switch f.(type) {
+	case apruntime.ArithmeticOperator, apruntime.UnaryArithmeticOperator,
+			apruntime.ComparisonOperator, apruntime.EqualityOperator:
+		return true
+	default:
+		return false
+	}
+}
+
+// Get the type of the native parameter that the argument at the given index is
+// passed to, accounting for variadic functions.
+func getNativeParamType(funcType reflect.Type, index int, hasEllipsis bool) reflect.Type {
+	if funcType.IsVariadic() && !hasEllipsis && index >= funcType.NumIn() - 1 {
+		return funcType.In(funcType.NumIn() - 1).Elem()
+	}
+	return funcType.In(index)
+}
+
+// Convert the untyped constant arguments of a native function to the types of
+// the parameters they're passed to. Untyped constants are represented with their
+// default type, so e.g. the 100 in `time.Sleep(100)` is an int that needs to
+// become a time.Duration. Constants passed as interfaces keep their default
+// type, like they do in Go.
+func convertUntypedArgs(nativeFunc *NativeValue, args []Value, isUntyped []bool, hasEllipsis bool) {
+	funcVal := reflect.ValueOf(nativeFunc.AsNative())
+	funcType := funcVal.Type()
+	for i, arg := range args {
+		// Extra arguments are reported by checkNativeArgCount.
+		if !isUntyped[i] || (i >= funcType.NumIn() && !funcType.IsVariadic()) {
+			continue
+		}
+		paramType := getNativeParamType(funcType, i, hasEllipsis)
+		argVal := reflect.ValueOf(arg.AsNative())
+		if paramType.Kind() == reflect.Interface || argVal.Type().AssignableTo(paramType) {
+			continue
+		}
+		// The constant has to be representable in the parameter type,
+		// e.g. 2.5 can't be passed as an int, and -1 can't be passed as
+		// a uint.
+		if (isNumericType(paramType) || paramType.Kind() == argVal.Kind()) && argVal.Type().ConvertibleTo(paramType) &&
+				!(isUnsignedType(paramType) && isNegative(argVal)) {
+			result := argVal.Convert(paramType)
+			if result.Convert(argVal.Type()).Interface() == argVal.Interface() {
+				args[i] = fromReflectValue(result)
+				continue
+			}
+		}
+		panicCannotUseArg(argVal, paramType, funcVal)
+	}
+}
+
+// Check an argument against the type of the native parameter it's passed to.
+// Like in Go, values are only passed if they're assignable to the parameter
+// type; untyped constants have already been converted by convertUntypedArgs.
+func toNativeArg(argVal reflect.Value, paramType reflect.Type, funcVal reflect.Value) reflect.Value {
+	if !argVal.IsValid() {
+		// Untyped nil needs to be given a type.
+		switch paramType.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+			return reflect.Zero(paramType)
+		}
+		panic(fmt.Sprint("runtime error: cannot use nil as type ", paramType,
+			" in argument to ", nativeFuncName(funcVal)))
+	}
+	if argVal.Type().AssignableTo(paramType) {
+		return argVal
+	}
+	panicCannotUseArg(argVal, paramType, funcVal)
+	return reflect.Value{}
+}
+
+func panicCannotUseArg(argVal reflect.Value, paramType reflect.Type, funcVal reflect.Value) {
+	panic(fmt.Sprint("runtime error: cannot use ", argVal.Interface(), " (type ", argVal.Type(),
+		") as type ", paramType, " in argument to ", nativeFuncName(funcVal)))
+}
+
+func isNumericType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	default:
+		return isUnsignedType(t)
+	}
+}
+
+func isUnsignedType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// Negative constants wrap around when converted to unsigned types, so they'd
+// otherwise survive the representability check.
+func isNegative(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int() < 0
+	case reflect.Float32, reflect.Float64:
+		return val.Float() < 0
+	default:
+		return false
+	}
+}
+
+// Check that a native function is called with the right number of arguments.
+func checkNativeArgCount(funcVal reflect.Value, numArgs int, hasEllipsis bool) {
+	numIn := funcVal.Type().NumIn()
+	if funcVal.Type().IsVariadic() && !hasEllipsis {
+		numIn--
+		if numArgs > numIn {
+			return
+		}
+	}
+	if numArgs < numIn {
+		panic(fmt.Sprint("runtime error: not enough arguments in call to ", nativeFuncName(funcVal)))
+	} else if numArgs > numIn {
+		panic(fmt.Sprint("runtime error: too many arguments in call to ", nativeFuncName(funcVal)))
+	}
+}
+
+// Get the name of a native function for error messages, like `strconv.Itoa`.
+func nativeFuncName(funcVal reflect.Value) string {
+	fn := runtime.FuncForPC(funcVal.Pointer())
+	if fn == nil {
+		return funcVal.Type().String()
+	}
+	// Method values have a -fm suffix.
+	return strings.TrimSuffix(fn.Name(), "-fm")
+}
+
+// Functions like sort.Slice swap the elements of the slice they're given, and
+// the less function passed with it compares elements of the original slice, so
+// the slice isn't materialized.
+var inPlaceSliceFuncs = map[string]bool{
+	"sort.Slice": true,
+	"sort.SliceStable": true,
+	"sort.SliceIsSorted": true,
+}
+
+func swapsInPlace(funcVal reflect.Value) bool {
+	return inPlaceSliceFuncs[nativeFuncName(funcVal)]
+}
+
+func evaluateNativeFunc(ctx *Context, nativeFunc *NativeValue, args []Value, hasEllipsis bool) []Value {
+	funcVal := reflect.ValueOf(nativeFunc.AsNative())
+	funcType := funcVal.Type()
+	// Operators work on the underlying values of interpreted types, so
+	// their operands shouldn't be proxies.
+	useProxies := !isOperator(nativeFunc.AsNative())
+	if isErrorsAs(funcVal) && len(args) == 2 {
+		if target, ok := args[1].(*PointerValue); ok {
+			return []Value{evaluateErrorsAs(ctx, args[0], target)}
+		}
+	}
+	// fmt's print functions print interpreted values through proxies (see
+	// format.go), so spread arguments are passed individually.
+	formatting := isFormatFunc(funcVal)
+	if formatting && hasEllipsis {
+		args = spreadArgs(args)
+		hasEllipsis = false
+	}
+	checkNativeArgCount(funcVal, len(args), hasEllipsis)
+	argVals := []reflect.Value{}
+	// Native code may write through pointers to materialized values, like
+	// json.Unmarshal does, so those changes are copied back after the call.
+	writeBacks := []func(){}
+	for i, arg := range args {
+		paramType := getNativeParamType(funcType, i, hasEllipsis)
+		if fn, ok := arg.(*FunctionValue); ok && paramType.Kind() == reflect.Func {
+			argVals = append(argVals, makeNativeFunc(fn, paramType))
+			continue
+		}
+		if formatting && paramType == interfaceType && isInterpretedValue(arg) {
+			argVals = append(argVals, reflect.ValueOf(newFormatProxy(ctx, arg)))
+			continue
+		}
+		if paramType.Kind() == reflect.Interface && useProxies {
+			if proxy, ok := makeProxy(ctx, arg, paramType); ok {
+				argVals = append(argVals, reflect.ValueOf(proxy))
+				continue
+			}
+		}
+		// The slice passed with an ellipsis is converted below.
+		isSpread := hasEllipsis && i == len(args) - 1
+		if useProxies && !isSpread && !swapsInPlace(funcVal) && needsMaterializing(arg) {
+			argVal := materializeValue(ctx, arg)
+			if pointer, ok := arg.(*PointerValue); ok {
+				writeBacks = append(writeBacks, func() {
+					fromNative(ctx, typeOfValue(ctx, pointer), argVal, pointer)
+				})
+			}
+			argVals = append(argVals, toNativeArg(argVal, paramType, funcVal))
+			continue
+		}
+		argVal := reflect.ValueOf(arg.AsNative())
+		if !isSpread {
+			argVal = toNativeArg(argVal, paramType, funcVal)
+		}
+		argVals = append(argVals, argVal)
+	}
+	if formatIndex := funcType.NumIn() - 2; formatting && formatIndex >= 0 &&
+			funcType.In(formatIndex).Kind() == reflect.String {
+		argVals = append(argVals[:formatIndex:formatIndex],
+			rewriteFormatVerbs(ctx, args[formatIndex:], argVals[formatIndex:])...)
+	}
+	var resultVals []reflect.Value
+	if hasEllipsis {
+		// The slice might hold interpreted values, so convert it to the
+		// type the native function expects.
+		lastIndex := len(argVals) - 1
+		sliceType := funcType.In(funcType.NumIn() - 1)
+		argVals[lastIndex] = convertSliceToNative(ctx, args[lastIndex], sliceType)
+		resultVals = funcVal.CallSlice(argVals)
 	} else {
-		return &RValue{nil}
+		resultVals = funcVal.Call(argVals)
+	}
+	for _, writeBack := range writeBacks {
+		writeBack()
+	}
+	results := []Value{}
+	for _, resultVal := range resultVals {
+		results = append(results, fromReflectValue(resultVal))
 	}
+	return results
 }
\ No newline at end of file
@@ -1,7 +1,9 @@
 package apevaluator
 
 import (
+	"fmt"
 	"github.com/alangpierce/apgo/apast"
+	"reflect"
 )
 
 func panicBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
@@ -11,6 +13,116 @@ func panicBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
 	return &NativeValue{nil}
 }
 
+func lenBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
+	arg := evaluateExpr(ctx, funcCall.Args[0]).get()
+	native := arg.AsNative()
+	if native == nil {
+		return &NativeValue{0}
+	}
+	return &NativeValue{reflect.ValueOf(native).Len()}
+}
+
+func capBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
+	arg := evaluateExpr(ctx, funcCall.Args[0]).get()
+	native := arg.AsNative()
+	if native == nil {
+		return &NativeValue{0}
+	}
+	return &NativeValue{reflect.ValueOf(native).Cap()}
+}
+
+// The first argument of make is a type, which may be a named type like
+// `make(Set[int])`, in which case the result is converted to that type.
+func makeBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
+	typeExpr := funcCall.Args[0]
+	sizes := []int{}
+	for _, sizeExpr := range funcCall.Args[1:] {
+		size := evaluateExpr(ctx, sizeExpr).get().AsNative()
+		sizes = append(sizes, int(reflect.ValueOf(size).Convert(intType).Int()))
+	}
+	if _, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		underlyingVal := makeValue(typeCtx, typeDecl.Underlying, sizes)
+		return convertValue(ctx, typeExpr, underlyingVal)
+	}
+	return makeValue(ctx, typeExpr, sizes)
+}
+
+var intType = reflect.TypeOf(0)
+
+func makeValue(ctx *Context, typeExpr apast.Expr, sizes []int) Value {
+	typ := evaluateType(ctx, typeExpr)
+	switch typ.Kind() {
+	case reflect.Slice:
+		length, capacity := sizes[0], sizes[0]
+		if len(sizes) > 1 {
+			capacity = sizes[1]
+		}
+		result := reflect.MakeSlice(typ, length, capacity)
+		if typ.Elem() == valueType {
+			// Interpreted zero values need to be filled in.
+			elemType := resolveTypeParam(ctx, typeExpr).(*apast.SliceTypeExpr).Elem
+			for i := 0; i < length; i++ {
+				result.Index(i).Set(toReflectValue(zeroValue(ctx, elemType), valueType))
+			}
+		}
+		return &NativeValue{result.Interface()}
+	case reflect.Map:
+		return &NativeValue{reflect.MakeMap(typ).Interface()}
+	default:
+		panic(fmt.Sprint("Cannot make value of type ", typ))
+	}
+}
+
+func newBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
+	return &PointerValue{
+		&AllocatedLValue{
+			zeroValue(ctx, funcCall.Args[0]),
+		},
+		nil,
+	}
+}
+
+func appendBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
+	args := evaluateExprList(ctx, funcCall.Args)
+	slice := args[0]
+	namedSlice, isNamed := slice.(*NamedValue)
+	if isNamed {
+		slice = namedSlice.Val
+	}
+	sliceVal := reflect.ValueOf(slice.AsNative())
+	if funcCall.HasEllipsis && sliceVal.Type().Elem() != valueType {
+		sliceVal = reflect.AppendSlice(sliceVal, convertSliceToNative(ctx, args[1], sliceVal.Type()))
+	} else {
+		elems := args[1:]
+		if funcCall.HasEllipsis {
+			elems = spreadArgs(elems)
+		}
+		for _, elem := range elems {
+			// Like assignment, appending copies structs.
+			sliceVal = reflect.Append(sliceVal, toNativeValue(ctx, elem.Copy(), sliceVal.Type().Elem()))
+		}
+	}
+	var result Value = &NativeValue{sliceVal.Interface()}
+	if isNamed {
+		result = &NamedValue{
+			namedSlice.TypeName,
+			namedSlice.TypeArgs,
+			result,
+		}
+	}
+	return result
+}
+
+func deleteBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) Value {
+	mapVal := reflect.ValueOf(evaluateExpr(ctx, funcCall.Args[0]).get().AsNative())
+	key := evaluateExpr(ctx, funcCall.Args[1]).get()
+	if mapVal.IsValid() {
+		mapVal.SetMapIndex(toMapKey(key, mapVal.Type().Key()), reflect.Value{})
+	}
+	return &NativeValue{nil}
+}
+
 type BuiltinFunc func(ctx *Context, funcCall *apast.FuncCallExpr) Value
 
 var builtins map[string]BuiltinFunc
@@ -18,27 +130,28 @@ func init() {
 	// Lazy-init to avoid a circular init loop.
 	builtins = map[string]BuiltinFunc{
 		"panic": panicBuiltin,
+		"len": lenBuiltin,
+		"cap": capBuiltin,
+		"make": makeBuiltin,
+		"new": newBuiltin,
+		"append": appendBuiltin,
+		"delete": deleteBuiltin,
 	}
 }
 
-// Builtins skip the normal evaluation step and are handled specially.
+// Builtins skip the normal evaluation step and are handled specially. The
+// compiler has already resolved names, so builtins that are shadowed by other
+// declarations are plain identifiers.
 func resolveBuiltin(ctx *Context, funcCall *apast.FuncCallExpr) func() Value {
-	switch funcExpr := funcCall.Func.(type) {
-	case *apast.IdentExpr:
-		if ctx.isNameValid(funcExpr.Name) {
-			// If this is the name of a builtin, it's shadowed by a
-			// user-defined name, so don't consider it to be a
-			// builtin.
-			// TODO: Consider cleaning this up and putting builtin
-			// resolution as part of normal name resolution.
-			return nil
-		}
-		builtin := builtins[funcExpr.Name]
-		if builtin != nil {
-			return func() Value {
-				return builtin(ctx, funcCall)
-			}
-		}
+	funcExpr, ok := funcCall.Func.(*apast.BuiltinExpr)
+	if !ok {
+		return nil
+	}
+	builtin := builtins[funcExpr.Name]
+	if builtin == nil {
+		panic(fmt.Sprint("Builtin not implemented: ", funcExpr.Name))
+	}
+	return func() Value {
+		return builtin(ctx, funcCall)
 	}
-	return nil
 }
\ No newline at end of file
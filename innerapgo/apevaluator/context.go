@@ -1,12 +1,18 @@
 package apevaluator
 
 import (
+	"fmt"
 	"github.com/alangpierce/apgo/apast"
 )
 
 type Context struct {
 	Locals map[string]Value
 	Package *apast.Package
+	// The package-level variables of the program.
+	Globals *Globals
+	// Type arguments for the type parameters in scope, or nil if there
+	// aren't any.
+	TypeArgs map[string]apast.Expr
 	// Slice of return values, or nil if the function hasn't returned yet.
 	// This is used both for the values themselves and to communicate
 	// control flow. For example, a function returning nothing should have
@@ -14,48 +20,107 @@ type Context struct {
 	// other code that we want to finish the function now.
 	returnValues []Value
 	shouldBreak bool
+	// LValues for local variables, which are reused so that pointers to the
+	// same variable point to the same location.
+	variables map[string]*VariableLValue
 }
 
 type MethodSet struct {
 	Methods map[string]*apast.MethodDecl
 }
 
-func NewContext(pack *apast.Package) *Context {
+func NewContext(globals *Globals, pack *apast.Package) *Context {
 	return &Context{
 		Locals: make(map[string]Value),
 		Package: pack,
+		Globals: globals,
+		variables: make(map[string]*VariableLValue),
 	}
 }
 
 func (ctx *Context) resolveValue(name string) ExprResult {
-	if _, ok := ctx.Locals[name]; ok {
-		return &VariableLValue{
-			ctx.Locals,
-			name,
-		}
+	// Variables captured by a function literal are only in variables.
+	if lvalue, ok := ctx.variables[name]; ok {
+		return lvalue
+	} else if _, ok := ctx.Locals[name]; ok {
+		return ctx.variableLValue(name)
 	} else if _, ok := ctx.Package.Funcs[name]; ok {
 		return &RValue{
-			CreatePackageFuncValue(ctx.Package, name),
+			CreatePackageFuncValue(ctx.Globals, ctx.Package, name),
+		}
+	}
+	// The compiler only allows declared names, so this shouldn't happen.
+	panic(fmt.Sprint("undefined: ", name))
+}
+
+// The package-level variables of a package, along with lvalues for them, which
+// are reused like the ones in Context.variables.
+type packageVars struct {
+	values map[string]Value
+	lvalues map[string]*VariableLValue
+}
+
+// The package-level variables of every package in a program. Each interpreter
+// has its own, so programs don't share variables.
+type Globals struct {
+	packages map[*apast.Package]*packageVars
+}
+
+func NewGlobals() *Globals {
+	return &Globals{
+		make(map[*apast.Package]*packageVars),
+	}
+}
+
+// Resolve a package-level function or variable, which may be in another
+// package than the current one.
+func (ctx *Context) resolvePackageMember(pack *apast.Package, name string) ExprResult {
+	if _, ok := pack.Funcs[name]; ok {
+		return &RValue{
+			CreatePackageFuncValue(ctx.Globals, pack, name),
+		}
+	} else if !pack.Vars[name] {
+		panic(fmt.Sprint("undefined: ", pack.Name, ".", name))
+	}
+	vars, ok := ctx.Globals.packages[pack]
+	if !ok {
+		vars = &packageVars{
+			make(map[string]Value),
+			make(map[string]*VariableLValue),
+		}
+		ctx.Globals.packages[pack] = vars
+	}
+	lvalue, ok := vars.lvalues[name]
+	if !ok {
+		lvalue = &VariableLValue{
+			vars.values,
+			name,
 		}
-	} else {
-		// If we didn't find anything, then create it as a local
-		// variable.
-		// TODO: Maybe we need to init to a zero value?
-		ctx.Locals[name] = nil
-		return &VariableLValue{
+		vars.lvalues[name] = lvalue
+	}
+	return lvalue
+}
+
+func (ctx *Context) variableLValue(name string) *VariableLValue {
+	lvalue, ok := ctx.variables[name]
+	if !ok {
+		lvalue = &VariableLValue{
 			ctx.Locals,
 			name,
 		}
+		ctx.variables[name] = lvalue
 	}
+	return lvalue
 }
 
-func (ctx *Context) isNameValid(name string) bool {
-	if _, ok := ctx.Locals[name]; ok {
-		return true
-	} else if _, ok := ctx.Package.Funcs[name]; ok {
-		return true
+// Start a new variable for a declaration that runs again, like one in a loop.
+// Function literals and pointers that refer to the previous variable keep it,
+// so it's moved out of Locals.
+func (ctx *Context) declareVar(name string) {
+	if lvalue, ok := ctx.variables[name]; ok {
+		lvalue.varMap = map[string]Value{name: lvalue.get()}
+		delete(ctx.variables, name)
 	}
-	return false
 }
 
 func (ctx *Context) assignValue(name string, value Value) {
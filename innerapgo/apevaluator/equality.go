@@ -0,0 +1,233 @@
+package apevaluator
+
+import (
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Interpreted values can't be compared natively (and Value interfaces holding
+// pointers would be compared by identity), so for == and map keys they're
+// converted to comparable native keys that follow Go's equality rules.
+
+type structKey struct {
+	typeName string
+	typeArgs *typeArgList
+	// Comma-separated field names, in the same order as the fields.
+	fieldNames string
+	// Array of the keys of the field values.
+	fields interface{}
+}
+
+type namedKey struct {
+	typeName string
+	typeArgs *typeArgList
+	val interface{}
+}
+
+type arrayKey struct {
+	elemType reflect.Type
+	// Array of the keys of the elements.
+	elems interface{}
+}
+
+type pointerKey struct {
+	location interface{}
+}
+
+// Type arguments are interned so that keys can compare them by identity.
+type typeArgList struct {
+	args []apast.Expr
+}
+
+var internedTypeArgs []*typeArgList
+
+func internTypeArgs(typeArgs []apast.Expr) *typeArgList {
+	if typeArgs == nil {
+		return nil
+	}
+	for _, interned := range internedTypeArgs {
+		if typeListsEqual(interned.args, typeArgs) {
+			return interned
+		}
+	}
+	interned := &typeArgList{typeArgs}
+	internedTypeArgs = append(internedTypeArgs, interned)
+	return interned
+}
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// Get a comparable native key for the value. Two keys are equal if and only if
+// the values are equal in Go: structs are compared field by field, arrays
+// element by element, pointers by the location they point to, and values of
+// different types are never equal. Like in Go, comparing keys panics if they
+// hold values that aren't comparable, like slices.
+func toComparable(val Value) interface{} {
+	switch val := val.(type) {
+	case *NativeValue:
+		rv := reflect.ValueOf(val.AsNative())
+		// Arrays of interpreted values may be nested, like [2][2]Value.
+		if rv.Kind() == reflect.Array && containsValueType(rv.Type()) {
+			elems := reflect.New(reflect.ArrayOf(rv.Len(), interfaceType)).Elem()
+			for i := 0; i < rv.Len(); i++ {
+				elems.Index(i).Set(comparableReflectValue(fromReflectValue(rv.Index(i))))
+			}
+			return arrayKey{rv.Type().Elem(), elems.Interface()}
+		}
+		return val.AsNative()
+	case *NamedValue:
+		return namedKey{
+			val.TypeName,
+			internTypeArgs(val.TypeArgs),
+			toComparable(val.Val),
+		}
+	case *StructValue:
+		fieldNames := []string{}
+		for name := range val.Values {
+			fieldNames = append(fieldNames, name)
+		}
+		sort.Strings(fieldNames)
+		fields := reflect.New(reflect.ArrayOf(len(fieldNames), interfaceType)).Elem()
+		for i, name := range fieldNames {
+			fields.Index(i).Set(comparableReflectValue(val.Values[name]))
+		}
+		return structKey{
+			val.TypeName,
+			internTypeArgs(val.TypeArgs),
+			strings.Join(fieldNames, ","),
+			fields.Interface(),
+		}
+	case *PointerValue:
+		if val.Target == nil {
+			return nil
+		}
+		return pointerKey{
+			locationKey(val.Target),
+		}
+	default:
+		panic(fmt.Sprint("runtime error: hash of unhashable type ", reflect.TypeOf(val)))
+	}
+}
+
+// Inverse of toComparable, e.g. to get the interpreted keys of a map.
+func fromComparable(key interface{}) Value {
+	switch key := key.(type) {
+	case structKey:
+		structVal := &StructValue{
+			key.typeName,
+			key.typeArgs.get(),
+			nil,
+			make(map[string]Value),
+		}
+		fields := reflect.ValueOf(key.fields)
+		for i := 0; i < fields.Len(); i++ {
+			name := strings.Split(key.fieldNames, ",")[i]
+			structVal.Values[name] = fromComparable(fields.Index(i).Interface())
+		}
+		return structVal
+	case namedKey:
+		return &NamedValue{
+			key.typeName,
+			key.typeArgs.get(),
+			fromComparable(key.val),
+		}
+	case arrayKey:
+		elems := reflect.ValueOf(key.elems)
+		result := reflect.New(reflect.ArrayOf(elems.Len(), key.elemType)).Elem()
+		for i := 0; i < elems.Len(); i++ {
+			result.Index(i).Set(toReflectValue(fromComparable(elems.Index(i).Interface()), key.elemType))
+		}
+		return &NativeValue{result.Interface()}
+	case pointerKey:
+		switch location := key.location.(type) {
+		case ExprResult:
+			return &PointerValue{location, nil}
+		case StructLValue:
+			return &PointerValue{&location, nil}
+		default:
+			return &PointerValue{&ReflectValLValue{reflect.ValueOf(location).Elem()}, nil}
+		}
+	default:
+		return &NativeValue{key}
+	}
+}
+
+func (typeArgs *typeArgList) get() []apast.Expr {
+	if typeArgs == nil {
+		return nil
+	}
+	return typeArgs.args
+}
+
+func comparableReflectValue(val Value) reflect.Value {
+	key := toComparable(val)
+	return reflect.ValueOf(&key).Elem()
+}
+
+// Get a key identifying the location that a pointer points to. Variable
+// lvalues are shared by everything referring to the same variable (see
+// Context.resolveValue), so most locations can be compared by identity.
+func locationKey(target ExprResult) interface{} {
+	switch target := target.(type) {
+	case *StructLValue:
+		return *target
+	case *ReflectValLValue:
+		if target.val.CanAddr() {
+			return target.val.Addr().Interface()
+		}
+	}
+	// Native values can also be pointed to by native pointers, so they're
+	// compared by their native address.
+	if nativeVal, ok := target.get().(*NativeValue); ok && !isNil(nativeVal) {
+		if addr, ok := addressNative(target, nativeVal); ok {
+			return addr.Addr().Interface()
+		}
+	}
+	return target
+}
+
+// The operands of == and != are converted to keys if they're interpreted
+// values. Values of named types are only converted if both sides are named,
+// since otherwise the other side may be an untyped constant, like in
+// `temp == 0`.
+func toComparableOperands(args []Value) []Value {
+	_, xIsNamed := args[0].(*NamedValue)
+	_, yIsNamed := args[1].(*NamedValue)
+	result := []Value{}
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case *NamedValue:
+			if fn, ok := arg.Val.(*FunctionValue); ok {
+				// Functions can only be compared with nil.
+				result = append(result, &NativeValue{fn})
+				continue
+			}
+			if !xIsNamed || !yIsNamed {
+				result = append(result, arg)
+				continue
+			}
+		case *FunctionValue:
+			// Functions can only be compared with nil.
+			result = append(result, &NativeValue{arg})
+			continue
+		}
+		result = append(result, &NativeValue{toComparable(arg)})
+	}
+	return result
+}
+
+// Convert a value to a key of a native map with the given key type. Maps with
+// interpreted key types have interface{} keys, which hold comparable keys.
+func toMapKey(val Value, keyType reflect.Type) reflect.Value {
+	if keyType == interfaceType {
+		key := toComparable(val)
+		if key == nil {
+			return reflect.Zero(keyType)
+		}
+		return reflect.ValueOf(&key).Elem()
+	}
+	return toReflectValue(val, keyType)
+}
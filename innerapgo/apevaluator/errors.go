@@ -0,0 +1,71 @@
+package apevaluator
+
+import (
+	"github.com/alangpierce/apgo/apast"
+	"reflect"
+)
+
+// Native errors.As can't assign to interpreted variables or check interpreted
+// types, so it's implemented here when the target is an interpreted pointer.
+// Like errors.As, it finds the first error in the chain that has the target's
+// type, or whose As method returns true. Variables don't keep their static
+// types, so the target's type is the type of the value it holds; targets of
+// interface types aren't supported.
+
+func isErrorsAs(funcVal reflect.Value) bool {
+	return nativeFuncName(funcVal) == "errors.As"
+}
+
+func evaluateErrorsAs(ctx *Context, err Value, target *PointerValue) Value {
+	if target.Target == nil {
+		panic("errors: target cannot be nil")
+	}
+	// Variables holding a nil interface don't have a type at runtime.
+	targetType := typeOfValue(ctx, target.Target.get())
+	if targetType == nil {
+		panic("errors.As: target must point to a variable of a concrete type in interpreted code")
+	}
+	return &NativeValue{errorsAs(ctx, err, target.Target, targetType)}
+}
+
+func errorsAs(ctx *Context, err Value, target ExprResult, targetType apast.Expr) bool {
+	for !isNil(err) {
+		if hasType(ctx, err, targetType) {
+			target.set(err.Copy())
+			return true
+		}
+		if _, ok := err.(*NativeValue); !ok && hasMethod(ctx.Package, err, "As") {
+			if callErrorMethod(ctx, err, "As", &PointerValue{target, nil}).AsNative().(bool) {
+				return true
+			}
+		}
+		if !hasMethod(ctx.Package, err, "Unwrap") {
+			return false
+		}
+		err = callErrorMethod(ctx, err, "Unwrap")
+		// Errors from errors.Join wrap a slice of errors, which are
+		// checked in order.
+		if errs, ok := nativeSlice(err); ok {
+			for i := 0; i < errs.Len(); i++ {
+				if errorsAs(ctx, fromReflectValue(errs.Index(i)), target, targetType) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return false
+}
+
+func nativeSlice(val Value) (reflect.Value, bool) {
+	if nativeVal, ok := val.(*NativeValue); ok {
+		rv := reflect.ValueOf(nativeVal.AsNative())
+		return rv, rv.Kind() == reflect.Slice
+	}
+	return reflect.Value{}, false
+}
+
+func callErrorMethod(ctx *Context, err Value, name string, args ...Value) Value {
+	method := evaluateSelector(ctx, &RValue{err}, name).get()
+	return callFunc(ctx, method, args, false)[0]
+}
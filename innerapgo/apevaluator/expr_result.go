@@ -47,11 +47,11 @@ type ReflectValLValue struct {
 }
 
 func (lv *ReflectValLValue) get() Value {
-	return &NativeValue{lv.val.Interface()}
+	return fromReflectValue(lv.val)
 }
 
 func (lv *ReflectValLValue) set(val Value) {
-	lv.val.Set(reflect.ValueOf(val.AsNative()))
+	lv.val.Set(toReflectValue(val, lv.val.Type()))
 }
 
 type StructLValue struct {
@@ -65,4 +65,45 @@ func (lv *StructLValue) get() Value {
 
 func (lv *StructLValue) set(val Value) {
 	lv.structVal.Values[lv.name] = val
-}
\ No newline at end of file
+}
+// AllocatedLValue is a new location that isn't part of any variable, like the
+// target of `&Point{}`.
+type AllocatedLValue struct {
+	val Value
+}
+
+func (lv *AllocatedLValue) get() Value {
+	return lv.val
+}
+
+func (lv *AllocatedLValue) set(val Value) {
+	lv.val = val
+}
+
+// MapLValue is an entry in a map, which may or may not exist yet.
+type MapLValue struct {
+	mapVal reflect.Value
+	key reflect.Value
+	// Gets the zero value of the element type, or nil if the element type
+	// isn't known, in which case the zero value of the native element type
+	// is used.
+	elemZero func() Value
+}
+
+func (lv *MapLValue) get() Value {
+	elem := lv.mapVal.MapIndex(lv.key)
+	if !elem.IsValid() && lv.elemZero != nil {
+		return lv.elemZero()
+	} else if !elem.IsValid() {
+		elem = reflect.Zero(lv.mapVal.Type().Elem())
+	}
+	return fromReflectValue(elem)
+}
+
+func (lv *MapLValue) isPresent() bool {
+	return lv.mapVal.MapIndex(lv.key).IsValid()
+}
+
+func (lv *MapLValue) set(val Value) {
+	lv.mapVal.SetMapIndex(lv.key, toReflectValue(val, lv.mapVal.Type().Elem()))
+}
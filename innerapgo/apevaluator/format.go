@@ -0,0 +1,705 @@
+package apevaluator
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"github.com/alangpierce/apgo/apruntime"
+	"go/ast"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Interpreted values passed to fmt's print functions are wrapped in proxies
+// that implement fmt.Formatter, which print the value the way fmt prints the
+// equivalent Go value. fmt handles %T and %p itself before calling Format, so
+// those verbs are rewritten before the call instead (see rewriteFormatVerbs).
+
+type formatProxy struct {
+	interpretedValue
+}
+
+// fmt's functions that format their operands.
+var formatFuncs = map[string]bool{
+	"fmt.Append": true,
+	"fmt.Appendf": true,
+	"fmt.Appendln": true,
+	"fmt.Errorf": true,
+	"fmt.Fprint": true,
+	"fmt.Fprintf": true,
+	"fmt.Fprintln": true,
+	"fmt.Print": true,
+	"fmt.Printf": true,
+	"fmt.Println": true,
+	"fmt.Sprint": true,
+	"fmt.Sprintf": true,
+	"fmt.Sprintln": true,
+}
+
+func isFormatFunc(funcVal reflect.Value) bool {
+	return formatFuncs[nativeFuncName(funcVal)]
+}
+
+// Returns true if native code can't print the value the way Go would.
+func isInterpretedValue(val Value) bool {
+	switch val := val.(type) {
+	case *StructValue, *NamedValue, *FunctionValue:
+		return true
+	case *PointerValue:
+		// Nil pointers are printed with their type, e.g. for %#v.
+		return val.Target == nil || needsMaterializing(val)
+	default:
+		return needsMaterializing(val)
+	}
+}
+
+// Get the proxy to pass to fmt for an interpreted value. Errors and Stringers
+// keep their usual proxies, e.g. so that %w wraps the error, and all proxies
+// implement Format.
+func newFormatProxy(ctx *Context, val Value) interface{} {
+	if proxy, ok := makeProxy(ctx, val, interfaceType); ok {
+		return proxy
+	}
+	obj, ok := newInterpretedValue(ctx, val)
+	if !ok {
+		obj = interpretedValue{val, nil, ctx.Package, ctx.Globals}
+	}
+	return formatProxy{obj}
+}
+
+func (obj interpretedValue) Format(state fmt.State, verb rune) {
+	p := &printer{
+		state,
+		state,
+		verb,
+		verb == 'v' && state.Flag('+'),
+		verb == 'v' && state.Flag('#'),
+	}
+	ctx := NewContext(obj.globals, obj.pack)
+	val := obj.value()
+	p.print(ctx, val, typeOfValue(ctx, val), 0, true)
+}
+
+// printer prints interpreted values following fmt's printValue.
+type printer struct {
+	out io.Writer
+	state fmt.State
+	verb rune
+	plusV bool
+	sharpV bool
+}
+
+func (p *printer) write(s string) {
+	io.WriteString(p.out, s)
+}
+
+// Rebuild the directive being printed, like `%+5v`, with the given verb, so
+// that leaf values can be printed by fmt itself.
+func (p *printer) directive(verb rune, sharp bool, withPrecision bool) string {
+	result := "%"
+	for _, flag := range "-+ 0" {
+		// Like fmt, %+v only uses the plus flag to print field names.
+		if p.state.Flag(int(flag)) && !(flag == '+' && p.plusV) {
+			result += string(flag)
+		}
+	}
+	if sharp {
+		result += "#"
+	}
+	if width, ok := p.state.Width(); ok {
+		result += strconv.Itoa(width)
+	}
+	if precision, ok := p.state.Precision(); ok && withPrecision {
+		result += "." + strconv.Itoa(precision)
+	}
+	return result + string(verb)
+}
+
+func (p *printer) print(ctx *Context, val Value, typeExpr apast.Expr, depth int, canInterface bool) {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if typeExpr == nil || getInterfaceType(ctx.Package, typeExpr) != nil {
+		if isNil(val) {
+			p.printNilInterface(ctx, typeExpr)
+			return
+		}
+		// Interfaces are printed as the value they hold.
+		typeExpr = typeOfValue(ctx, val)
+	}
+	if canInterface && p.handleMethods(ctx, val) {
+		return
+	}
+	if namedVal, ok := val.(*NamedValue); ok {
+		val = namedVal.Val
+	}
+	switch val := val.(type) {
+	case *StructValue:
+		if structType := typeOfValue(ctx, val); structType != nil {
+			typeExpr = structType
+		}
+		p.printStruct(ctx, val, typeExpr, depth, canInterface)
+	case *PointerValue:
+		p.printPointer(ctx, val, typeExpr, depth, canInterface)
+	case *FunctionValue:
+		p.printAddress(ctx, reflect.ValueOf(val.FuncDecl).Pointer(), typeExpr)
+	case *NativeValue:
+		if val.val == nil {
+			p.printAddress(ctx, 0, typeExpr)
+			return
+		}
+		rv := reflect.ValueOf(val.AsNative())
+		_, _, _, isNamed := lookupNamedType(ctx, typeExpr)
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			if containsValueType(rv.Type()) || (isNamed && p.sharpV) {
+				p.printContainer(ctx, rv, typeExpr, depth, canInterface)
+				return
+			}
+		}
+		if isNamed {
+			// Interpreted named types don't have the methods of their
+			// underlying native type, like time.Duration's String.
+			rv = withoutMethods(rv)
+		}
+		p.printLeaf(rv, depth, canInterface)
+	}
+}
+
+// Call the value's Error, String or GoString method, like fmt's handleMethods.
+func (p *printer) handleMethods(ctx *Context, val Value) bool {
+	obj, ok := newInterpretedValue(ctx, val)
+	if !ok {
+		return false
+	}
+	methodNames := []string{"Error", "String"}
+	if p.sharpV {
+		methodNames = []string{"GoString"}
+	} else if !strings.ContainsRune("vsxXq", p.verb) {
+		return false
+	}
+	for _, name := range methodNames {
+		if hasMethod(ctx.Package, val, name) {
+			str := obj.callMethod(name, []reflect.Type{stringType})[0].String()
+			if p.sharpV {
+				fmt.Fprintf(p.out, p.directive('s', false, true), str)
+			} else {
+				fmt.Fprintf(p.out, p.directive(p.verb, p.state.Flag('#'), true), str)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) printStruct(ctx *Context, structVal *StructValue, typeExpr apast.Expr, depth int, canInterface bool) {
+	if p.sharpV {
+		p.write(describeType(ctx.Package, typeExpr))
+	}
+	fieldCtx, structType := resolveUnderlying(ctx, typeExpr)
+	p.write("{")
+	for i, field := range structType.(*apast.StructTypeExpr).Fields {
+		if i > 0 && p.sharpV {
+			p.write(", ")
+		} else if i > 0 {
+			p.write(" ")
+		}
+		if p.plusV || p.sharpV {
+			p.write(field.Name + ":")
+		}
+		// Like with reflection, methods can't be called on unexported
+		// fields.
+		p.print(fieldCtx, structVal.Values[field.Name], field.Type, depth + 1,
+			canInterface && ast.IsExported(field.Name))
+	}
+	p.write("}")
+}
+
+func (p *printer) printPointer(ctx *Context, pointer *PointerValue, typeExpr apast.Expr, depth int, canInterface bool) {
+	if pointer.Target == nil {
+		p.printAddress(ctx, 0, typeExpr)
+		return
+	}
+	target := pointer.Target.get()
+	// Like fmt, pointers to composite values are followed at the top
+	// level, but not inside other values, to avoid loops.
+	if depth == 0 && isCompositeValue(target) {
+		elemType := typeOfValue(ctx, target)
+		if pointerType, ok := typeExpr.(*apast.PointerTypeExpr); ok {
+			elemType = pointerType.Elem
+		}
+		p.write("&")
+		p.print(ctx, target, elemType, depth + 1, canInterface)
+		return
+	}
+	p.printAddress(ctx, pointerAddress(pointer), typeExpr)
+}
+
+func isCompositeValue(val Value) bool {
+	switch val := val.(type) {
+	case *StructValue:
+		return true
+	case *NamedValue:
+		return isCompositeValue(val.Val)
+	case *NativeValue:
+		switch reflect.ValueOf(val.AsNative()).Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return true
+		}
+	}
+	return false
+}
+
+// Struct fields don't have an address of their own in the evaluator, so
+// pointers to them are given one.
+var fieldAddresses = map[interface{}]*int{}
+
+// Get the address that a pointer is printed with. Pointers to the same
+// location have the same address.
+func pointerAddress(pointer *PointerValue) uintptr {
+	if pointer.Target == nil {
+		return 0
+	}
+	key := locationKey(pointer.Target)
+	if rv := reflect.ValueOf(key); rv.Kind() == reflect.Ptr {
+		return rv.Pointer()
+	}
+	address, ok := fieldAddresses[key]
+	if !ok {
+		address = new(int)
+		fieldAddresses[key] = address
+	}
+	return reflect.ValueOf(address).Pointer()
+}
+
+// Print a pointer, func or other reference with the given address, like
+// fmt's fmtPointer.
+func (p *printer) printAddress(ctx *Context, address uintptr, typeExpr apast.Expr) {
+	switch p.verb {
+	case 'v':
+		if p.sharpV {
+			p.write("(" + describeType(ctx.Package, typeExpr) + ")(")
+			if address == 0 {
+				p.write("nil")
+			} else {
+				fmt.Fprintf(p.out, p.directive('x', true, true), uint64(address))
+			}
+			p.write(")")
+		} else if address == 0 {
+			fmt.Fprintf(p.out, p.directive('s', false, false), "<nil>")
+		} else {
+			fmt.Fprintf(p.out, p.directive('x', !p.state.Flag('#'), true), uint64(address))
+		}
+	case 'p':
+		fmt.Fprintf(p.out, p.directive('x', !p.state.Flag('#'), true), uint64(address))
+	case 'b', 'o', 'd', 'x', 'X':
+		fmt.Fprintf(p.out, p.directive(p.verb, p.state.Flag('#'), true), uint64(address))
+	default:
+		p.write("%!" + string(p.verb) + "(" + describeType(ctx.Package, typeExpr) + "=")
+		(&printer{p.out, p.state, 'v', false, false}).printAddress(ctx, address, typeExpr)
+		p.write(")")
+	}
+}
+
+func (p *printer) printNilInterface(ctx *Context, typeExpr apast.Expr) {
+	if p.sharpV {
+		p.write(describeType(ctx.Package, typeExpr) + "(nil)")
+	} else if p.verb == 'v' {
+		p.write("<nil>")
+	} else {
+		p.write("%!" + string(p.verb) + "(<nil>)")
+	}
+}
+
+// Print a slice, array or map holding interpreted values, or of an
+// interpreted named type.
+func (p *printer) printContainer(ctx *Context, rv reflect.Value, typeExpr apast.Expr, depth int, canInterface bool) {
+	elemCtx, underlying := resolveUnderlying(ctx, typeExpr)
+	keyType, elemType := containerTypes(underlying, rv.Type())
+	if p.sharpV {
+		p.write(describeType(ctx.Package, typeExpr))
+		if rv.Kind() != reflect.Array && rv.IsNil() {
+			p.write("(nil)")
+			return
+		}
+		p.write("{")
+	} else if rv.Kind() == reflect.Map {
+		p.write("map[")
+	} else {
+		p.write("[")
+	}
+	separator := " "
+	if p.sharpV {
+		separator = ", "
+	}
+	if rv.Kind() == reflect.Map {
+		for i, key := range sortedMapKeys(ctx, rv) {
+			if i > 0 {
+				p.write(separator)
+			}
+			p.print(elemCtx, fromMapKey(rv, key), keyType, depth + 1, canInterface)
+			p.write(":")
+			p.print(elemCtx, fromReflectValue(rv.MapIndex(key)), elemType, depth + 1, canInterface)
+		}
+	} else {
+		for i := 0; i < rv.Len(); i++ {
+			if i > 0 {
+				p.write(separator)
+			}
+			p.print(elemCtx, fromReflectValue(rv.Index(i)), elemType, depth + 1, canInterface)
+		}
+	}
+	if p.sharpV {
+		p.write("}")
+	} else {
+		p.write("]")
+	}
+}
+
+// Get the key and element types of a container type.
+func containerTypes(typeExpr apast.Expr, t reflect.Type) (apast.Expr, apast.Expr) {
+	switch typeExpr := typeExpr.(type) {
+	case *apast.SliceTypeExpr:
+		return nil, typeExpr.Elem
+	case *apast.ArrayTypeExpr:
+		return nil, typeExpr.Elem
+	case *apast.MapTypeExpr:
+		return typeExpr.Key, typeExpr.Elem
+	}
+	if t.Kind() == reflect.Map {
+		return interpretedTypeExpr(t.Key()), interpretedTypeExpr(t.Elem())
+	}
+	return nil, interpretedTypeExpr(t.Elem())
+}
+
+func fromMapKey(mapVal reflect.Value, key reflect.Value) Value {
+	if mapVal.Type().Key() == interfaceType {
+		// See toMapKey.
+		return fromComparable(key.Interface())
+	}
+	return fromReflectValue(key)
+}
+
+// Leaf values are printed by fmt. Inside other values, they're printed as a
+// field of a struct, so that fmt follows its rules for nested values, like not
+// calling methods on unexported fields.
+type exportedLeaf struct {
+	V interface{}
+}
+
+type unexportedLeaf struct {
+	v interface{}
+}
+
+func (p *printer) printLeaf(rv reflect.Value, depth int, canInterface bool) {
+	directive := p.directive(p.verb, p.state.Flag('#'), true)
+	if p.plusV {
+		directive = "%+" + directive[1:]
+	}
+	if depth == 0 {
+		fmt.Fprintf(p.out, directive, rv.Interface())
+		return
+	}
+	var wrapper interface{} = unexportedLeaf{rv.Interface()}
+	if canInterface {
+		wrapper = exportedLeaf{rv.Interface()}
+	}
+	prefix := "{"
+	if p.sharpV {
+		prefix = reflect.TypeOf(wrapper).String() + "{" + reflect.TypeOf(wrapper).Field(0).Name + ":"
+	} else if p.plusV {
+		prefix = "{" + reflect.TypeOf(wrapper).Field(0).Name + ":"
+	}
+	printed := fmt.Sprintf(directive, wrapper)
+	p.write(printed[len(prefix):len(printed) - 1])
+}
+
+// Convert a value of a native named type to its underlying basic type, if it
+// has one.
+func withoutMethods(rv reflect.Value) reflect.Value {
+	if rv.Type().PkgPath() == "" {
+		return rv
+	}
+	for _, basicType := range apruntime.BasicTypes {
+		if basicType.Kind() == rv.Kind() {
+			return rv.Convert(basicType)
+		}
+	}
+	return rv
+}
+
+// Get the underlying type of a resolved type along with the context to resolve
+// its type parameters, e.g. to get the fields of a generic struct type.
+func resolveUnderlying(ctx *Context, typeExpr apast.Expr) (*Context, apast.Expr) {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if _, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		return resolveUnderlying(typeCtx, typeDecl.Underlying)
+	}
+	return ctx, typeExpr
+}
+
+// Sort map keys in the order fmt prints them in.
+func sortedMapKeys(ctx *Context, mapVal reflect.Value) []reflect.Value {
+	keys := mapVal.MapKeys()
+	sort.SliceStable(keys, func(i int, j int) bool {
+		return compareValues(ctx, fromMapKey(mapVal, keys[i]), fromMapKey(mapVal, keys[j])) < 0
+	})
+	return keys
+}
+
+// Compare two values of a comparable type, following fmt's internal/fmtsort.
+func compareValues(ctx *Context, a Value, b Value) int {
+	switch a := a.(type) {
+	case *NamedValue:
+		if b, ok := b.(*NamedValue); ok && a.TypeName == b.TypeName {
+			return compareValues(ctx, a.Val, b.Val)
+		}
+	case *StructValue:
+		if b, ok := b.(*StructValue); ok && a.TypeName == b.TypeName {
+			_, structType := resolveUnderlying(ctx, typeOfValue(ctx, a))
+			if structType, ok := structType.(*apast.StructTypeExpr); ok {
+				for _, field := range structType.Fields {
+					if c := compareValues(ctx, a.Values[field.Name], b.Values[field.Name]); c != 0 {
+						return c
+					}
+				}
+			}
+			return 0
+		}
+	case *PointerValue:
+		if b, ok := b.(*PointerValue); ok {
+			return cmp.Compare(pointerAddress(a), pointerAddress(b))
+		}
+	case *NativeValue:
+		if b, ok := b.(*NativeValue); ok && a.val != nil && b.val != nil {
+			return compareNative(ctx, reflect.ValueOf(a.AsNative()), reflect.ValueOf(b.AsNative()))
+		}
+	}
+	// Values of different types, in maps with interface keys, are ordered
+	// by type.
+	return strings.Compare(describeType(ctx.Package, typeOfValue(ctx, a)), describeType(ctx.Package, typeOfValue(ctx, b)))
+}
+
+func compareNative(ctx *Context, a reflect.Value, b reflect.Value) int {
+	if a.Type() != b.Type() {
+		return strings.Compare(a.Type().String(), b.Type().String())
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(a.Uint(), b.Uint())
+	case reflect.String:
+		return cmp.Compare(a.String(), b.String())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(a.Float(), b.Float())
+	case reflect.Complex64, reflect.Complex128:
+		if c := cmp.Compare(real(a.Complex()), real(b.Complex())); c != 0 {
+			return c
+		}
+		return cmp.Compare(imag(a.Complex()), imag(b.Complex()))
+	case reflect.Bool:
+		if a.Bool() == b.Bool() {
+			return 0
+		} else if b.Bool() {
+			return -1
+		}
+		return 1
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Chan:
+		return cmp.Compare(a.Pointer(), b.Pointer())
+	case reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			if c := compareNative(ctx, a.Field(i), b.Field(i)); c != 0 {
+				return c
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < a.Len(); i++ {
+			if c := compareNative(ctx, a.Index(i), b.Index(i)); c != 0 {
+				return c
+			}
+		}
+	case reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return cmp.Compare(boolToInt(!a.IsNil()), boolToInt(!b.IsNil()))
+		}
+		if a.Type() == valueType {
+			return compareValues(ctx, fromReflectValue(a), fromReflectValue(b))
+		}
+		return compareNative(ctx, a.Elem(), b.Elem())
+	}
+	return 0
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// fmt handles %T and %p before calling Format, so for interpreted values,
+// rewrite %T to print the type name as a string and %p to print the address as
+// hex. Arguments are usually used once, in which case they're replaced. With
+// explicit argument indexes, like `%[1]T %[1]v`, the new arguments are
+// appended instead, and later verbs are given explicit indexes to match.
+func rewriteFormatVerbs(ctx *Context, args []Value, argVals []reflect.Value) []reflect.Value {
+	format := argVals[0].String()
+	args = args[1:]
+	reordered := strings.Contains(format, "[")
+	result := ""
+	argNum := 0
+	// Set once an argument is appended, since later verbs would otherwise
+	// use the arguments after the appended one.
+	appended := false
+	for i := 0; i < len(format); {
+		if format[i] != '%' {
+			result += format[i:i + 1]
+			i++
+			continue
+		}
+		i++
+		directive := "%"
+		for i < len(format) && strings.IndexByte("#0+- ", format[i]) >= 0 {
+			directive += format[i:i + 1]
+			i++
+		}
+		// Parse the rest of the directive the way fmt's doPrintf does,
+		// keeping track of which argument each part uses.
+		afterIndex := false
+		parseIndex := func() {
+			afterIndex = false
+			if i < len(format) && format[i] == '[' {
+				if end := strings.IndexByte(format[i:], ']'); end >= 0 {
+					if n, err := strconv.Atoi(format[i + 1:i + end]); err == nil {
+						argNum = n - 1
+					}
+					directive += format[i:i + end + 1]
+					i += end + 1
+					afterIndex = true
+				}
+			}
+		}
+		explicitIndex := func() string {
+			if appended && !afterIndex {
+				return "[" + strconv.Itoa(argNum + 1) + "]"
+			}
+			return ""
+		}
+		parseNum := func() {
+			if i < len(format) && format[i] == '*' {
+				directive += explicitIndex() + "*"
+				argNum++
+				i++
+				afterIndex = false
+				return
+			}
+			for i < len(format) && format[i] >= '0' && format[i] <= '9' {
+				directive += format[i:i + 1]
+				i++
+			}
+		}
+		parseIndex()
+		parseNum()
+		if i + 1 < len(format) && format[i] == '.' {
+			directive += "."
+			i++
+			parseIndex()
+			parseNum()
+		}
+		if !afterIndex {
+			parseIndex()
+		}
+		if i >= len(format) {
+			result += directive
+			break
+		}
+		verb, size := utf8.DecodeRuneInString(format[i:])
+		i += size
+		if verb == '%' {
+			result += directive + "%"
+			continue
+		}
+		// The index before the verb, which changes if the argument is
+		// appended.
+		verbIndex := explicitIndex()
+		if afterIndex {
+			indexStart := strings.LastIndexByte(directive, '[')
+			verbIndex = directive[indexStart:]
+			directive = directive[:indexStart]
+		}
+		if argNum >= 0 && argNum < len(args) && (verb == 'T' || verb == 'p') && isInterpretedValue(args[argNum]) {
+			arg := args[argNum]
+			var newArg interface{}
+			if verb == 'T' {
+				newArg = describeType(ctx.Package, typeOfValue(ctx, arg))
+				verb = 's'
+			} else if address, ok := valueAddress(arg); ok {
+				newArg = uint64(address)
+				// %p prints hex with a leading 0x, unless the
+				// # flag is given.
+				if strings.Contains(directive, "#") {
+					directive = strings.Replace(directive, "#", "", 1)
+				} else {
+					directive = "%#" + directive[1:]
+				}
+				verb = 'x'
+			} else {
+				newArg = "%!p(" + describeType(ctx.Package, typeOfValue(ctx, arg)) + "=" +
+					fmt.Sprint(newFormatProxy(ctx, arg)) + ")"
+				directive = "%"
+				verb = 's'
+			}
+			if reordered {
+				argVals = append(argVals, reflect.ValueOf(newArg))
+				verbIndex = "[" + strconv.Itoa(len(argVals) - 1) + "]"
+				appended = true
+			} else {
+				argVals[argNum + 1] = reflect.ValueOf(newArg)
+			}
+		}
+		result += directive + verbIndex + string(verb)
+		argNum++
+	}
+	// fmt reports unused arguments with their native types, so report them
+	// here instead.
+	if !reordered && argNum < len(args) {
+		var extras []string
+		for j := argNum; j < len(args); j++ {
+			extra := argVals[j + 1]
+			if isInterpretedValue(args[j]) {
+				extras = append(extras, describeType(ctx.Package, typeOfValue(ctx, args[j])) + "=" +
+					fmt.Sprint(newFormatProxy(ctx, args[j])))
+			} else if !extra.IsValid() || isNil(args[j]) && extra.Kind() == reflect.Interface {
+				extras = append(extras, "<nil>")
+			} else {
+				extras = append(extras, fmt.Sprintf("%T=%v", extra.Interface(), extra.Interface()))
+			}
+		}
+		result += strings.ReplaceAll("%!(EXTRA " + strings.Join(extras, ", ") + ")", "%", "%%")
+		argVals = argVals[:argNum + 1]
+	}
+	argVals[0] = reflect.ValueOf(result)
+	return argVals
+}
+
+// Get the address that %p prints for a value, if it has one.
+func valueAddress(val Value) (uintptr, bool) {
+	switch val := val.(type) {
+	case *PointerValue:
+		return pointerAddress(val), true
+	case *FunctionValue:
+		return reflect.ValueOf(val.FuncDecl).Pointer(), true
+	case *NamedValue:
+		return valueAddress(val.Val)
+	case *NativeValue:
+		switch rv := reflect.ValueOf(val.AsNative()); rv.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Func, reflect.Chan:
+			return rv.Pointer(), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,615 @@
+package apevaluator
+
+import (
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+)
+
+// Generics are implemented with dictionary passing: the Context maps the type
+// parameters in scope to their type arguments, and type expressions that
+// mention a type parameter are resolved through it at runtime. Type arguments
+// are always resolved (see resolveType), so they don't depend on the context
+// that they came from.
+
+// Create a context for evaluating type expressions in the declaration of a
+// generic type with the given type arguments.
+func newTypeContext(outer *Context, typeParams []*apast.TypeParamDecl, typeArgs []apast.Expr) *Context {
+	ctx := NewContext(outer.Globals, outer.Package)
+	if len(typeParams) > 0 {
+		ctx.TypeArgs = make(map[string]apast.Expr)
+		for i, typeParam := range typeParams {
+			ctx.TypeArgs[typeParam.Name] = typeArgs[i]
+		}
+	}
+	return ctx
+}
+
+// Create a context for evaluating the given function, including type
+// expressions in its signature. Functions are evaluated in the package that
+// declared them.
+func funcContext(fn *FunctionValue) *Context {
+	ctx := NewContext(fn.Globals, fn.FuncDecl.Package)
+	ctx.TypeArgs = fn.TypeArgs
+	for name, lvalue := range fn.CapturedVars {
+		ctx.variables[name] = lvalue
+	}
+	return ctx
+}
+
+// If the type expression is a type parameter, return its type argument.
+// Otherwise, return the type expression unchanged.
+func resolveTypeParam(ctx *Context, typeExpr apast.Expr) apast.Expr {
+	if ident, ok := typeExpr.(*apast.IdentExpr); ok {
+		if typeArg, ok := ctx.TypeArgs[ident.Name]; ok {
+			return typeArg
+		}
+	}
+	return typeExpr
+}
+
+// Resolve a type expression to a form that doesn't depend on the context, so
+// that it can be used as a type argument and compared with other types. Type
+// parameters are replaced with their type arguments, and types that are
+// entirely native, like int or []string, become NativeTypeExprs.
+func resolveType(ctx *Context, typeExpr apast.Expr) apast.Expr {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	switch typeExpr := typeExpr.(type) {
+	case *apast.IdentExpr:
+		if _, ok := ctx.Package.Types[typeExpr.Name]; ok {
+			return typeExpr
+		} else if basicType, ok := apruntime.BasicTypes[typeExpr.Name]; ok {
+			return &apast.NativeTypeExpr{basicType}
+		}
+		return typeExpr
+	case *apast.InstantiatedTypeExpr:
+		return &apast.InstantiatedTypeExpr{
+			typeExpr.Name,
+			resolveTypes(ctx, typeExpr.TypeArgs),
+		}
+	case *apast.SliceTypeExpr:
+		elem := resolveType(ctx, typeExpr.Elem)
+		return toNativeTypeExpr(ctx, &apast.SliceTypeExpr{elem}, elem)
+	case *apast.ArrayTypeExpr:
+		elem := resolveType(ctx, typeExpr.Elem)
+		return toNativeTypeExpr(ctx, &apast.ArrayTypeExpr{typeExpr.Len, elem}, elem)
+	case *apast.MapTypeExpr:
+		key := resolveType(ctx, typeExpr.Key)
+		elem := resolveType(ctx, typeExpr.Elem)
+		return toNativeTypeExpr(ctx, &apast.MapTypeExpr{key, elem}, key, elem)
+	case *apast.PointerTypeExpr:
+		return &apast.PointerTypeExpr{
+			resolveType(ctx, typeExpr.Elem),
+		}
+	case *apast.FuncTypeExpr:
+		return &apast.FuncTypeExpr{
+			resolveTypes(ctx, typeExpr.ParamTypes),
+			resolveTypes(ctx, typeExpr.ResultTypes),
+			typeExpr.IsVariadic,
+		}
+	default:
+		return typeExpr
+	}
+}
+
+func resolveTypes(ctx *Context, typeExprs []apast.Expr) []apast.Expr {
+	if typeExprs == nil {
+		return nil
+	}
+	result := []apast.Expr{}
+	for _, typeExpr := range typeExprs {
+		result = append(result, resolveType(ctx, typeExpr))
+	}
+	return result
+}
+
+// A composite type whose components are all native is itself native, e.g.
+// []int.
+func toNativeTypeExpr(ctx *Context, typeExpr apast.Expr, components ...apast.Expr) apast.Expr {
+	for _, component := range components {
+		if _, ok := component.(*apast.NativeTypeExpr); !ok {
+			return typeExpr
+		}
+	}
+	return &apast.NativeTypeExpr{
+		evaluateType(ctx, typeExpr),
+	}
+}
+
+// Returns true if the two resolved types are identical.
+func typeExprsEqual(a apast.Expr, b apast.Expr) bool {
+	switch a := a.(type) {
+	case *apast.NativeTypeExpr:
+		b, ok := b.(*apast.NativeTypeExpr)
+		return ok && a.Type == b.Type
+	case *apast.IdentExpr:
+		b, ok := b.(*apast.IdentExpr)
+		return ok && a.Name == b.Name
+	case *apast.InstantiatedTypeExpr:
+		b, ok := b.(*apast.InstantiatedTypeExpr)
+		return ok && a.Name == b.Name && typeListsEqual(a.TypeArgs, b.TypeArgs)
+	case *apast.SliceTypeExpr:
+		b, ok := b.(*apast.SliceTypeExpr)
+		return ok && typeExprsEqual(a.Elem, b.Elem)
+	case *apast.ArrayTypeExpr:
+		b, ok := b.(*apast.ArrayTypeExpr)
+		return ok && a.Len == b.Len && typeExprsEqual(a.Elem, b.Elem)
+	case *apast.MapTypeExpr:
+		b, ok := b.(*apast.MapTypeExpr)
+		return ok && typeExprsEqual(a.Key, b.Key) && typeExprsEqual(a.Elem, b.Elem)
+	case *apast.PointerTypeExpr:
+		b, ok := b.(*apast.PointerTypeExpr)
+		return ok && typeExprsEqual(a.Elem, b.Elem)
+	case *apast.FuncTypeExpr:
+		b, ok := b.(*apast.FuncTypeExpr)
+		return ok && a.IsVariadic == b.IsVariadic &&
+			typeListsEqual(a.ParamTypes, b.ParamTypes) &&
+			typeListsEqual(a.ResultTypes, b.ResultTypes)
+	default:
+		return a == b
+	}
+}
+
+func typeListsEqual(a []apast.Expr, b []apast.Expr) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !typeExprsEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Look up a named type declared in interpreted code, returning the name and
+// resolved type arguments (if it's generic) along with the declaration.
+func lookupNamedType(ctx *Context, typeExpr apast.Expr) (
+		typeName string, typeArgs []apast.Expr, typeDecl *apast.TypeDecl, ok bool) {
+	switch typeExpr := resolveTypeParam(ctx, typeExpr).(type) {
+	case *apast.IdentExpr:
+		typeDecl, ok = ctx.Package.Types[typeExpr.Name]
+		return typeExpr.Name, nil, typeDecl, ok
+	case *apast.InstantiatedTypeExpr:
+		typeDecl, ok = ctx.Package.Types[typeExpr.Name]
+		return typeExpr.Name, resolveTypes(ctx, typeExpr.TypeArgs), typeDecl, ok
+	}
+	return "", nil, nil, false
+}
+
+func namedTypeExpr(typeName string, typeArgs []apast.Expr) apast.Expr {
+	if typeArgs == nil {
+		return &apast.IdentExpr{typeName}
+	}
+	return &apast.InstantiatedTypeExpr{
+		typeName,
+		typeArgs,
+	}
+}
+
+// Get the name and type arguments of a resolved named type, as created by
+// namedTypeExpr.
+func splitNamedTypeExpr(typeExpr apast.Expr) (typeName string, typeArgs []apast.Expr, ok bool) {
+	switch typeExpr := typeExpr.(type) {
+	case *apast.IdentExpr:
+		return typeExpr.Name, nil, true
+	case *apast.InstantiatedTypeExpr:
+		return typeExpr.Name, typeExpr.TypeArgs, true
+	}
+	return "", nil, false
+}
+
+// Get the underlying type of a resolved type, e.g. float64 for Celsius.
+func underlyingType(ctx *Context, typeExpr apast.Expr) apast.Expr {
+	if _, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		return underlyingType(ctx, resolveType(typeCtx, typeDecl.Underlying))
+	}
+	if nativeType, ok := typeExpr.(*apast.NativeTypeExpr); ok && nativeType.Type.PkgPath() != "" {
+		// Named native types like time.Duration.
+		if basicType, ok := apruntime.BasicTypes[nativeType.Type.Kind().String()]; ok {
+			return &apast.NativeTypeExpr{basicType}
+		}
+	}
+	return typeExpr
+}
+
+// Type used for values whose type can't be determined, like elements of an
+// empty []Point. Type inference treats these as unknown.
+var unknownType = &apast.NativeTypeExpr{valueType}
+
+func isUnknownType(typeExpr apast.Expr) bool {
+	nativeType, ok := typeExpr.(*apast.NativeTypeExpr)
+	return ok && nativeType.Type == valueType
+}
+
+// Get the resolved type of a value, or nil if it's an untyped nil.
+func typeOfValue(ctx *Context, val Value) apast.Expr {
+	switch val := val.(type) {
+	case *StructValue:
+		if val.TypeName == "" {
+			if val.StructType == nil {
+				return nil
+			}
+			return val.StructType
+		}
+		return namedTypeExpr(val.TypeName, val.TypeArgs)
+	case *NamedValue:
+		return namedTypeExpr(val.TypeName, val.TypeArgs)
+	case *PointerValue:
+		if val.Target == nil {
+			return &apast.PointerTypeExpr{val.Elem}
+		}
+		elem := typeOfValue(ctx, val.Target.get())
+		if elem == nil {
+			elem = unknownType
+		}
+		return &apast.PointerTypeExpr{elem}
+	case *FunctionValue:
+		return resolveType(funcContext(val), val.FuncDecl.Type)
+	case *NativeValue:
+		if val.val == nil {
+			return nil
+		}
+		return typeOfReflectValue(ctx, reflect.ValueOf(val.AsNative()))
+	default:
+		return nil
+	}
+}
+
+// Native containers of interpreted values, like the []Value used for a
+// []Point, don't know which interpreted type they hold, so look at the
+// elements to find out.
+func typeOfReflectValue(ctx *Context, rv reflect.Value) apast.Expr {
+	t := rv.Type()
+	if (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem() == valueType {
+		var elems []Value
+		for i := 0; i < rv.Len(); i++ {
+			elems = append(elems, fromReflectValue(rv.Index(i)))
+		}
+		elem := commonType(ctx, elems)
+		if elem != nil && t.Kind() == reflect.Slice {
+			return &apast.SliceTypeExpr{elem}
+		} else if elem != nil {
+			return &apast.ArrayTypeExpr{t.Len(), elem}
+		}
+	}
+	if t.Kind() == reflect.Map && (t.Key() == interfaceType || t.Elem() == valueType) {
+		var keys, elems []Value
+		for _, key := range rv.MapKeys() {
+			keys = append(keys, fromMapKey(rv, key))
+			elems = append(elems, fromReflectValue(rv.MapIndex(key)))
+		}
+		var key apast.Expr = &apast.NativeTypeExpr{t.Key()}
+		var elem apast.Expr = &apast.NativeTypeExpr{t.Elem()}
+		if t.Key() == interfaceType {
+			key = commonType(ctx, keys)
+		}
+		if t.Elem() == valueType {
+			elem = commonType(ctx, elems)
+		}
+		if key != nil && elem != nil {
+			return &apast.MapTypeExpr{key, elem}
+		}
+	}
+	return &apast.NativeTypeExpr{t}
+}
+
+// Get the type shared by all of the values, or nil if there are none or they
+// have different types, e.g. the elements of a []interface{}.
+func commonType(ctx *Context, vals []Value) apast.Expr {
+	var result apast.Expr
+	for i, val := range vals {
+		typeExpr := typeOfValue(ctx, val)
+		if typeExpr == nil {
+			return nil
+		}
+		if i == 0 {
+			result = typeExpr
+		} else if describeType(ctx.Package, typeExpr) != describeType(ctx.Package, result) {
+			return nil
+		}
+	}
+	return result
+}
+
+type typeInferrer struct {
+	ctx *Context
+	typeParams map[string]bool
+	typeArgs map[string]apast.Expr
+	// Ranks of type arguments inferred from untyped constants, which can
+	// be replaced by a higher-ranked constant type, e.g. float64 for
+	// `Max(1, 2.5)`.
+	untypedRanks map[string]int
+}
+
+// Infer any type arguments of a generic function that weren't given
+// explicitly, based on the arguments that it's called with, and return the
+// instantiated function. isUntyped marks the arguments that are untyped
+// constants, and is nil if there aren't any.
+func instantiateFunc(ctx *Context, fn *FunctionValue, args []Value, isUntyped []bool, hasEllipsis bool) *FunctionValue {
+	typeParams := fn.FuncDecl.TypeParams
+	if len(fn.TypeArgs) == len(typeParams) {
+		return fn
+	}
+	inferrer := &typeInferrer{
+		ctx,
+		make(map[string]bool),
+		make(map[string]apast.Expr),
+		make(map[string]int),
+	}
+	for _, typeParam := range typeParams {
+		inferrer.typeParams[typeParam.Name] = true
+	}
+	for name, typeArg := range fn.TypeArgs {
+		inferrer.typeArgs[name] = typeArg
+	}
+
+	funcType := fn.FuncDecl.Type
+	numFixed := len(funcType.ParamTypes)
+	if funcType.IsVariadic && !hasEllipsis {
+		numFixed--
+	}
+	// Like Go, untyped constant arguments are only used after all other
+	// arguments, so that `Max(x, 1)` infers the type of x.
+	untypedArgs := []int{}
+	for i, arg := range args {
+		paramType := getParamType(funcType, numFixed, i)
+		if i < len(isUntyped) && isUntyped[i] && inferrer.isTypeParam(paramType) {
+			untypedArgs = append(untypedArgs, i)
+			continue
+		}
+		if argType := typeOfValue(ctx, arg); argType != nil {
+			inferrer.unify(paramType, argType)
+		}
+	}
+	for _, i := range untypedArgs {
+		inferrer.unifyUntyped(getParamType(funcType, numFixed, i).(*apast.IdentExpr).Name, args[i])
+	}
+	// Type parameters can also be inferred from the constraints of other
+	// type parameters, e.g. E in `[S ~[]E, E any]`.
+	for _, typeParam := range typeParams {
+		if typeArg, ok := inferrer.typeArgs[typeParam.Name]; ok {
+			if coreType := getCoreType(ctx.Package, typeParam.Constraint); coreType != nil {
+				inferrer.unify(coreType, underlyingType(ctx, typeArg))
+			}
+		}
+	}
+	for _, typeParam := range typeParams {
+		if _, ok := inferrer.typeArgs[typeParam.Name]; !ok {
+			// This can happen when a type parameter is only used
+			// in containers that are empty, so we don't know what's
+			// in them.
+			inferrer.typeArgs[typeParam.Name] = unknownType
+		}
+	}
+	result := &FunctionValue{
+		fn.FuncDecl,
+		fn.BoundVariables,
+		fn.CapturedVars,
+		inferrer.typeArgs,
+		fn.Globals,
+	}
+	checkTypeArgs(result)
+	return result
+}
+
+// Get the declared type of the parameter that the argument at the given index
+// is passed to, where numFixed is the number of params that aren't variadic.
+func getParamType(funcType *apast.FuncTypeExpr, numFixed int, index int) apast.Expr {
+	if index < numFixed {
+		return funcType.ParamTypes[index]
+	}
+	return funcType.ParamTypes[numFixed].(*apast.SliceTypeExpr).Elem
+}
+
+func (inf *typeInferrer) isTypeParam(typeExpr apast.Expr) bool {
+	ident, ok := typeExpr.(*apast.IdentExpr)
+	return ok && inf.typeParams[ident.Name]
+}
+
+// Untyped constants are represented using their default type, ordered here by
+// the rules for constant expressions. When constants of different kinds are
+// passed for the same type parameter, the one with the highest rank determines
+// the type, e.g. float64 for `Max(1, 2.5)`.
+var untypedConstRanks = map[reflect.Type]int{
+	reflect.TypeOf(0): 1,
+	reflect.TypeOf('a'): 2,
+	reflect.TypeOf(0.0): 3,
+	reflect.TypeOf(0i): 4,
+}
+
+func (inf *typeInferrer) unifyUntyped(name string, val Value) {
+	rank := untypedConstRanks[reflect.TypeOf(val.AsNative())]
+	prevRank, isUntyped := inf.untypedRanks[name]
+	if _, ok := inf.typeArgs[name]; ok && (!isUntyped || prevRank >= rank) {
+		return
+	}
+	inf.typeArgs[name] = typeOfValue(inf.ctx, val)
+	inf.untypedRanks[name] = rank
+}
+
+// Match a parameter type against the resolved type of an argument, inferring
+// any type parameters that appear in the parameter type.
+func (inf *typeInferrer) unify(paramType apast.Expr, argType apast.Expr) {
+	if isUnknownType(argType) {
+		return
+	}
+	switch paramType := paramType.(type) {
+	case *apast.IdentExpr:
+		if _, ok := inf.typeArgs[paramType.Name]; !ok && inf.typeParams[paramType.Name] {
+			inf.typeArgs[paramType.Name] = argType
+		}
+		return
+	case *apast.InstantiatedTypeExpr:
+		if argType, ok := argType.(*apast.InstantiatedTypeExpr); ok && argType.Name == paramType.Name {
+			for i, typeArg := range paramType.TypeArgs {
+				inf.unify(typeArg, argType.TypeArgs[i])
+			}
+		}
+		return
+	case *apast.PointerTypeExpr:
+		if argType, ok := argType.(*apast.PointerTypeExpr); ok {
+			inf.unify(paramType.Elem, argType.Elem)
+		}
+		return
+	}
+
+	// Other types can match values of named types with that underlying
+	// type, e.g. []T matches IntList.
+	argType = underlyingType(inf.ctx, argType)
+	var nativeType reflect.Type
+	if nativeArgType, ok := argType.(*apast.NativeTypeExpr); ok {
+		nativeType = nativeArgType.Type
+	}
+	switch paramType := paramType.(type) {
+	case *apast.SliceTypeExpr:
+		if argType, ok := argType.(*apast.SliceTypeExpr); ok {
+			inf.unify(paramType.Elem, argType.Elem)
+		} else if nativeType != nil && nativeType.Kind() == reflect.Slice {
+			inf.unify(paramType.Elem, &apast.NativeTypeExpr{nativeType.Elem()})
+		}
+	case *apast.ArrayTypeExpr:
+		if argType, ok := argType.(*apast.ArrayTypeExpr); ok {
+			inf.unify(paramType.Elem, argType.Elem)
+		} else if nativeType != nil && nativeType.Kind() == reflect.Array {
+			inf.unify(paramType.Elem, &apast.NativeTypeExpr{nativeType.Elem()})
+		}
+	case *apast.MapTypeExpr:
+		if argType, ok := argType.(*apast.MapTypeExpr); ok {
+			inf.unify(paramType.Key, argType.Key)
+			inf.unify(paramType.Elem, argType.Elem)
+		} else if nativeType != nil && nativeType.Kind() == reflect.Map {
+			inf.unify(paramType.Key, &apast.NativeTypeExpr{nativeType.Key()})
+			inf.unify(paramType.Elem, &apast.NativeTypeExpr{nativeType.Elem()})
+		}
+	case *apast.FuncTypeExpr:
+		if argType, ok := argType.(*apast.FuncTypeExpr); ok {
+			for i := 0; i < len(paramType.ParamTypes) && i < len(argType.ParamTypes); i++ {
+				inf.unify(paramType.ParamTypes[i], argType.ParamTypes[i])
+			}
+			for i := 0; i < len(paramType.ResultTypes) && i < len(argType.ResultTypes); i++ {
+				inf.unify(paramType.ResultTypes[i], argType.ResultTypes[i])
+			}
+		} else if nativeType != nil && nativeType.Kind() == reflect.Func {
+			for i := 0; i < len(paramType.ParamTypes) && i < nativeType.NumIn(); i++ {
+				inf.unify(paramType.ParamTypes[i], &apast.NativeTypeExpr{nativeType.In(i)})
+			}
+			for i := 0; i < len(paramType.ResultTypes) && i < nativeType.NumOut(); i++ {
+				inf.unify(paramType.ResultTypes[i], &apast.NativeTypeExpr{nativeType.Out(i)})
+			}
+		}
+	}
+}
+
+// Get the single type that a constraint allows (ignoring ~), like []E for
+// `~[]E`, or nil if there isn't one.
+func getCoreType(pack *apast.Package, constraint apast.Expr) apast.Expr {
+	interfaceType := getInterfaceType(pack, constraint)
+	if interfaceType == nil || len(interfaceType.Unions) != 1 ||
+			len(interfaceType.Unions[0].Terms) != 1 {
+		return nil
+	}
+	return interfaceType.Unions[0].Terms[0].Type
+}
+
+// Check that all type arguments of the instantiated function satisfy their
+// constraints. Since we assume that the code compiles, this can only fail if
+// type inference went wrong.
+func checkTypeArgs(fn *FunctionValue) {
+	ctx := funcContext(fn)
+	for _, typeParam := range fn.FuncDecl.TypeParams {
+		typeArg := fn.TypeArgs[typeParam.Name]
+		if !satisfiesConstraint(ctx, typeArg, typeParam.Constraint) {
+			panic(fmt.Sprint(describeType(ctx.Package, typeArg), " does not satisfy the constraint of ", typeParam.Name))
+		}
+	}
+}
+
+// Returns true if the resolved type is in the type set of the constraint.
+func satisfiesConstraint(ctx *Context, typeArg apast.Expr, constraint apast.Expr) bool {
+	if isUnknownType(typeArg) {
+		return true
+	}
+	if ident, ok := constraint.(*apast.IdentExpr); ok && ident.Name == "comparable" {
+		return isComparableType(ctx, typeArg)
+	}
+	interfaceType := getInterfaceType(ctx.Package, resolveTypeParam(ctx, constraint))
+	if interfaceType == nil {
+		panic(fmt.Sprint("Expected interface as constraint, got ", constraint))
+	}
+	for _, methodName := range interfaceType.MethodNames {
+		if !typeHasMethod(ctx.Package, typeArg, methodName) {
+			return false
+		}
+	}
+	for _, embedded := range interfaceType.Embedded {
+		if !satisfiesConstraint(ctx, typeArg, embedded) {
+			return false
+		}
+	}
+	for _, union := range interfaceType.Unions {
+		if !inTypeUnion(ctx, typeArg, union) {
+			return false
+		}
+	}
+	return true
+}
+
+func inTypeUnion(ctx *Context, typeArg apast.Expr, union *apast.TypeUnion) bool {
+	for _, term := range union.Terms {
+		termType := resolveType(ctx, term.Type)
+		if getInterfaceType(ctx.Package, termType) != nil {
+			// Interfaces in a union contribute their whole type set.
+			if satisfiesConstraint(ctx, typeArg, termType) {
+				return true
+			}
+		} else if typeExprsEqual(typeArg, termType) {
+			return true
+		} else if term.Tilde && typeExprsEqual(underlyingType(ctx, typeArg), termType) {
+			return true
+		}
+	}
+	return false
+}
+
+func isComparableType(ctx *Context, typeArg apast.Expr) bool {
+	switch underlying := underlyingType(ctx, typeArg).(type) {
+	case *apast.NativeTypeExpr:
+		return underlying.Type.Comparable()
+	case *apast.SliceTypeExpr, *apast.MapTypeExpr, *apast.FuncTypeExpr:
+		return false
+	default:
+		return true
+	}
+}
+
+// Returns true if the method set of the resolved type includes the given
+// method.
+func typeHasMethod(pack *apast.Package, typeArg apast.Expr, name string) bool {
+	isPointer := false
+	if pointerType, ok := typeArg.(*apast.PointerTypeExpr); ok {
+		typeArg = pointerType.Elem
+		isPointer = true
+	}
+	switch typeArg := typeArg.(type) {
+	case *apast.NativeTypeExpr:
+		_, ok := typeArg.Type.MethodByName(name)
+		return ok
+	case *apast.IdentExpr:
+		if interfaceType := getInterfaceType(pack, typeArg); interfaceType != nil {
+			for _, methodName := range getInterfaceMethodNames(pack, interfaceType) {
+				if methodName == name {
+					return true
+				}
+			}
+			return false
+		}
+		return inMethodSet(findMethodSetSelection(pack, typeArg.Name, name), isPointer)
+	case *apast.InstantiatedTypeExpr:
+		return inMethodSet(findMethodSetSelection(pack, typeArg.Name, name), isPointer)
+	default:
+		return false
+	}
+}
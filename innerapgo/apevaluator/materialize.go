@@ -0,0 +1,393 @@
+package apevaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"go/ast"
+	"reflect"
+)
+
+// Native code that inspects values with reflection, like encoding/json or fmt's
+// %v, needs real Go values rather than StructValues. Interpreted values passed
+// to native code are materialized: structs are converted to values of a struct
+// type created with reflect.StructOf from the struct's declaration, including
+// its tags, and containers and pointers holding them are converted too.
+// reflect.StructOf can't create unexported fields, so those are left out, and
+// recursive types use interface{} fields where they refer to themselves.
+// Decoders fill those in with generic values, like encoding/json's
+// map[string]interface{}, which are converted back; see fromGenericValue.
+
+// Returns true if the value, or a value it holds, is of an interpreted type
+// that native code can't use directly.
+func needsMaterializing(val Value) bool {
+	switch val := val.(type) {
+	case *StructValue:
+		return true
+	case *NamedValue:
+		return needsMaterializing(val.Val)
+	case *PointerValue:
+		if val.Target == nil {
+			return false
+		}
+		// Pointers to native values already have a native address; see
+		// PointerValue.AsNative.
+		target := val.Target.get()
+		_, isNative := target.(*NativeValue)
+		return !isNative || needsMaterializing(target)
+	case *NativeValue:
+		return val.val != nil && containsValueType(reflect.TypeOf(val.AsNative()))
+	default:
+		return false
+	}
+}
+
+func containsValueType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Ptr:
+		return containsValueType(t.Elem())
+	case reflect.Map:
+		// Maps with interpreted key types have interface{} keys holding
+		// comparable keys; see toMapKey.
+		return t.Key() == interfaceType || containsValueType(t.Key()) || containsValueType(t.Elem())
+	default:
+		return t == valueType
+	}
+}
+
+// Convert a value to a native value of its materialized type.
+func materializeValue(ctx *Context, val Value) reflect.Value {
+	typeExpr := typeOfValue(ctx, val)
+	if nativeVal, ok := val.(*NativeValue); ok {
+		// Native containers of interpreted values, like a []Value, may
+		// hold values of different types, so each element is
+		// materialized as its own type.
+		typeExpr = interpretedTypeExpr(reflect.TypeOf(nativeVal.AsNative()))
+	}
+	return materialize(ctx, typeExpr, nativeTypeOf(ctx, typeExpr, map[string]bool{}), val)
+}
+
+// Get the native type that values of the given type are materialized as. The
+// visiting set holds the named types being materialized, to detect recursive
+// types, which return nil until they reach a struct field.
+func nativeTypeOf(ctx *Context, typeExpr apast.Expr, visiting map[string]bool) reflect.Type {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if typeName, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		key := describeType(ctx.Package, namedTypeExpr(typeName, typeArgs))
+		if visiting[key] {
+			return nil
+		}
+		visiting[key] = true
+		defer delete(visiting, key)
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		return nativeTypeOf(typeCtx, typeDecl.Underlying, visiting)
+	}
+	if getInterfaceType(ctx.Package, typeExpr) != nil {
+		if nativeType, ok := typeExpr.(*apast.NativeTypeExpr); ok {
+			return nativeType.Type
+		}
+		return interfaceType
+	}
+	switch typeExpr := typeExpr.(type) {
+	case *apast.StructTypeExpr:
+		fields := []reflect.StructField{}
+		for _, field := range typeExpr.Fields {
+			if !ast.IsExported(field.Name) {
+				continue
+			}
+			fieldType := nativeTypeOf(ctx, field.Type, visiting)
+			if fieldType == nil {
+				fieldType = interfaceType
+			}
+			fields = append(fields, reflect.StructField{
+				Name: field.Name,
+				Type: fieldType,
+				Tag: reflect.StructTag(field.Tag),
+				// Only embedded structs without methods can be
+				// embedded in a type made by reflect.StructOf.
+				Anonymous: field.Embedded && fieldType.Kind() == reflect.Struct &&
+					fieldType.NumMethod() == 0,
+			})
+		}
+		return reflect.StructOf(fields)
+	case *apast.PointerTypeExpr:
+		elem := nativeTypeOf(ctx, typeExpr.Elem, visiting)
+		if elem == nil {
+			return nil
+		}
+		return reflect.PtrTo(elem)
+	case *apast.SliceTypeExpr:
+		elem := nativeTypeOf(ctx, typeExpr.Elem, visiting)
+		if elem == nil {
+			return nil
+		}
+		return reflect.SliceOf(elem)
+	case *apast.ArrayTypeExpr:
+		elem := nativeTypeOf(ctx, typeExpr.Elem, visiting)
+		if elem == nil {
+			return nil
+		}
+		return reflect.ArrayOf(typeExpr.Len, elem)
+	case *apast.MapTypeExpr:
+		key := nativeTypeOf(ctx, typeExpr.Key, visiting)
+		elem := nativeTypeOf(ctx, typeExpr.Elem, visiting)
+		if key == nil || elem == nil {
+			return nil
+		}
+		return reflect.MapOf(key, elem)
+	case *apast.FuncTypeExpr:
+		params := []reflect.Type{}
+		for _, paramType := range typeExpr.ParamTypes {
+			param := nativeTypeOf(ctx, paramType, visiting)
+			if param == nil {
+				return nil
+			}
+			params = append(params, param)
+		}
+		results := []reflect.Type{}
+		for _, resultType := range typeExpr.ResultTypes {
+			result := nativeTypeOf(ctx, resultType, visiting)
+			if result == nil {
+				return nil
+			}
+			results = append(results, result)
+		}
+		return reflect.FuncOf(params, results, typeExpr.IsVariadic)
+	case *apast.NativeTypeExpr:
+		if containsValueType(typeExpr.Type) {
+			return nativeTypeOf(ctx, interpretedTypeExpr(typeExpr.Type), visiting)
+		}
+		return typeExpr.Type
+	default:
+		return evaluateType(ctx, typeExpr)
+	}
+}
+
+// Get a type expression for a native container type holding interpreted
+// values, like []Value, which is materialized with interface{} elements.
+func interpretedTypeExpr(t reflect.Type) apast.Expr {
+	if !containsValueType(t) {
+		return &apast.NativeTypeExpr{t}
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		return &apast.SliceTypeExpr{interpretedTypeExpr(t.Elem())}
+	case reflect.Array:
+		return &apast.ArrayTypeExpr{t.Len(), interpretedTypeExpr(t.Elem())}
+	case reflect.Map:
+		return &apast.MapTypeExpr{interpretedTypeExpr(t.Key()), interpretedTypeExpr(t.Elem())}
+	case reflect.Ptr:
+		return &apast.PointerTypeExpr{interpretedTypeExpr(t.Elem())}
+	default:
+		return &apast.InterfaceTypeExpr{nil, nil, nil, nil}
+	}
+}
+
+// Convert a value of the given type to the native type t that it's
+// materialized as.
+func materialize(ctx *Context, typeExpr apast.Expr, t reflect.Type, val Value) reflect.Value {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if nativeVal, ok := val.(*NativeValue); ok && nativeVal.val == nil {
+		return reflect.Zero(t)
+	}
+	if t.Kind() == reflect.Interface {
+		// Interface types, and recursive types, hold whatever the
+		// value's own type is.
+		return toNativeValue(ctx, val, t)
+	}
+	if _, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		if namedVal, ok := val.(*NamedValue); ok {
+			val = namedVal.Val
+		}
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		return materialize(typeCtx, typeDecl.Underlying, t, val)
+	}
+	switch typeExpr := typeExpr.(type) {
+	case *apast.StructTypeExpr:
+		structVal := val.(*StructValue)
+		result := reflect.New(t).Elem()
+		for _, field := range typeExpr.Fields {
+			if !ast.IsExported(field.Name) {
+				continue
+			}
+			fieldVal := result.FieldByName(field.Name)
+			fieldVal.Set(materialize(ctx, field.Type, fieldVal.Type(), structVal.Values[field.Name]))
+		}
+		return result
+	case *apast.PointerTypeExpr:
+		pointer := val.(*PointerValue)
+		if !needsMaterializing(pointer) {
+			return toReflectValue(pointer, t)
+		}
+		target := pointer.Target.get()
+		result := reflect.New(t.Elem())
+		result.Elem().Set(materialize(ctx, typeExpr.Elem, t.Elem(), target))
+		return result
+	case *apast.SliceTypeExpr:
+		rv := reflect.ValueOf(val.AsNative())
+		if rv.Type() == t {
+			return rv
+		}
+		if rv.IsNil() {
+			return reflect.Zero(t)
+		}
+		result := reflect.MakeSlice(t, rv.Len(), rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			result.Index(i).Set(materialize(ctx, typeExpr.Elem, t.Elem(), fromReflectValue(rv.Index(i))))
+		}
+		return result
+	case *apast.ArrayTypeExpr:
+		rv := reflect.ValueOf(val.AsNative())
+		if rv.Type() == t {
+			return rv
+		}
+		result := reflect.New(t).Elem()
+		for i := 0; i < rv.Len(); i++ {
+			result.Index(i).Set(materialize(ctx, typeExpr.Elem, t.Elem(), fromReflectValue(rv.Index(i))))
+		}
+		return result
+	case *apast.MapTypeExpr:
+		rv := reflect.ValueOf(val.AsNative())
+		if rv.Type() == t {
+			return rv
+		}
+		if rv.IsNil() {
+			return reflect.Zero(t)
+		}
+		result := reflect.MakeMapWithSize(t, rv.Len())
+		for _, key := range rv.MapKeys() {
+			result.SetMapIndex(
+				materialize(ctx, typeExpr.Key, t.Key(), fromMapKey(rv, key)),
+				materialize(ctx, typeExpr.Elem, t.Elem(), fromReflectValue(rv.MapIndex(key))))
+		}
+		return result
+	case *apast.NativeTypeExpr:
+		if containsValueType(typeExpr.Type) {
+			return materialize(ctx, interpretedTypeExpr(typeExpr.Type), t, val)
+		}
+		return toReflectValue(val, t)
+	default:
+		return toReflectValue(val, t)
+	}
+}
+
+// Convert a materialized value back to a value of the given type. Native code
+// may have modified the value in place, like json.Unmarshal does, so the old
+// value, if there is one, is updated rather than replaced. Fields left out of
+// the materialized type keep their old values.
+func fromNative(ctx *Context, typeExpr apast.Expr, rv reflect.Value, old Value) Value {
+	typeExpr = resolveTypeParam(ctx, typeExpr)
+	if rv.Kind() == reflect.Interface && getInterfaceType(ctx.Package, typeExpr) == nil {
+		// A recursive type materialized as interface{}.
+		if rv.IsNil() {
+			return zeroValue(ctx, typeExpr)
+		}
+		rv = fromGenericValue(ctx, typeExpr, rv.Elem())
+	}
+	if typeName, typeArgs, typeDecl, ok := lookupNamedType(ctx, typeExpr); ok {
+		if namedVal, ok := old.(*NamedValue); ok {
+			old = namedVal.Val
+		}
+		typeCtx := newTypeContext(ctx, typeDecl.TypeParams, typeArgs)
+		switch underlyingVal := fromNative(typeCtx, typeDecl.Underlying, rv, old).(type) {
+		case *StructValue:
+			underlyingVal.TypeName = typeName
+			underlyingVal.TypeArgs = typeArgs
+			return underlyingVal
+		case *NamedValue:
+			return &NamedValue{typeName, typeArgs, underlyingVal.Val}
+		default:
+			return &NamedValue{typeName, typeArgs, underlyingVal}
+		}
+	}
+	switch typeExpr := typeExpr.(type) {
+	case *apast.StructTypeExpr:
+		structVal, ok := old.(*StructValue)
+		if !ok {
+			structVal = zeroValue(ctx, typeExpr).(*StructValue)
+		}
+		for _, field := range typeExpr.Fields {
+			if !ast.IsExported(field.Name) {
+				continue
+			}
+			structVal.Values[field.Name] = fromNative(ctx, field.Type, rv.FieldByName(field.Name), structVal.Values[field.Name])
+		}
+		return structVal
+	case *apast.PointerTypeExpr:
+		if evaluateType(ctx, typeExpr) != valueType {
+			return fromReflectValue(rv)
+		}
+		if rv.IsNil() {
+			return zeroValue(ctx, typeExpr)
+		}
+		if pointer, ok := old.(*PointerValue); ok && pointer.Target != nil {
+			pointer.Target.set(fromNative(ctx, typeExpr.Elem, rv.Elem(), pointer.Target.get()))
+			return pointer
+		}
+		return &PointerValue{&AllocatedLValue{fromNative(ctx, typeExpr.Elem, rv.Elem(), nil)}, nil}
+	case *apast.SliceTypeExpr, *apast.ArrayTypeExpr:
+		t := evaluateType(ctx, typeExpr)
+		if rv.Type() == t {
+			return fromReflectValue(rv)
+		}
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return zeroValue(ctx, typeExpr)
+		}
+		var result reflect.Value
+		var elemType apast.Expr
+		if sliceType, ok := typeExpr.(*apast.SliceTypeExpr); ok {
+			result = reflect.MakeSlice(t, rv.Len(), rv.Len())
+			elemType = sliceType.Elem
+		} else {
+			result = reflect.New(t).Elem()
+			elemType = typeExpr.(*apast.ArrayTypeExpr).Elem
+		}
+		for i := 0; i < rv.Len(); i++ {
+			result.Index(i).Set(toReflectValue(fromNative(ctx, elemType, rv.Index(i), nil), t.Elem()))
+		}
+		return fromReflectValue(result)
+	case *apast.MapTypeExpr:
+		t := evaluateType(ctx, typeExpr)
+		if rv.Type() == t {
+			return fromReflectValue(rv)
+		}
+		if rv.IsNil() {
+			return zeroValue(ctx, typeExpr)
+		}
+		result := reflect.MakeMapWithSize(t, rv.Len())
+		for _, key := range rv.MapKeys() {
+			result.SetMapIndex(
+				toMapKey(fromNative(ctx, typeExpr.Key, key, nil), t.Key()),
+				toReflectValue(fromNative(ctx, typeExpr.Elem, rv.MapIndex(key), nil), t.Elem()))
+		}
+		return fromReflectValue(result)
+	case *apast.NativeTypeExpr:
+		if containsValueType(typeExpr.Type) {
+			return fromNative(ctx, interpretedTypeExpr(typeExpr.Type), rv, old)
+		}
+		return fromReflectValue(rv)
+	default:
+		return fromReflectValue(rv)
+	}
+}
+
+// Convert a value that native code stored in the interface{} field of a
+// recursive type to the type that the field's type is materialized as. Values
+// that were materialized keep that type, but decoders like encoding/json
+// replace them with generic values, like a map[string]interface{} for a JSON
+// object, so those are encoded as JSON again and decoded into the right type.
+func fromGenericValue(ctx *Context, typeExpr apast.Expr, rv reflect.Value) reflect.Value {
+	t := nativeTypeOf(ctx, typeExpr, map[string]bool{})
+	if rv.Type() == t {
+		return rv
+	}
+	data, err := json.Marshal(rv.Interface())
+	if err == nil {
+		result := reflect.New(t)
+		if err = json.Unmarshal(data, result.Interface()); err == nil {
+			return result.Elem()
+		}
+	}
+	panic(fmt.Sprint("Cannot convert native value of type ", rv.Type(), " to ",
+		describeType(ctx.Package, typeExpr), ": ", err))
+}
@@ -0,0 +1,179 @@
+package apevaluator
+
+import (
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Describe a value passed to panic, in the format Go uses when a program
+// crashes: errors and Stringers are described with their methods, values of
+// basic kinds are printed with their named type, if any, like
+// `main.Celsius(5)`, and other values are printed as their type and address,
+// like `(*main.Point) 0xc000012345`. Panics can also come from the interpreter
+// itself, like runtime errors, which are described the same way.
+func DescribePanic(globals *Globals, pack *apast.Package, val interface{}) string {
+	value, ok := val.(Value)
+	if !ok {
+		return describeNativePanic(val)
+	}
+	for _, methodName := range []string{"Error", "String"} {
+		if _, ok := value.(*NativeValue); !ok && hasMethod(pack, value, methodName) {
+			ctx := NewContext(globals, pack)
+			method := evaluateSelector(ctx, &RValue{value}, methodName).get()
+			return fmt.Sprint(callFunc(ctx, method, []Value{}, false)[0].AsNative())
+		}
+	}
+	var address uintptr
+	switch value := value.(type) {
+	case *NativeValue:
+		if !containsValueType(reflect.TypeOf(value.AsNative())) {
+			return describeNativePanic(value.AsNative())
+		}
+		address = nativeAddress(reflect.ValueOf(value.AsNative()))
+	case *NamedValue:
+		if underlying, ok := value.Val.(*NativeValue); ok {
+			if desc, ok := describeBasicValue(reflect.ValueOf(underlying.AsNative())); ok {
+				return describeNamedBasicValue(displayTypeName(pack, value.TypeName), underlying.AsNative(), desc)
+			}
+		}
+		address = reflect.ValueOf(value).Pointer()
+	case *PointerValue:
+		address = pointerAddress(value)
+	default:
+		address = reflect.ValueOf(value).Pointer()
+	}
+	typeExpr := typeOfValue(NewContext(globals, pack), value)
+	return fmt.Sprintf("(%s) %#x", describeType(pack, typeExpr), address)
+}
+
+func describeNativePanic(val interface{}) string {
+	switch val := val.(type) {
+	case nil:
+		return "panic called with nil argument (goexit=false)"
+	case error:
+		return val.Error()
+	case fmt.Stringer:
+		return val.String()
+	}
+	rv := reflect.ValueOf(val)
+	desc, ok := describeBasicValue(rv)
+	if !ok {
+		return fmt.Sprintf("(%s) %#x", rv.Type(), nativeAddress(rv))
+	}
+	// Predeclared types have no package.
+	if rv.Type().PkgPath() == "" {
+		return desc
+	}
+	return describeNamedBasicValue(rv.Type().String(), val, desc)
+}
+
+// Print a value of a basic kind, like a number or string, or return false if
+// the value isn't of a basic kind.
+func describeBasicValue(rv reflect.Value) (string, bool) {
+	switch rv.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return fmt.Sprint(rv.Interface()), true
+	default:
+		return "", false
+	}
+}
+
+// Strings of named types are quoted, but not escaped, like `main.Name("x")`.
+func describeNamedBasicValue(typeName string, val interface{}, desc string) string {
+	if reflect.ValueOf(val).Kind() == reflect.String {
+		desc = "\"" + desc + "\""
+	}
+	return typeName + "(" + desc + ")"
+}
+
+// Get the address Go prints for a native value that isn't of a basic kind,
+// which is where the value is stored, or the pointer itself for references.
+func nativeAddress(rv reflect.Value) uintptr {
+	switch rv.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice, reflect.UnsafePointer:
+		return rv.Pointer()
+	default:
+		copied := reflect.New(rv.Type())
+		copied.Elem().Set(rv)
+		return copied.Pointer()
+	}
+}
+
+// Describe a resolved type for an error message, like `*geom.Point`.
+func describeType(pack *apast.Package, typeExpr apast.Expr) string {
+	switch typeExpr := typeExpr.(type) {
+	case nil:
+		return "nil"
+	case *apast.IdentExpr:
+		return displayTypeName(pack, typeExpr.Name)
+	case *apast.InstantiatedTypeExpr:
+		typeArgs := []string{}
+		for _, typeArg := range typeExpr.TypeArgs {
+			typeArgs = append(typeArgs, describeType(pack, typeArg))
+		}
+		return fmt.Sprint(displayTypeName(pack, typeExpr.Name), "[", strings.Join(typeArgs, ","), "]")
+	case *apast.PointerTypeExpr:
+		return "*" + describeType(pack, typeExpr.Elem)
+	case *apast.SliceTypeExpr:
+		return "[]" + describeType(pack, typeExpr.Elem)
+	case *apast.ArrayTypeExpr:
+		return fmt.Sprint("[", typeExpr.Len, "]", describeType(pack, typeExpr.Elem))
+	case *apast.MapTypeExpr:
+		return "map[" + describeType(pack, typeExpr.Key) + "]" + describeType(pack, typeExpr.Elem)
+	case *apast.FuncTypeExpr:
+		params := describeTypes(pack, typeExpr.ParamTypes)
+		if typeExpr.IsVariadic {
+			params[len(params) - 1] = "..." + strings.TrimPrefix(params[len(params) - 1], "[]")
+		}
+		result := "func(" + strings.Join(params, ", ") + ")"
+		results := describeTypes(pack, typeExpr.ResultTypes)
+		if len(results) == 1 {
+			result += " " + results[0]
+		} else if len(results) > 1 {
+			result += " (" + strings.Join(results, ", ") + ")"
+		}
+		return result
+	case *apast.StructTypeExpr:
+		if len(typeExpr.Fields) == 0 {
+			return "struct {}"
+		}
+		fields := []string{}
+		for _, field := range typeExpr.Fields {
+			desc := describeType(pack, field.Type)
+			if !field.Embedded {
+				desc = field.Name + " " + desc
+			}
+			if field.Tag != "" {
+				desc += " " + strconv.Quote(field.Tag)
+			}
+			fields = append(fields, desc)
+		}
+		return "struct { " + strings.Join(fields, "; ") + " }"
+	case *apast.InterfaceTypeExpr:
+		if len(typeExpr.MethodNames) == 0 && len(typeExpr.Embedded) == 0 {
+			return "interface {}"
+		}
+		return fmt.Sprint(typeExpr)
+	case *apast.NativeTypeExpr:
+		if containsValueType(typeExpr.Type) {
+			return describeType(pack, interpretedTypeExpr(typeExpr.Type))
+		}
+		return typeExpr.Type.String()
+	default:
+		return fmt.Sprint(typeExpr)
+	}
+}
+
+func describeTypes(pack *apast.Package, typeExprs []apast.Expr) []string {
+	result := []string{}
+	for _, typeExpr := range typeExprs {
+		result = append(result, describeType(pack, typeExpr))
+	}
+	return result
+}
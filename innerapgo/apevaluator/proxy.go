@@ -0,0 +1,244 @@
+package apevaluator
+
+import (
+	"errors"
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Native code can't see the methods of interpreted types, so interpreted values
+// passed to native code as a native interface, like error or sort.Interface,
+// are wrapped in a proxy: a native type with the interface's methods that calls
+// the interpreted methods. Go can't create types with methods at runtime, so
+// only the interfaces below are supported.
+
+// interpretedValue is the interpreted value held by a proxy. Go compares
+// interface values holding proxies, e.g. in errors.Is, so pointers are stored
+// as the location they point to, which copies of the pointer share.
+type interpretedValue struct {
+	// The value, or nil if it's a pointer.
+	val Value
+	// The location that the pointer points to, if it's a pointer.
+	target ExprResult
+	// The package that declared the value's type, which its methods are
+	// resolved in.
+	pack *apast.Package
+	globals *Globals
+}
+
+func (obj interpretedValue) value() Value {
+	if obj.target != nil {
+		return &PointerValue{obj.target, nil}
+	}
+	return obj.val
+}
+
+// Call an interpreted method with native arguments, and convert the results to
+// the given native types.
+func (obj interpretedValue) callMethod(name string, resultTypes []reflect.Type, args ...interface{}) []reflect.Value {
+	ctx := NewContext(obj.globals, obj.pack)
+	method := evaluateSelector(ctx, &RValue{obj.value()}, name).get()
+	argVals := []Value{}
+	for _, arg := range args {
+		argVals = append(argVals, fromReflectValue(reflect.ValueOf(arg)))
+	}
+	resultVals := []reflect.Value{}
+	for i, result := range callFunc(ctx, method, argVals, false) {
+		resultVals = append(resultVals, toNativeValue(ctx, result, resultTypes[i]))
+	}
+	return resultVals
+}
+
+type nativeProxy interface {
+	interpreted() Value
+}
+
+func (obj interpretedValue) interpreted() Value {
+	return obj.value()
+}
+
+var (
+	boolType = reflect.TypeOf(false)
+	stringType = reflect.TypeOf("")
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// Convert a native error result, which may be a nil interface.
+func toError(val reflect.Value) error {
+	err, _ := val.Interface().(error)
+	return err
+}
+
+type errorProxy struct {
+	interpretedValue
+}
+
+func (p errorProxy) Error() string {
+	return p.callMethod("Error", []reflect.Type{stringType})[0].String()
+}
+
+// errors.Is compares errors with ==, which only works for proxies of pointers,
+// since proxies of other values hold separate copies of them. Those are
+// compared by their interpreted values instead. Native code also can't see the
+// interpreted Is and Unwrap methods, so they're called here like errors.Is
+// would, and the rest of the chain is checked from here.
+func (p errorProxy) Is(target error) bool {
+	if targetProxy, ok := target.(errorProxy); ok && p.target == nil && targetProxy.target == nil &&
+			comparableKeysEqual(toComparable(p.val), toComparable(targetProxy.val)) {
+		return true
+	}
+	if hasMethod(p.pack, p.value(), "Is") && p.callMethod("Is", []reflect.Type{boolType}, target)[0].Bool() {
+		return true
+	}
+	if !hasMethod(p.pack, p.value(), "Unwrap") {
+		return false
+	}
+	ctx := NewContext(p.globals, p.pack)
+	err := callErrorMethod(ctx, p.value(), "Unwrap")
+	// Like errors.Join, Unwrap may return a slice of errors.
+	if errs, ok := nativeSlice(err); ok {
+		for i := 0; i < errs.Len(); i++ {
+			if errors.Is(toError(toNativeValue(ctx, fromReflectValue(errs.Index(i)), errorType)), target) {
+				return true
+			}
+		}
+		return false
+	}
+	return errors.Is(toError(toNativeValue(ctx, err, errorType)), target)
+}
+
+// Like errors.Is, values that aren't comparable, like structs with slice
+// fields, are never equal rather than panicking.
+func comparableKeysEqual(x interface{}, y interface{}) (equal bool) {
+	defer func() {
+		if recover() != nil {
+			equal = false
+		}
+	}()
+	return x == y
+}
+
+type stringerProxy struct {
+	interpretedValue
+}
+
+func (p stringerProxy) String() string {
+	return p.callMethod("String", []reflect.Type{stringType})[0].String()
+}
+
+type sortProxy struct {
+	interpretedValue
+}
+
+func (p sortProxy) Len() int {
+	return int(p.callMethod("Len", []reflect.Type{intType})[0].Int())
+}
+
+func (p sortProxy) Less(i int, j int) bool {
+	return p.callMethod("Less", []reflect.Type{boolType}, i, j)[0].Bool()
+}
+
+func (p sortProxy) Swap(i int, j int) {
+	p.callMethod("Swap", []reflect.Type{}, i, j)
+}
+
+type readerProxy struct {
+	interpretedValue
+}
+
+func (p readerProxy) Read(buf []byte) (int, error) {
+	results := p.callMethod("Read", []reflect.Type{intType, errorType}, buf)
+	return int(results[0].Int()), toError(results[1])
+}
+
+type writerProxy struct {
+	interpretedValue
+}
+
+func (p writerProxy) Write(buf []byte) (int, error) {
+	results := p.callMethod("Write", []reflect.Type{intType, errorType}, buf)
+	return int(results[0].Int()), toError(results[1])
+}
+
+type proxyType struct {
+	iface reflect.Type
+	makeProxy func(obj interpretedValue) interface{}
+}
+
+// Proxies in order of preference, e.g. fmt prefers Error to String.
+var proxyTypes = []proxyType{
+	{errorType, func(obj interpretedValue) interface{} { return errorProxy{obj} }},
+	{stringerType, func(obj interpretedValue) interface{} { return stringerProxy{obj} }},
+	{reflect.TypeOf((*sort.Interface)(nil)).Elem(), func(obj interpretedValue) interface{} { return sortProxy{obj} }},
+	{reflect.TypeOf((*io.Reader)(nil)).Elem(), func(obj interpretedValue) interface{} { return readerProxy{obj} }},
+	{reflect.TypeOf((*io.Writer)(nil)).Elem(), func(obj interpretedValue) interface{} { return writerProxy{obj} }},
+}
+
+// Like toReflectValue, but values of interpreted types that implement the
+// native interface type t are wrapped in a proxy, and other interpreted values
+// are materialized (see materialize.go).
+func toNativeValue(ctx *Context, val Value, t reflect.Type) reflect.Value {
+	if t.Kind() == reflect.Interface && t != valueType {
+		if proxy, ok := makeProxy(ctx, val, t); ok {
+			return reflect.ValueOf(proxy).Convert(t)
+		}
+	}
+	if !containsValueType(t) && needsMaterializing(val) {
+		return materializeValue(ctx, val).Convert(t)
+	}
+	return toReflectValue(val, t)
+}
+
+func makeProxy(ctx *Context, val Value, t reflect.Type) (interface{}, bool) {
+	obj, ok := newInterpretedValue(ctx, val)
+	if !ok {
+		return nil, false
+	}
+	for _, proxy := range proxyTypes {
+		// Only error and fmt.Stringer are used for interface{}, since
+		// they change how native code like fmt treats the value.
+		if t.NumMethod() == 0 && proxy.iface != errorType && proxy.iface != stringerType {
+			continue
+		}
+		if proxy.iface.Implements(t) && implementsNative(ctx.Package, val, proxy.iface) {
+			return proxy.makeProxy(obj), true
+		}
+	}
+	return nil, false
+}
+
+// Returns true if the value has all of the methods of the native interface.
+func implementsNative(pack *apast.Package, val Value, iface reflect.Type) bool {
+	for i := 0; i < iface.NumMethod(); i++ {
+		if !hasMethod(pack, val, iface.Method(i).Name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Get the interpreted value for a proxy, if the value is of an interpreted type
+// or a pointer to one.
+func newInterpretedValue(ctx *Context, val Value) (interpretedValue, bool) {
+	if isNil(val) {
+		return interpretedValue{}, false
+	}
+	var target ExprResult
+	if pointer, ok := val.(*PointerValue); ok {
+		target = pointer.Target
+		val = target.get()
+	}
+	typeName, ok := getTypeName(val)
+	if !ok || typeName == "" {
+		return interpretedValue{}, false
+	}
+	pack := ctx.Package.Types[typeName].Package
+	if target != nil {
+		return interpretedValue{nil, target, pack, ctx.Globals}, true
+	}
+	return interpretedValue{val, nil, pack, ctx.Globals}, true
+}
@@ -0,0 +1,482 @@
+package apevaluator
+
+import (
+	"fmt"
+	"github.com/alangpierce/apgo/apast"
+	"reflect"
+)
+
+// A selection describes how to resolve a selector like `x.Name` on a value of
+// some interpreted type, which may involve following embedded fields.
+type selection struct {
+	// Names of the embedded fields to follow to get to the value that
+	// actually has the field or method.
+	path []string
+	// The field name, or "" if this is a method.
+	fieldName string
+	// The method, or nil if this is a field or a method of an embedded
+	// interface, which is resolved on the dynamic value.
+	method *apast.MethodDecl
+	// True if following the path goes through an embedded pointer, which
+	// makes pointer methods available even if the original value isn't a
+	// pointer.
+	throughPointer bool
+}
+
+type selectionCandidate struct {
+	typeName string
+	path []string
+	throughPointer bool
+}
+
+// Find the field or method with the given name. Like Go, this searches by
+// depth, so shallower fields and methods take precedence over deeper ones, and
+// it's an error for two to be found at the same depth. Returns nil if nothing
+// was found.
+func findSelection(pack *apast.Package, typeName string, name string) *selection {
+	sel, isAmbiguous := searchSelection(pack, typeName, name)
+	if isAmbiguous {
+		panic(fmt.Sprint("Ambiguous selector ", name, " on ", typeName))
+	}
+	return sel
+}
+
+// Find the method with the given name for checking the method set of a type.
+// Ambiguous selectors aren't in the method set, so they give nil rather than
+// an error.
+func findMethodSetSelection(pack *apast.Package, typeName string, name string) *selection {
+	sel, _ := searchSelection(pack, typeName, name)
+	return sel
+}
+
+// Search for a field or method as described in findSelection, also returning
+// whether the selector was ambiguous.
+func searchSelection(pack *apast.Package, typeName string, name string) (*selection, bool) {
+	candidates := []selectionCandidate{{typeName, nil, false}}
+	seenTypes := make(map[string]bool)
+	for len(candidates) > 0 {
+		matches := []*selection{}
+		nextCandidates := []selectionCandidate{}
+		for _, candidate := range candidates {
+			// Types seen at a shallower depth were already fully
+			// searched, so skip them (this also avoids infinite
+			// loops with recursive types).
+			if seenTypes[candidate.typeName] {
+				continue
+			}
+			typeDecl, ok := pack.Types[candidate.typeName]
+			if !ok {
+				continue
+			}
+			if method, ok := typeDecl.Methods[name]; ok {
+				matches = append(matches, &selection{
+					candidate.path,
+					"",
+					method,
+					candidate.throughPointer,
+				})
+			}
+			if interfaceType := getInterfaceType(pack, typeDecl.Underlying); interfaceType != nil {
+				for _, methodName := range getInterfaceMethodNames(pack, interfaceType) {
+					if methodName == name {
+						matches = append(matches, &selection{
+							candidate.path,
+							"",
+							nil,
+							candidate.throughPointer,
+						})
+					}
+				}
+			}
+			structType := getStructType(pack, candidate.typeName)
+			if structType == nil {
+				continue
+			}
+			for _, field := range structType.Fields {
+				if field.Name == name {
+					matches = append(matches, &selection{
+						candidate.path,
+						name,
+						nil,
+						candidate.throughPointer,
+					})
+				}
+				if field.Embedded && hasNativeSelector(field.Type, name) {
+					// Promoted from an embedded native type, so
+					// it's resolved on the field's value.
+					matches = append(matches, &selection{
+						appendPath(candidate.path, field.Name),
+						"",
+						nil,
+						candidate.throughPointer,
+					})
+				} else if field.Embedded && !isNativeType(field.Type) {
+					embeddedTypeName, isPointer := getEmbeddedTypeName(field.Type)
+					nextCandidates = append(nextCandidates, selectionCandidate{
+						embeddedTypeName,
+						appendPath(candidate.path, field.Name),
+						candidate.throughPointer || isPointer,
+					})
+				}
+			}
+		}
+		for _, candidate := range candidates {
+			seenTypes[candidate.typeName] = true
+		}
+		if len(matches) == 1 {
+			return matches[0], false
+		} else if len(matches) > 1 {
+			return nil, true
+		}
+		candidates = nextCandidates
+	}
+	return nil, false
+}
+
+func isNativeType(typeExpr apast.Expr) bool {
+	if pointerType, ok := typeExpr.(*apast.PointerTypeExpr); ok {
+		typeExpr = pointerType.Elem
+	}
+	_, ok := typeExpr.(*apast.NativeTypeExpr)
+	return ok
+}
+
+// Returns true if a native type, or a pointer to one, has a method or exported
+// field with the given name. Embedded fields are addressable, so this includes
+// pointer methods.
+func hasNativeSelector(typeExpr apast.Expr, name string) bool {
+	if pointerType, ok := typeExpr.(*apast.PointerTypeExpr); ok {
+		typeExpr = pointerType.Elem
+	}
+	nativeType, ok := typeExpr.(*apast.NativeTypeExpr)
+	if !ok {
+		return false
+	}
+	t := nativeType.Type
+	if t.Kind() == reflect.Interface {
+		_, ok := t.MethodByName(name)
+		return ok
+	}
+	if _, ok := reflect.PtrTo(t).MethodByName(name); ok {
+		return true
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	field, ok := t.FieldByName(name)
+	return ok && field.PkgPath == ""
+}
+
+// Copy the path so that different candidates don't share the same backing
+// array.
+func appendPath(path []string, name string) []string {
+	result := make([]string, len(path), len(path) + 1)
+	copy(result, path)
+	return append(result, name)
+}
+
+// Get the struct definition for a type name, following any named types defined
+// in terms of other named types. Returns nil if the type isn't a struct.
+func getStructType(pack *apast.Package, typeName string) *apast.StructTypeExpr {
+	typeDecl, ok := pack.Types[typeName]
+	if !ok {
+		return nil
+	}
+	switch underlying := typeDecl.Underlying.(type) {
+	case *apast.StructTypeExpr:
+		return underlying
+	case *apast.IdentExpr:
+		return getStructType(pack, underlying.Name)
+	default:
+		return nil
+	}
+}
+
+// Get the interface definition for a type expression, or nil if it isn't an
+// interface.
+func getInterfaceType(pack *apast.Package, typeExpr apast.Expr) *apast.InterfaceTypeExpr {
+	switch typeExpr := typeExpr.(type) {
+	case *apast.InterfaceTypeExpr:
+		return typeExpr
+	case *apast.IdentExpr:
+		if typeDecl, ok := pack.Types[typeExpr.Name]; ok {
+			return getInterfaceType(pack, typeDecl.Underlying)
+		}
+		return predeclaredInterfaces[typeExpr.Name]
+	case *apast.InstantiatedTypeExpr:
+		if typeDecl, ok := pack.Types[typeExpr.Name]; ok {
+			return getInterfaceType(pack, typeDecl.Underlying)
+		}
+	case *apast.NativeTypeExpr:
+		// Native interfaces, like io.Reader. Value is used for unknown
+		// types, so it isn't treated as an interface.
+		if typeExpr.Type.Kind() == reflect.Interface && typeExpr.Type != valueType {
+			methodNames := []string{}
+			methodTypes := []*apast.FuncTypeExpr{}
+			for i := 0; i < typeExpr.Type.NumMethod(); i++ {
+				methodNames = append(methodNames, typeExpr.Type.Method(i).Name)
+				methodTypes = append(methodTypes, nativeMethodType(typeExpr.Type, typeExpr.Type.Method(i).Name))
+			}
+			return &apast.InterfaceTypeExpr{methodNames, methodTypes, nil, nil}
+		}
+	}
+	return nil
+}
+
+var predeclaredInterfaces = map[string]*apast.InterfaceTypeExpr{
+	"error": {
+		[]string{"Error"},
+		[]*apast.FuncTypeExpr{{nil, []apast.Expr{&apast.NativeTypeExpr{reflect.TypeOf("")}}, false}},
+		nil,
+		nil,
+	},
+	"any": {[]string{}, []*apast.FuncTypeExpr{}, nil, nil},
+	// Comparability isn't a method, so it's checked separately by
+	// satisfiesConstraint.
+	"comparable": {[]string{}, []*apast.FuncTypeExpr{}, nil, nil},
+}
+
+// Get the signature of a method of an interface, including from embedded
+// interfaces, or nil if the interface doesn't have the method.
+func getInterfaceMethodType(pack *apast.Package, interfaceType *apast.InterfaceTypeExpr, name string) *apast.FuncTypeExpr {
+	for i, methodName := range interfaceType.MethodNames {
+		if methodName == name {
+			return interfaceType.MethodTypes[i]
+		}
+	}
+	for _, embedded := range interfaceType.Embedded {
+		if methodType := getInterfaceMethodType(pack, getInterfaceType(pack, embedded), name); methodType != nil {
+			return methodType
+		}
+	}
+	return nil
+}
+
+// Get all method names of an interface, including from embedded interfaces.
+func getInterfaceMethodNames(pack *apast.Package, interfaceType *apast.InterfaceTypeExpr) []string {
+	result := append([]string{}, interfaceType.MethodNames...)
+	for _, embedded := range interfaceType.Embedded {
+		embeddedInterface := getInterfaceType(pack, embedded)
+		if embeddedInterface == nil {
+			panic(fmt.Sprint("Expected embedded interface, got ", embedded))
+		}
+		result = append(result, getInterfaceMethodNames(pack, embeddedInterface)...)
+	}
+	return result
+}
+
+func getEmbeddedTypeName(typeExpr apast.Expr) (typeName string, isPointer bool) {
+	switch typeExpr := typeExpr.(type) {
+	case *apast.IdentExpr:
+		return typeExpr.Name, false
+	case *apast.InstantiatedTypeExpr:
+		return typeExpr.Name, false
+	case *apast.PointerTypeExpr:
+		typeName, _ := getEmbeddedTypeName(typeExpr.Elem)
+		return typeName, true
+	default:
+		panic(fmt.Sprint("Unexpected embedded type ", typeExpr))
+	}
+}
+
+// Evaluate a selector on the result of an expression, returning either an
+// assignable field or a method value. The expression result is needed, rather
+// than just its value, so that pointer methods can take its address.
+func evaluateSelector(ctx *Context, target ExprResult, name string) ExprResult {
+	// Selectors automatically dereference pointers.
+	val := target.get()
+	if pointer, ok := val.(*PointerValue); ok && pointer.Target == nil {
+		return evaluateNilPointerSelector(ctx, pointer, name)
+	} else if ok {
+		target = pointer.Target
+		val = target.get()
+	}
+	if nativeVal, ok := val.(*NativeValue); ok && !isNil(val) {
+		return evaluateNativeSelector(target, nativeVal, name)
+	}
+	if structVal, ok := val.(*StructValue); ok && structVal.TypeName == "" {
+		// Anonymous structs have no methods, so only look at the fields.
+		if _, ok := structVal.Values[name]; ok {
+			return &StructLValue{
+				structVal,
+				name,
+			}
+		}
+		panic(fmt.Sprint("Field not found: ", name))
+	}
+	typeName, ok := getTypeName(val)
+	if !ok && isNil(val) {
+		panic("runtime error: invalid memory address or nil pointer dereference")
+	} else if !ok {
+		panic(fmt.Sprint("Unsupported field access on ", val))
+	}
+	sel := findSelection(ctx.Package, typeName, name)
+	if sel == nil {
+		panic(fmt.Sprint("Field not found: ", name))
+	}
+	for _, embeddedName := range sel.path {
+		target = &StructLValue{
+			val.(*StructValue),
+			embeddedName,
+		}
+		val = target.get()
+		if pointer, ok := val.(*PointerValue); ok {
+			target = pointer.deref()
+			val = target.get()
+		} else if isNil(val) {
+			panic("runtime error: invalid memory address or nil pointer dereference")
+		}
+	}
+	if sel.fieldName == "" && sel.method == nil {
+		// Method of an embedded interface or native type, so look it
+		// up on the value itself.
+		return evaluateSelector(ctx, target, name)
+	}
+	if sel.method != nil {
+		return &RValue{
+			createMethodValue(ctx, sel.method, target),
+		}
+	}
+	return &StructLValue{
+		val.(*StructValue),
+		sel.fieldName,
+	}
+}
+
+// Methods with pointer receivers can be called on nil pointers, which is the
+// only selector that doesn't dereference the pointer.
+func evaluateNilPointerSelector(ctx *Context, pointer *PointerValue, name string) ExprResult {
+	if typeName, typeArgs, ok := splitNamedTypeExpr(pointer.Elem); ok {
+		sel := findSelection(ctx.Package, typeName, name)
+		if sel != nil && sel.method != nil && sel.method.IsPointer && len(sel.path) == 0 {
+			return &RValue{
+				bindMethod(ctx, sel.method, pointer, typeArgs),
+			}
+		}
+	}
+	panic("runtime error: invalid memory address or nil pointer dereference")
+}
+
+// Evaluate a selector on a native value using reflection, giving either a
+// method value or an exported field. Pointer methods are only available if the
+// target is addressable, and fields are only assignable if it's addressable or
+// a pointer.
+func evaluateNativeSelector(target ExprResult, nativeVal *NativeValue, name string) ExprResult {
+	rv := reflect.ValueOf(nativeVal.AsNative())
+	if method := rv.MethodByName(name); method.IsValid() {
+		return &RValue{&NativeValue{method.Interface()}}
+	}
+	if rv.Kind() != reflect.Ptr {
+		if addr, ok := addressNative(target, nativeVal); ok {
+			if method := addr.Addr().MethodByName(name); method.IsValid() {
+				return &RValue{&NativeValue{method.Interface()}}
+			}
+			rv = addr
+		}
+	} else if rv.IsNil() {
+		panic("runtime error: invalid memory address or nil pointer dereference")
+	} else {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Struct {
+		if field, ok := rv.Type().FieldByName(name); ok && field.PkgPath == "" {
+			fieldVal := rv.FieldByIndex(field.Index)
+			if fieldVal.CanSet() {
+				return &ReflectValLValue{fieldVal}
+			}
+			return &RValue{fromReflectValue(fieldVal)}
+		}
+	}
+	panic(fmt.Sprint("Field not found: ", name))
+}
+
+// Get an addressable reflect.Value for the native value at the given location.
+// Interpreted locations like variables have no native address, so the value is
+// moved to a new native location the first time one is needed, and later
+// calls use the same one. Returns false if the location isn't addressable,
+// like a map entry or the result of a function call.
+func addressNative(target ExprResult, nativeVal *NativeValue) (reflect.Value, bool) {
+	switch target := target.(type) {
+	case *ReflectValLValue:
+		return target.val, target.val.CanAddr() && target.val.Kind() != reflect.Interface
+	case *VariableLValue, *StructLValue, *AllocatedLValue:
+		location, ok := nativeVal.val.(*nativeLocation)
+		if !ok {
+			location = &nativeLocation{reflect.New(reflect.TypeOf(nativeVal.val)).Elem()}
+			location.val.Set(reflect.ValueOf(nativeVal.val))
+			nativeVal.val = location
+		}
+		return location.val, true
+	default:
+		return reflect.Value{}, false
+	}
+}
+
+// Follow the value if it's a pointer, or return it unchanged otherwise.
+func dereference(val Value) Value {
+	if pointer, ok := val.(*PointerValue); ok {
+		return pointer.deref().get()
+	}
+	if isNil(val) {
+		panic("runtime error: invalid memory address or nil pointer dereference")
+	}
+	return val
+}
+
+// Returns true if the value implements the interface, which may be any
+// interface type expression.
+func implementsInterface(pack *apast.Package, val Value, interfaceType *apast.InterfaceTypeExpr) bool {
+	for _, methodName := range getInterfaceMethodNames(pack, interfaceType) {
+		if !hasMethod(pack, val, methodName) {
+			return false
+		}
+	}
+	return true
+}
+
+func isNil(val Value) bool {
+	if pointer, ok := val.(*PointerValue); ok {
+		return pointer.Target == nil
+	}
+	nativeVal, ok := val.(*NativeValue)
+	return ok && nativeVal.val == nil
+}
+
+// Returns true if the method set of the value's type includes the given
+// method, including any promoted methods.
+func hasMethod(pack *apast.Package, val Value, name string) bool {
+	isPointer := false
+	if pointer, ok := val.(*PointerValue); ok && pointer.Target == nil {
+		typeName, _, ok := splitNamedTypeExpr(pointer.Elem)
+		return ok && inMethodSet(findMethodSetSelection(pack, typeName, name), true)
+	} else if ok {
+		val = pointer.Target.get()
+		isPointer = true
+		// Pointers to native values have the methods of the native
+		// pointer type, like *bytes.Buffer.
+		if nativeVal, ok := val.(*NativeValue); ok && !isNil(nativeVal) {
+			if addr, ok := addressNative(pointer.Target, nativeVal); ok {
+				return addr.Addr().MethodByName(name).IsValid()
+			}
+		}
+	}
+	if nativeVal, ok := val.(*NativeValue); ok && !isPointer {
+		return nativeVal.val != nil &&
+			reflect.ValueOf(nativeVal.AsNative()).MethodByName(name).IsValid()
+	}
+	typeName, ok := getTypeName(val)
+	if !ok {
+		return false
+	}
+	return inMethodSet(findMethodSetSelection(pack, typeName, name), isPointer)
+}
+
+// Returns true if the selection is a method in the method set of the type,
+// which only includes pointer methods if the type is a pointer type.
+func inMethodSet(sel *selection, isPointer bool) bool {
+	if sel == nil || sel.fieldName != "" {
+		return false
+	}
+	// Methods of embedded interfaces are always in the method set.
+	return sel.method == nil || isPointer || sel.throughPointer || !sel.method.IsPointer
+}
@@ -3,6 +3,7 @@ package apevaluator
 import (
 	"fmt"
 	"github.com/alangpierce/apgo/apast"
+	"reflect"
 )
 
 type Value interface {
@@ -13,28 +14,105 @@ type Value interface {
 	Copy() Value
 }
 
+// Values of interpreted types are stored in native containers (e.g. as the
+// elements of a []Celsius) as Value interfaces.
+var valueType = reflect.TypeOf((*Value)(nil)).Elem()
+
+// Convert a value to a reflect.Value of the given type so it can be stored in a
+// native container.
+func toReflectValue(val Value, t reflect.Type) reflect.Value {
+	if t == valueType {
+		return reflect.ValueOf(&val).Elem()
+	}
+	if fn, ok := val.(*FunctionValue); ok && t.Kind() == reflect.Func {
+		return makeNativeFunc(fn, t)
+	}
+	native := val.AsNative()
+	if native == nil {
+		return reflect.Zero(t)
+	}
+	result := reflect.ValueOf(native)
+	if !result.Type().AssignableTo(t) {
+		// This can happen for untyped constants, e.g. storing 1 in a
+		// []float64.
+		result = result.Convert(t)
+	}
+	return result
+}
+
+// Convert an interpreted function to a native func of the given type, so it can
+// be passed to native code like sort.Slice. Calling the func calls back into the
+// evaluator. Panics in the interpreted function are regular Go panics, so they
+// propagate through the native code that called it, like they would in Go.
+func makeNativeFunc(fn *FunctionValue, funcType reflect.Type) reflect.Value {
+	return reflect.MakeFunc(funcType, func(argVals []reflect.Value) []reflect.Value {
+		// Variadic arguments are already packed into a slice, which is
+		// what the interpreted function expects too.
+		args := []Value{}
+		for _, argVal := range argVals {
+			args = append(args, fromReflectValue(argVal))
+		}
+		resultVals := []reflect.Value{}
+		for i, result := range EvaluateFunc(fn, args) {
+			resultVals = append(resultVals, toNativeValue(funcContext(fn), result, funcType.Out(i)))
+		}
+		return resultVals
+	})
+}
+
+// Inverse of toReflectValue. Proxies (see toNativeValue) become the interpreted
+// values they hold again.
+func fromReflectValue(rv reflect.Value) Value {
+	if rv.Type() == valueType {
+		if rv.IsNil() {
+			return &NativeValue{nil}
+		}
+		return rv.Interface().(Value)
+	}
+	if proxy, ok := rv.Interface().(nativeProxy); ok {
+		return proxy.interpreted()
+	}
+	return &NativeValue{rv.Interface()}
+}
+
 type NativeValue struct {
+	// The native value, or a *nativeLocation once the value has been given
+	// an address (see addressNative). Use AsNative to get the value itself.
 	val interface{}
 }
 
+// nativeLocation is an addressable location holding a native value, so that
+// pointer methods and field assignments can modify it in place.
+type nativeLocation struct {
+	val reflect.Value
+}
+
 func (nv *NativeValue) AsNative() interface{} {
+	if location, ok := nv.val.(*nativeLocation); ok {
+		return location.val.Interface()
+	}
 	return nv.val
 }
 
 func (nv *NativeValue) Copy() Value {
-	// TODO: Verify this when the true fate of NativeValue is more certain.
+	// The copy is a separate value, so it doesn't share the location.
 	return &NativeValue{
-		nv.val,
+		nv.AsNative(),
 	}
 }
 
 func (nv *NativeValue) String() string {
-	return fmt.Sprint("NativeValue{", nv.val, "}")
+	return fmt.Sprint("NativeValue{", nv.AsNative(), "}")
 }
 
 type StructValue struct {
-	// This is the concrete type of this struct instance.
+	// The qualified name of the concrete type of this struct instance, or ""
+	// for anonymous structs.
 	TypeName string
+	// Type arguments if the type is generic, or nil otherwise.
+	TypeArgs []apast.Expr
+	// The struct type for anonymous structs, or nil otherwise.
+	StructType *apast.StructTypeExpr
 	Values map[string]Value
 }
 
@@ -44,11 +122,15 @@ func (sv *StructValue) AsNative() interface{} {
 
 func (sv *StructValue) Copy() Value {
 	newValues := make(map[string]Value)
+	// Fields that are structs are copied too, since they're part of the
+	// struct's value.
 	for key, value := range sv.Values {
-		newValues[key] = value
+		newValues[key] = value.Copy()
 	}
 	return &StructValue{
 		sv.TypeName,
+		sv.TypeArgs,
+		sv.StructType,
 		newValues,
 	}
 }
@@ -57,9 +139,126 @@ func (sv *StructValue) String() string {
 	return fmt.Sprint("StructValue{", sv.TypeName, ", ", sv.Values, "}")
 }
 
+// NamedValue is a value of a named type declared in interpreted code whose
+// underlying type isn't a struct, like `type Celsius float64`. We need to keep
+// track of the type name so that methods can be resolved.
+type NamedValue struct {
+	// Qualified name of the type.
+	TypeName string
+	TypeArgs []apast.Expr
+	Val Value
+}
+
+func (nv *NamedValue) AsNative() interface{} {
+	return nv.Val.AsNative()
+}
+
+func (nv *NamedValue) Copy() Value {
+	return &NamedValue{
+		nv.TypeName,
+		nv.TypeArgs,
+		nv.Val.Copy(),
+	}
+}
+
+func (nv *NamedValue) String() string {
+	return fmt.Sprint("NamedValue{", nv.TypeName, ", ", nv.Val, "}")
+}
+
+// Returns the type arguments of the interpreted type of the given value, or nil
+// if the type isn't generic.
+func getTypeArgs(val Value) []apast.Expr {
+	switch val := val.(type) {
+	case *StructValue:
+		return val.TypeArgs
+	case *NamedValue:
+		return val.TypeArgs
+	default:
+		return nil
+	}
+}
+
+// Returns the name of the interpreted type of the given value, if any.
+func getTypeName(val Value) (string, bool) {
+	switch val := val.(type) {
+	case *StructValue:
+		return val.TypeName, true
+	case *NamedValue:
+		return val.TypeName, true
+	default:
+		return "", false
+	}
+}
+
+// Get the name of a declared type the way Go prints it, with the package name
+// rather than the full import path, like `geom.Point`.
+func displayTypeName(pack *apast.Package, typeName string) string {
+	typeDecl, ok := pack.Types[typeName]
+	if !ok {
+		return typeName
+	}
+	_, name := apast.SplitQualifiedName(typeName)
+	return typeDecl.Package.Name + "." + name
+}
+
+// PointerValue is a pointer to a location in interpreted code.
+type PointerValue struct {
+	// The location pointed to, or nil for a nil pointer.
+	Target ExprResult
+	// For nil pointers, the type pointed to, so that methods can still be
+	// called on them.
+	Elem apast.Expr
+}
+
+// Get the location that the pointer points to, which panics like Go if the
+// pointer is nil.
+func (pv *PointerValue) deref() ExprResult {
+	if pv.Target == nil {
+		panic("runtime error: invalid memory address or nil pointer dereference")
+	}
+	return pv.Target
+}
+
+func (pv *PointerValue) AsNative() interface{} {
+	if pv.Target == nil {
+		return nil
+	}
+	// Pointers to native values become native pointers to the same
+	// location.
+	if nativeVal, ok := pv.Target.get().(*NativeValue); ok && !isNil(nativeVal) {
+		if addr, ok := addressNative(pv.Target, nativeVal); ok {
+			return addr.Addr().Interface()
+		}
+	}
+	panic("Cannot convert PointerValue to native value.")
+}
+
+func (pv *PointerValue) Copy() Value {
+	// Copying a pointer gives another pointer to the same location.
+	return &PointerValue{
+		pv.Target,
+		pv.Elem,
+	}
+}
+
+func (pv *PointerValue) String() string {
+	if pv.Target == nil {
+		return "PointerValue{nil}"
+	}
+	return fmt.Sprint("PointerValue{", pv.Target.get(), "}")
+}
+
 type FunctionValue struct {
 	FuncDecl *apast.FuncDecl
 	BoundVariables map[string]Value
+	// Variables of the enclosing function that a function literal refers
+	// to, which it shares with the enclosing function.
+	CapturedVars map[string]*VariableLValue
+	// Type arguments for the type parameters of a generic function, or of
+	// the receiver type of a method on a generic type.
+	TypeArgs map[string]apast.Expr
+	// The package-level variables of the program the function belongs to.
+	Globals *Globals
 }
 
 func (fv *FunctionValue) AsNative() interface{} {
@@ -70,5 +269,8 @@ func (fv *FunctionValue) Copy() Value {
 	return &FunctionValue{
 		fv.FuncDecl,
 		fv.BoundVariables,
+		fv.CapturedVars,
+		fv.TypeArgs,
+		fv.Globals,
 	}
 }
\ No newline at end of file
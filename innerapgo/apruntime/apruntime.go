@@ -5,116 +5,325 @@ import (
 	"reflect"
 	"go/token"
 	"fmt"
-	"time"
 )
 
 type NativePackage struct {
+	// Import path of the package, like "encoding/json".
+	Path string
+	// Name that the package is imported as by default, like "json".
 	Name string
 	Funcs map[string]interface{}
-	Globals map[string]*interface{}
+	// Constants, which keep their Go types, like time.Duration for
+	// time.Second. Untyped constants have their default type.
+	Consts map[string]interface{}
+	// Names of the untyped constants, like math.Pi, which are converted to
+	// the type they're used as.
+	UntypedConsts map[string]bool
+	// Pointers to the package's variables, like &os.Args.
+	Vars map[string]interface{}
+	Types map[string]reflect.Type
 }
 
-func add(x interface{}, y interface{}) interface{} {
-	sum := reflect.ValueOf(x).Int() + reflect.ValueOf(y).Int()
+// The predeclared types, by name.
+var BasicTypes = map[string]reflect.Type{
+	"bool": reflect.TypeOf(false),
+	"string": reflect.TypeOf(""),
+	"int": reflect.TypeOf(int(0)),
+	"int8": reflect.TypeOf(int8(0)),
+	"int16": reflect.TypeOf(int16(0)),
+	"int32": reflect.TypeOf(int32(0)),
+	"int64": reflect.TypeOf(int64(0)),
+	"uint": reflect.TypeOf(uint(0)),
+	"uint8": reflect.TypeOf(uint8(0)),
+	"uint16": reflect.TypeOf(uint16(0)),
+	"uint32": reflect.TypeOf(uint32(0)),
+	"uint64": reflect.TypeOf(uint64(0)),
+	"uintptr": reflect.TypeOf(uintptr(0)),
+	"float32": reflect.TypeOf(float32(0)),
+	"float64": reflect.TypeOf(float64(0)),
+	"complex64": reflect.TypeOf(complex64(0)),
+	"complex128": reflect.TypeOf(complex128(0)),
+	"byte": reflect.TypeOf(byte(0)),
+	"rune": reflect.TypeOf(rune(0)),
+}
+
+// ArithmeticOperator is an operator whose result has the same type as its
+// operands, like + or ||. The evaluator uses this to preserve named types
+// declared in interpreted code, which are unwrapped before calling into native
+// code.
+type ArithmeticOperator func(x interface{}, y interface{}) interface{}
+
+// UnaryArithmeticOperator is like ArithmeticOperator, but takes one operand.
+type UnaryArithmeticOperator func(x interface{}) interface{}
+
+// ComparisonOperator is an operator that always produces a bool, regardless of
+// the types of its operands.
+type ComparisonOperator func(x interface{}, y interface{}) interface{}
+
+// EqualityOperator is == or !=. The evaluator converts interpreted values like
+// structs to comparable native values before calling these.
+type EqualityOperator func(x interface{}, y interface{}) interface{}
+
+// Get the operands of a binary operator as reflect values. Since we assume the
+// code compiles, the operands have the same type; the evaluator has already
+// converted any untyped constant operand to the type of the other operand.
+func operandValues(x interface{}, y interface{}) (reflect.Value, reflect.Value) {
+	xVal, yVal := reflect.ValueOf(x), reflect.ValueOf(y)
+	if xVal.Type() != yVal.Type() {
+		panic(fmt.Sprint("Mismatched operand types ", xVal.Type(), " and ", yVal.Type()))
+	}
+	return xVal, yVal
+}
+
+// Apply an arithmetic operation to two values of any numeric type (and
+// strings, if stringOp is provided). The result has the same type as the
+// operands.
+func arithmetic(
+		x interface{}, y interface{},
+		intOp func(int64, int64) int64,
+		uintOp func(uint64, uint64) uint64,
+		floatOp func(float64, float64) float64,
+		complexOp func(complex128, complex128) complex128,
+		stringOp func(string, string) string) interface{} {
+	xVal, yVal := operandValues(x, y)
+	var result interface{}
+	switch xVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result = intOp(xVal.Int(), yVal.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		result = uintOp(xVal.Uint(), yVal.Uint())
+	case reflect.Float32, reflect.Float64:
+		if floatOp != nil {
+			result = floatOp(xVal.Float(), yVal.Float())
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if complexOp != nil {
+			result = complexOp(xVal.Complex(), yVal.Complex())
+		}
+	case reflect.String:
+		if stringOp != nil {
+			result = stringOp(xVal.String(), yVal.String())
+		}
+	}
+	if result == nil {
+		panic(fmt.Sprint("Operator not supported on type ", xVal.Type()))
+	}
 	// Since this is a well-formed operation, the two types must be the
 	// same, so convert to that type.
-	// TODO: Handle other types, like floats.
-	return reflect.ValueOf(sum).Convert(reflect.TypeOf(x)).Interface()
+	return reflect.ValueOf(result).Convert(xVal.Type()).Interface()
+}
+
+// Compare two values of any ordered type, returning -1, 0, or 1.
+func compare(x interface{}, y interface{}) int {
+	xVal, yVal := operandValues(x, y)
+	switch xVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(xVal.Int() < yVal.Int(), xVal.Int() > yVal.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return compareOrdered(xVal.Uint() < yVal.Uint(), xVal.Uint() > yVal.Uint())
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(xVal.Float() < yVal.Float(), xVal.Float() > yVal.Float())
+	case reflect.String:
+		return compareOrdered(xVal.String() < yVal.String(), xVal.String() > yVal.String())
+	default:
+		panic(fmt.Sprint("Comparison not supported on type ", xVal.Type()))
+	}
+}
+
+func compareOrdered(isLess bool, isGreater bool) int {
+	if isLess {
+		return -1
+	} else if isGreater {
+		return 1
+	}
+	return 0
+}
+
+func add(x interface{}, y interface{}) interface{} {
+	return arithmetic(x, y,
+		func(a, b int64) int64 { return a + b },
+		func(a, b uint64) uint64 { return a + b },
+		func(a, b float64) float64 { return a + b },
+		func(a, b complex128) complex128 { return a + b },
+		func(a, b string) string { return a + b })
 }
 
 func sub(x interface{}, y interface{}) interface{} {
-	sum := reflect.ValueOf(x).Int() - reflect.ValueOf(y).Int()
-	// TODO: Handle other types.
-	return reflect.ValueOf(sum).Convert(reflect.TypeOf(x)).Interface()
+	return arithmetic(x, y,
+		func(a, b int64) int64 { return a - b },
+		func(a, b uint64) uint64 { return a - b },
+		func(a, b float64) float64 { return a - b },
+		func(a, b complex128) complex128 { return a - b },
+		nil)
 }
 
 func mul(x interface{}, y interface{}) interface{} {
-	sum := reflect.ValueOf(x).Int() * reflect.ValueOf(y).Int()
-	// TODO: Handle other types.
-	return reflect.ValueOf(sum).Convert(reflect.TypeOf(x)).Interface()
+	return arithmetic(x, y,
+		func(a, b int64) int64 { return a * b },
+		func(a, b uint64) uint64 { return a * b },
+		func(a, b float64) float64 { return a * b },
+		func(a, b complex128) complex128 { return a * b },
+		nil)
 }
 
 func quo(x interface{}, y interface{}) interface{} {
-	sum := reflect.ValueOf(x).Int() / reflect.ValueOf(y).Int()
-	// TODO: Handle other types.
-	return reflect.ValueOf(sum).Convert(reflect.TypeOf(x)).Interface()
+	return arithmetic(x, y,
+		func(a, b int64) int64 { return a / b },
+		func(a, b uint64) uint64 { return a / b },
+		func(a, b float64) float64 { return a / b },
+		func(a, b complex128) complex128 { return a / b },
+		nil)
+}
+
+func rem(x interface{}, y interface{}) interface{} {
+	return arithmetic(x, y,
+		func(a, b int64) int64 { return a % b },
+		func(a, b uint64) uint64 { return a % b },
+		nil, nil, nil)
 }
 
 func less(x interface{}, y interface{}) interface{} {
-	// TODO: Handle other types.
-	return reflect.ValueOf(x).Int() < reflect.ValueOf(y).Int()
+	return compare(x, y) < 0
 }
 
 func greater(x interface{}, y interface{}) interface{} {
-	// TODO: Handle other types.
-	return reflect.ValueOf(x).Int() > reflect.ValueOf(y).Int()
+	return compare(x, y) > 0
 }
 
 func lor(x interface{}, y interface{}) interface{} {
 	// TODO: Short-circuit.
-	return x.(bool) || y.(bool)
+	return reflect.ValueOf(x).Bool() || reflect.ValueOf(y).Bool()
+}
+
+func land(x interface{}, y interface{}) interface{} {
+	// TODO: Short-circuit.
+	return reflect.ValueOf(x).Bool() && reflect.ValueOf(y).Bool()
 }
 
 func equal(x interface{}, y interface{}) interface{} {
+	if x == nil || y == nil {
+		// Slices, maps, etc. can also be compared with nil.
+		return isNil(x) && isNil(y)
+	}
+	// Values of different types are never equal, which is what Go does
+	// for interface values.
 	return x == y
 }
 
+func isNil(x interface{}) bool {
+	if x == nil {
+		return true
+	}
+	val := reflect.ValueOf(x)
+	switch val.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return val.IsNil()
+	}
+	return false
+}
+
 func neq(x interface{}, y interface{}) interface{} {
-	return x != y
+	return !equal(x, y).(bool)
 }
 
 func leq(x interface{}, y interface{}) interface{} {
-	// TODO: Handle other types.
-	return reflect.ValueOf(x).Int() <= reflect.ValueOf(y).Int()
+	return compare(x, y) <= 0
 }
 
 func geq(x interface{}, y interface{}) interface{} {
-	// TODO: Handle other types.
-	return reflect.ValueOf(x).Int() >= reflect.ValueOf(y).Int()
+	return compare(x, y) >= 0
+}
+
+func neg(x interface{}) interface{} {
+	val := reflect.ValueOf(x)
+	result := reflect.New(val.Type()).Elem()
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result.SetInt(-val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		result.SetUint(-val.Uint())
+	case reflect.Float32, reflect.Float64:
+		result.SetFloat(-val.Float())
+	case reflect.Complex64, reflect.Complex128:
+		result.SetComplex(-val.Complex())
+	default:
+		panic(fmt.Sprint("Operator not supported on type ", val.Type()))
+	}
+	return result.Interface()
 }
 
+func not(x interface{}) interface{} {
+	return !reflect.ValueOf(x).Bool()
+}
+
+var UnaryOperators = map[token.Token]interface{}{
+	token.SUB: UnaryArithmeticOperator(neg),
+	token.NOT: UnaryArithmeticOperator(not),
+}
 
 var BinaryOperators = map[token.Token]interface{}{
-	token.ADD: add,
-	token.SUB: sub,
-	token.MUL: mul,
-	token.QUO: quo,
-	token.LSS: less,
-	token.GTR: greater,
-	token.LOR: lor,
-	token.EQL: equal,
-	token.NEQ: neq,
-	token.LEQ: leq,
-	token.GEQ: geq,
+	token.ADD: ArithmeticOperator(add),
+	token.SUB: ArithmeticOperator(sub),
+	token.MUL: ArithmeticOperator(mul),
+	token.QUO: ArithmeticOperator(quo),
+	token.REM: ArithmeticOperator(rem),
+	token.LSS: ComparisonOperator(less),
+	token.GTR: ComparisonOperator(greater),
+	token.LOR: ArithmeticOperator(lor),
+	token.LAND: ArithmeticOperator(land),
+	token.EQL: EqualityOperator(equal),
+	token.NEQ: EqualityOperator(neq),
+	token.LEQ: ComparisonOperator(leq),
+	token.GEQ: ComparisonOperator(geq),
 }
 
 var AssignBinaryOperators = map[token.Token]interface{}{
-	token.ADD_ASSIGN: add,
-	token.SUB_ASSIGN: sub,
-	token.MUL_ASSIGN: mul,
-	token.QUO_ASSIGN: quo,
+	token.ADD_ASSIGN: ArithmeticOperator(add),
+	token.SUB_ASSIGN: ArithmeticOperator(sub),
+	token.MUL_ASSIGN: ArithmeticOperator(mul),
+	token.QUO_ASSIGN: ArithmeticOperator(quo),
+	token.REM_ASSIGN: ArithmeticOperator(rem),
 }
 
 var IncDecOperators = map[token.Token]interface{}{
-	token.INC: add,
-	token.DEC: sub,
+	token.INC: ArithmeticOperator(add),
+	token.DEC: ArithmeticOperator(sub),
+}
+
+// ProgramExit is panicked by os.Exit to stop the interpreted program without
+// exiting the host process. The interpreter recovers it and returns the code.
+type ProgramExit struct {
+	Code int
+}
+
+func exit(code int) {
+	panic(&ProgramExit{code})
 }
 
 var FmtPackage = &NativePackage{
+	Path: "fmt",
 	Name: "fmt",
 	Funcs: map[string]interface{} {
+		"Errorf": fmt.Errorf,
+		"Fprint": fmt.Fprint,
+		"Fprintf": fmt.Fprintf,
+		"Fprintln": fmt.Fprintln,
 		"Print": fmt.Print,
+		"Printf": fmt.Printf,
 		"Println": fmt.Println,
 		"Sprint": fmt.Sprint,
+		"Sprintf": fmt.Sprintf,
+		"Sprintln": fmt.Sprintln,
+	},
+	Types: map[string]reflect.Type {
+		"Stringer": reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
 	},
-	Globals: map[string]*interface{} {},
 }
 
-var TimePackage = &NativePackage{
-	Name: "time",
+var OsPackage = &NativePackage{
+	Path: "os",
+	Name: "os",
 	Funcs: map[string]interface{} {
-		"Now": time.Now,
-		"Since": time.Since,
+		"Exit": exit,
 	},
-	Globals: map[string]*interface{} {},
-}
\ No newline at end of file
+}
@@ -2,52 +2,236 @@
 package interpreter
 
 import (
+	"fmt"
 	"github.com/alangpierce/apgo/apast"
 	"github.com/alangpierce/apgo/apcompiler"
 	"github.com/alangpierce/apgo/apevaluator"
 	"github.com/alangpierce/apgo/apruntime"
 	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type Interpreter struct {
-	packages       map[string]*apast.Package
 	nativePackages map[string]*apruntime.NativePackage
+	fset           *token.FileSet
+	compileCtx     *apcompiler.CompileCtx
+	// Interpreted packages that have been loaded, by directory.
+	loadedDirs     map[string]*apast.Package
+	// Packages that are in the middle of being loaded, which are used to
+	// detect import cycles.
+	loading        []loadingPackage
+	// Packages in the order they should be initialized, which puts each
+	// package after the packages it imports.
+	initOrder      []*apast.Package
+	// Finds the directory of an interpreted package by its import path.
+	// By default, packages are found using the go.mod of the first package
+	// loaded, or in GOPATH if it isn't in a module.
+	FindPackageDir func(importPath string) (string, error)
+	// Where the interpreted program's crash messages are written.
+	Stderr         io.Writer
+	// The package-level variables of the interpreted program.
+	globals        *apevaluator.Globals
+}
+
+type loadingPackage struct {
+	dir        string
+	importPath string
 }
 
 func NewInterpreter() *Interpreter {
+	fset := token.NewFileSet()
+	nativePackages := make(map[string]*apruntime.NativePackage)
 	return &Interpreter{
-		packages:       make(map[string]*apast.Package),
-		nativePackages: make(map[string]*apruntime.NativePackage),
+		nativePackages: nativePackages,
+		fset:           fset,
+		compileCtx:     apcompiler.NewCompileCtx(fset, nativePackages),
+		loadedDirs:     make(map[string]*apast.Package),
+		loading:        []loadingPackage{},
+		initOrder:      []*apast.Package{},
+		Stderr:         os.Stderr,
+		globals:        apevaluator.NewGlobals(),
 	}
 }
 
-// Load and compile the package at the given path.
+// Load and compile the package at the given path, along with any interpreted
+// packages that it imports.
 func (interpreter *Interpreter) LoadPackage(dirPath string) error {
-	fset := token.NewFileSet()
-	packageAsts, err := parser.ParseDir(fset, dirPath, nil, 0)
+	if interpreter.FindPackageDir == nil {
+		loader, err := LoadModule(dirPath)
+		if err != nil {
+			return err
+		}
+		if loader != nil {
+			interpreter.FindPackageDir = loader.FindPackageDir
+		} else {
+			interpreter.FindPackageDir = findInGopath
+		}
+	}
+	_, err := interpreter.loadPackage(dirPath, dirPath)
+	return err
+}
+
+func (interpreter *Interpreter) loadPackage(dirPath string, importPath string) (*apast.Package, error) {
+	dir, err := filepath.Abs(dirPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	compileCtx := &apcompiler.CompileCtx{
-		interpreter.nativePackages,
-		make(map[string]bool),
-		make(map[string]*ast.StructType),
+	if pack, ok := interpreter.loadedDirs[dir]; ok {
+		return pack, nil
 	}
-	for name, packageAst := range packageAsts {
-		interpreter.packages[name] = apcompiler.CompilePackage(compileCtx, packageAst)
+	for i, loading := range interpreter.loading {
+		if loading.dir == dir {
+			cycle := []string{}
+			for _, cyclePackage := range interpreter.loading[i:] {
+				cycle = append(cycle, cyclePackage.importPath)
+			}
+			cycle = append(cycle, importPath)
+			return nil, fmt.Errorf("import cycle not allowed: %s", strings.Join(cycle, " -> "))
+		}
 	}
-	return nil
+	interpreter.loading = append(interpreter.loading, loadingPackage{dir, importPath})
+	defer func() {
+		interpreter.loading = interpreter.loading[:len(interpreter.loading) - 1]
+	}()
+
+	packageAst, err := interpreter.parsePackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Imported packages are compiled first, since the package refers to
+	// them.
+	for _, path := range getImportPaths(packageAst) {
+		// Standard library packages can only be native, so if one
+		// isn't registered, the compiler reports it at the import.
+		if _, ok := interpreter.nativePackages[path]; ok || isStandardImportPath(path) {
+			continue
+		}
+		importDir, err := interpreter.FindPackageDir(path)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := interpreter.loadPackage(importDir, path); err != nil {
+			return nil, err
+		}
+	}
+	pack, err := apcompiler.CompilePackage(interpreter.compileCtx, packageAst, importPath)
+	if err != nil {
+		return nil, err
+	}
+	interpreter.compileCtx.Packages[importPath] = pack
+	interpreter.loadedDirs[dir] = pack
+	interpreter.initOrder = append(interpreter.initOrder, pack)
+	return pack, nil
+}
+
+// Parse the package in the given directory, leaving out tests.
+func (interpreter *Interpreter) parsePackage(dir string) (*ast.Package, error) {
+	isNotTest := func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}
+	packageAsts, err := parser.ParseDir(interpreter.fset, dir, isNotTest, 0)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range packageAsts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	} else if len(names) > 1 {
+		return nil, fmt.Errorf("found packages %s in %s", strings.Join(names, ", "), dir)
+	}
+	return packageAsts[names[0]], nil
+}
+
+// Get the import paths of a package, in the order they're imported, with files
+// in order of their names.
+func getImportPaths(pack *ast.Package) []string {
+	fileNames := []string{}
+	for fileName := range pack.Files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	paths := []string{}
+	seen := make(map[string]bool)
+	for _, fileName := range fileNames {
+		for _, spec := range pack.Files[fileName].Imports {
+			path, err := strconv.Unquote(spec.Path.Value)
+			if err != nil {
+				panic(err)
+			}
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// Returns true if the import path is a package in the standard library, like
+// "fmt" or "encoding/json".
+func isStandardImportPath(path string) bool {
+	if build.Default.GOROOT == "" {
+		return false
+	}
+	return isDir(filepath.Join(build.Default.GOROOT, "src", filepath.FromSlash(path)))
+}
+
+func findInGopath(importPath string) (string, error) {
+	for _, root := range filepath.SplitList(build.Default.GOPATH) {
+		dir := filepath.Join(root, "src", filepath.FromSlash(importPath))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find package %q in GOPATH (%s)", importPath, build.Default.GOPATH)
 }
 
 func (interpreter *Interpreter) LoadNativePackage(pack *apruntime.NativePackage) {
-	interpreter.nativePackages[pack.Name] = pack
+	interpreter.nativePackages[pack.Path] = pack
 }
 
-func (interpreter *Interpreter) RunMain() {
-	mainPackage := interpreter.packages["main"]
-	mainFunc := apevaluator.CreatePackageFuncValue(mainPackage, "main")
-	apevaluator.EvaluateFunc(mainPackage,
-		mainFunc.(*apevaluator.FunctionValue), []apevaluator.Value{})
+// Initialize all packages, in import order, then run main, and return the exit
+// code of the program. Like in Go, the code is 0 if main returns, the code
+// passed to os.Exit if it's called, and 2 if the program panics. It's an error
+// if no main package with a main function has been loaded.
+func (interpreter *Interpreter) RunMain() (exitCode int, err error) {
+	var mainPackage *apast.Package
+	for _, pack := range interpreter.initOrder {
+		if pack.Name == "main" {
+			mainPackage = pack
+		}
+	}
+	if mainPackage == nil {
+		return 0, fmt.Errorf("no main package has been loaded")
+	} else if mainPackage.Funcs["main"] == nil {
+		return 0, fmt.Errorf("function main is undeclared in the main package")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if exit, ok := r.(*apruntime.ProgramExit); ok {
+				exitCode = exit.Code
+				return
+			}
+			fmt.Fprintln(interpreter.Stderr, "panic:", apevaluator.DescribePanic(interpreter.globals, mainPackage, r))
+			exitCode = 2
+		}
+	}()
+	for _, pack := range interpreter.initOrder {
+		apevaluator.InitPackage(interpreter.globals, pack)
+	}
+	mainFunc := apevaluator.CreatePackageFuncValue(interpreter.globals, mainPackage, "main")
+	apevaluator.EvaluateFunc(mainFunc.(*apevaluator.FunctionValue), []apevaluator.Value{})
+	return 0, nil
 }
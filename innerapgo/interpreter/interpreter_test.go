@@ -0,0 +1,487 @@
+package interpreter
+
+import (
+	"bytes"
+	"github.com/alangpierce/apgo/apruntime"
+	"github.com/alangpierce/apgo/stdlib"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// Write the given files to a temporary directory, with paths relative to it.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "apgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// Load the main package in the given source, with fmt and the stdlib bindings
+// available.
+func loadProgram(t *testing.T, source string) (*Interpreter, error) {
+	dir := writeFiles(t, map[string]string{
+		"main.go": source,
+	})
+	defer os.RemoveAll(dir)
+	interp := NewInterpreter()
+	interp.LoadNativePackage(apruntime.FmtPackage)
+	for _, pack := range stdlib.Packages {
+		interp.LoadNativePackage(pack)
+	}
+	return interp, interp.LoadPackage(dir)
+}
+
+// Run the main package in the given source, returning its exit code and what it
+// wrote to stderr.
+func runProgram(t *testing.T, source string) (int, string) {
+	interp, err := loadProgram(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	interp.Stderr = &stderr
+	exitCode, err := interp.RunMain()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exitCode, stderr.String()
+}
+
+// Run a program that panics if something is wrong.
+func expectPass(t *testing.T, source string) {
+	if exitCode, stderr := runProgram(t, source); exitCode != 0 {
+		t.Errorf("Exited with code %d: %s", exitCode, stderr)
+	}
+}
+
+func expectCompileError(t *testing.T, source string, msg string) {
+	_, err := loadProgram(t, source)
+	if err == nil {
+		t.Errorf("Expected compile error %q", msg)
+	} else if !strings.Contains(err.Error(), msg) {
+		t.Errorf("Expected compile error %q, got %q", msg, err)
+	}
+}
+
+func TestShadowing(t *testing.T) {
+	expectPass(t, `package main
+
+func helper() int {
+	return 42
+}
+
+func check(ok bool) {
+	if !ok {
+		panic("failed")
+	}
+}
+
+func main() {
+	x := 1
+	{
+		x := 2
+		x++
+		check(x == 3)
+	}
+	check(x == 1)
+	for i := 0; i < 3; i++ {
+		x := i + 100
+		check(x >= 100)
+	}
+	check(x == 1)
+	if x := 5; x > 3 {
+		check(x == 5)
+	}
+	check(x == 1)
+	{
+		helper := 3
+		check(helper == 3)
+	}
+	check(helper() == 42)
+	y := 1
+	{
+		y := y + 10
+		check(y == 11)
+	}
+	check(y == 1)
+}
+`)
+}
+
+func TestUndefinedNames(t *testing.T) {
+	expectCompileError(t, `package main
+
+func main() {
+	x := y
+}
+`, "undefined: y")
+	// Variables are only defined in their scope.
+	expectCompileError(t, `package main
+
+func main() {
+	{
+		x := 1
+		x++
+	}
+	x++
+}
+`, "undefined: x")
+}
+
+func TestUnsupportedCode(t *testing.T) {
+	// Problems other than compile errors are returned as errors too.
+	expectCompileError(t, `package main
+
+func f() {
+}
+
+func main() {
+	go f()
+}
+`, "Statement compile not implemented")
+}
+
+func TestInitOrder(t *testing.T) {
+	// Variables are initialized after the variables they depend on, even
+	// through functions, and then the init functions run in order.
+	expectPass(t, `package main
+
+var order []string
+
+func record(name string) int {
+	order = append(order, name)
+	return len(order)
+}
+
+var a = b + record("a")
+var b = record("b")
+
+func init() {
+	record("init1")
+}
+
+func init() {
+	record("init2")
+}
+
+func check(ok bool) {
+	if !ok {
+		panic("failed")
+	}
+}
+
+func main() {
+	check(b == 1)
+	check(a == 3)
+	check(len(order) == 4)
+	check(order[0] == "b" && order[1] == "a")
+	check(order[2] == "init1" && order[3] == "init2")
+}
+`)
+}
+
+func TestConstants(t *testing.T) {
+	// Constants are evaluated at compile time, including ones from other
+	// packages and ones that refer to constants declared later.
+	dir := writeFiles(t, map[string]string{
+		"go.mod": "module example.com/app\n",
+		"units/units.go": `package units
+
+type Celsius float64
+
+const (
+	Freezing Celsius = 0
+	Boiling Celsius = 100
+)
+
+type Weekday int
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+const (
+	_ = 1 << (10 * iota)
+	KB
+	MB
+)
+
+const Greeting = prefix + "world"
+
+const prefix = "hello, "
+`,
+		"main.go": `package main
+
+import (
+	"example.com/app/units"
+	"math"
+	"time"
+)
+
+const (
+	A, B = iota, iota * 10
+	C, D
+)
+
+type Grid [Size * 2]int
+
+const Size = 3
+const Half = Size / 2
+const Ratio = Size / 2.0
+const Timeout = 2 * time.Second
+const TwoPi = 2 * math.Pi
+
+func check(ok bool) {
+	if !ok {
+		panic("failed")
+	}
+}
+
+func main() {
+	check(A == 0 && B == 0 && C == 1 && D == 10)
+	var g Grid
+	check(len(g) == 6)
+	check(Half == 1 && Ratio == 1.5)
+	check(Timeout.Seconds() == 2)
+	check(TwoPi > 6.28 && TwoPi < 6.29)
+	temp := units.Boiling
+	temp = temp - units.Freezing
+	check(temp == 100)
+	check(units.Tuesday == 2 && units.MB == 1024 * 1024)
+	check(units.Greeting == "hello, world")
+	const kilobytes = units.KB * 2
+	check(kilobytes == 2048)
+	x := 5
+	if x > 0 {
+		const x = "shadowed"
+		check(x == "shadowed")
+	}
+	check(x == 5)
+}
+`,
+	})
+	defer os.RemoveAll(dir)
+	interp := NewInterpreter()
+	for _, pack := range stdlib.Packages {
+		interp.LoadNativePackage(pack)
+	}
+	if err := interp.LoadPackage(dir); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	interp.Stderr = &stderr
+	if exitCode, err := interp.RunMain(); err != nil || exitCode != 0 {
+		t.Errorf("Exited with code %d (%v): %s", exitCode, err, stderr.String())
+	}
+	expectCompileError(t, `package main
+
+const A = B
+const B = A
+
+func main() {
+}
+`, "initialization cycle for A")
+}
+
+func TestExitCode(t *testing.T) {
+	exitCode, _ := runProgram(t, `package main
+
+import "os"
+
+func main() {
+	os.Exit(3)
+	panic("unreachable")
+}
+`)
+	if exitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", exitCode)
+	}
+	// Exiting during initialization stops the program too.
+	exitCode, _ = runProgram(t, `package main
+
+import "os"
+
+func init() {
+	os.Exit(4)
+}
+
+func main() {
+	panic("unreachable")
+}
+`)
+	if exitCode != 4 {
+		t.Errorf("Expected exit code 4, got %d", exitCode)
+	}
+}
+
+func TestPanicExitCode(t *testing.T) {
+	exitCode, stderr := runProgram(t, `package main
+
+import "errors"
+
+func main() {
+	panic(errors.New("boom"))
+}
+`)
+	if exitCode != 2 {
+		t.Errorf("Expected exit code 2, got %d", exitCode)
+	}
+	if stderr != "panic: boom\n" {
+		t.Errorf("Unexpected panic message %q", stderr)
+	}
+	// Runtime errors from the interpreter crash the program the same way.
+	exitCode, stderr = runProgram(t, `package main
+
+type Point struct {
+	X int
+}
+
+func main() {
+	var p *Point
+	p.X = 1
+}
+`)
+	if exitCode != 2 {
+		t.Errorf("Expected exit code 2, got %d", exitCode)
+	}
+	if stderr != "panic: runtime error: invalid memory address or nil pointer dereference\n" {
+		t.Errorf("Unexpected panic message %q", stderr)
+	}
+}
+
+func TestPanicValues(t *testing.T) {
+	for value, expected := range map[string]string{
+		`Name("x")`: `main\.Name\("x"\)`,
+		`Celsius(1.5)`: `main\.Celsius\(1\.5\)`,
+		`Point{1, 2}`: `\(main\.Point\) 0x[0-9a-f]+`,
+		`&Point{1, 2}`: `\(\*main\.Point\) 0x[0-9a-f]+`,
+		`[]int{1}`: `\(\[\]int\) 0x[0-9a-f]+`,
+		`2.5`: `2\.5`,
+	} {
+		exitCode, stderr := runProgram(t, `package main
+
+type Name string
+
+type Celsius float64
+
+type Point struct {
+	X, Y int
+}
+
+func main() {
+	panic(` + value + `)
+}
+`)
+		if exitCode != 2 || !regexp.MustCompile("^panic: " + expected + "\n$").MatchString(stderr) {
+			t.Errorf("panic(%s): unexpected exit code %d and panic message %q", value, exitCode, stderr)
+		}
+	}
+}
+
+func TestRunMainErrors(t *testing.T) {
+	interp, err := loadProgram(t, "package lib\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.RunMain(); err == nil || err.Error() != "no main package has been loaded" {
+		t.Errorf("Unexpected error %v", err)
+	}
+	interp, err = loadProgram(t, "package main\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.RunMain(); err == nil || err.Error() != "function main is undeclared in the main package" {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestNativeArgConversion(t *testing.T) {
+	expectPass(t, `package main
+
+import "strconv"
+
+func main() {
+	if strconv.FormatUint(5, 2) != "101" {
+		panic("wrong result")
+	}
+}
+`)
+	// Negative constants aren't representable in unsigned types.
+	exitCode, stderr := runProgram(t, `package main
+
+import "strconv"
+
+func main() {
+	strconv.FormatUint(-1, 10)
+}
+`)
+	if exitCode != 2 || !strings.Contains(stderr, "cannot use -1 (type int) as type uint64") {
+		t.Errorf("Unexpected exit code %d and panic message %q", exitCode, stderr)
+	}
+	// Only untyped constants are converted implicitly, and negative values of
+	// any type can't be passed as unsigned types.
+	for call, expected := range map[string]string{
+		`var n int8 = 5; strconv.FormatInt(n, 10)`: "cannot use 5 (type int8) as type int64",
+		`n := 5; strconv.FormatInt(n, 10)`: "cannot use 5 (type int) as type int64",
+		`x := 1.5; math.Float32bits(x)`: "cannot use 1.5 (type float64) as type float32",
+		`r := 'a'; strconv.FormatUint(r, 10)`: "cannot use 97 (type int32) as type uint64",
+		`strconv.FormatUint(-1.0, 10)`: "cannot use -1 (type float64) as type uint64",
+		`math.Float64frombits(-1)`: "cannot use -1 (type int) as type uint64",
+		`strconv.FormatUint(2.5, 10)`: "cannot use 2.5 (type float64) as type uint64",
+	} {
+		exitCode, stderr = runProgram(t, `package main
+
+import (
+	"math"
+	"strconv"
+)
+
+var _ = math.Pi
+
+func main() {
+	` + call + `
+}
+`)
+		if exitCode != 2 || !strings.Contains(stderr, expected) {
+			t.Errorf("%s: unexpected exit code %d and panic message %q", call, exitCode, stderr)
+		}
+	}
+}
+
+func TestRecursiveMaterializing(t *testing.T) {
+	// Recursive fields are decoded through encoding/json's generic values,
+	// so errors in them are only found after the native call returns.
+	exitCode, stderr := runProgram(t, `package main
+
+import "encoding/json"
+
+type Node struct {
+	Val int
+	Next *Node
+}
+
+func main() {
+	var n Node
+	json.Unmarshal([]byte(`+"`"+`{"next":{"val":"x"}}`+"`"+`), &n)
+}
+`)
+	if exitCode != 2 || !strings.Contains(stderr, "to *main.Node: json: cannot unmarshal string") {
+		t.Errorf("Unexpected exit code %d and panic message %q", exitCode, stderr)
+	}
+}
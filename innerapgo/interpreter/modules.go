@@ -0,0 +1,307 @@
+package interpreter
+
+import (
+	"fmt"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A ModuleLoader finds the directories of packages imported by a Go module,
+// using the module's go.mod file. It only looks at files already on disk, so it
+// never downloads anything. Since Go 1.17, go.mod lists every module needed to
+// build the main module's packages, so the go.mod files of dependencies aren't
+// read.
+type ModuleLoader struct {
+	// Module path of the main module, like github.com/ourco/app.
+	Path string
+	// Directory that contains the go.mod file.
+	Dir string
+	// Versions of the required modules, by module path.
+	Requires map[string]string
+	Replaces []*moduleReplace
+	// Directory with the vendored packages, or "" if the module doesn't use
+	// vendoring.
+	VendorDir string
+	// Root of the module cache, like ~/go/pkg/mod.
+	CacheDir string
+}
+
+// A replace directive, like `replace example.com/a v1.0.0 => ../a`. The old
+// version is "" if the directive applies to all versions, and the new version
+// is "" if the replacement is a local directory.
+type moduleReplace struct {
+	oldPath string
+	oldVersion string
+	newPath string
+	newVersion string
+}
+
+// Find the module containing the given directory and read its go.mod. Returns
+// nil if the directory isn't in a module.
+func LoadModule(dir string) (*ModuleLoader, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		goModPath := filepath.Join(dir, "go.mod")
+		if _, err := os.Stat(goModPath); err == nil {
+			return readModule(dir, goModPath)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+func readModule(dir string, goModPath string) (*ModuleLoader, error) {
+	contents, err := ioutil.ReadFile(goModPath)
+	if err != nil {
+		return nil, err
+	}
+	loader := &ModuleLoader{
+		"",
+		dir,
+		make(map[string]string),
+		[]*moduleReplace{},
+		"",
+		getModuleCacheDir(),
+	}
+	if err := parseGoMod(loader, goModPath, string(contents)); err != nil {
+		return nil, err
+	}
+	if loader.Path == "" {
+		return nil, fmt.Errorf("%s: no module declaration", goModPath)
+	}
+	// Like the go command, vendor/ is used if it has a modules.txt.
+	vendorDir := filepath.Join(dir, "vendor")
+	if _, err := os.Stat(filepath.Join(vendorDir, "modules.txt")); err == nil {
+		loader.VendorDir = vendorDir
+	}
+	return loader, nil
+}
+
+func getModuleCacheDir() string {
+	if dir := os.Getenv("GOMODCACHE"); dir != "" {
+		return dir
+	}
+	gopaths := filepath.SplitList(build.Default.GOPATH)
+	if len(gopaths) == 0 {
+		return ""
+	}
+	return filepath.Join(gopaths[0], "pkg", "mod")
+}
+
+// Read the module, require and replace directives of a go.mod file. Other
+// directives, like go and exclude, don't affect where packages are found, so
+// they're ignored.
+func parseGoMod(loader *ModuleLoader, goModPath string, contents string) error {
+	blockVerb := ""
+	for i, line := range strings.Split(contents, "\n") {
+		lineErr := func(msg string) error {
+			return fmt.Errorf("%s:%d: %s", goModPath, i + 1, msg)
+		}
+		words, err := splitGoModLine(line)
+		if err != nil {
+			return lineErr(err.Error())
+		}
+		if len(words) == 0 {
+			continue
+		}
+		verb := blockVerb
+		if blockVerb == "" {
+			verb, words = words[0], words[1:]
+			if len(words) == 1 && words[0] == "(" {
+				blockVerb = verb
+				continue
+			}
+		} else if len(words) == 1 && words[0] == ")" {
+			blockVerb = ""
+			continue
+		}
+		switch verb {
+		case "module":
+			if len(words) != 1 {
+				return lineErr("usage: module module/path")
+			}
+			loader.Path = words[0]
+		case "require":
+			if len(words) != 2 {
+				return lineErr("usage: require module/path v1.2.3")
+			}
+			loader.Requires[words[0]] = words[1]
+		case "replace":
+			replace, err := parseReplace(words)
+			if err != nil {
+				return lineErr(err.Error())
+			}
+			loader.Replaces = append(loader.Replaces, replace)
+		}
+	}
+	return nil
+}
+
+// Split a go.mod line into words, leaving out comments.
+func splitGoModLine(line string) ([]string, error) {
+	if i := strings.Index(line, "//"); i >= 0 {
+		line = line[:i]
+	}
+	words := []string{}
+	for _, word := range strings.Fields(line) {
+		if strings.HasPrefix(word, "\"") {
+			unquoted, err := strconv.Unquote(word)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string %s", word)
+			}
+			word = unquoted
+		}
+		words = append(words, word)
+	}
+	return words, nil
+}
+
+func parseReplace(words []string) (*moduleReplace, error) {
+	arrow := -1
+	for i, word := range words {
+		if word == "=>" {
+			arrow = i
+		}
+	}
+	old, replacement := words, []string{}
+	if arrow >= 0 {
+		old, replacement = words[:arrow], words[arrow + 1:]
+	}
+	if len(old) < 1 || len(old) > 2 || len(replacement) < 1 || len(replacement) > 2 {
+		return nil, fmt.Errorf("usage: replace module/path [v1.2.3] => other/module v1.4 or ../local/directory")
+	}
+	replace := &moduleReplace{old[0], "", replacement[0], ""}
+	if len(old) == 2 {
+		replace.oldVersion = old[1]
+	}
+	if len(replacement) == 2 {
+		replace.newVersion = replacement[1]
+	} else if !isLocalPath(replacement[0]) {
+		return nil, fmt.Errorf("replacement module without version must be directory path (rooted or starting with ./ or ../)")
+	}
+	return replace, nil
+}
+
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		path == "." || path == ".." || filepath.IsAbs(path)
+}
+
+// Find the directory for the package with the given import path.
+func (loader *ModuleLoader) FindPackageDir(importPath string) (string, error) {
+	if rest, ok := cutModulePath(importPath, loader.Path); ok {
+		return loader.packageDir(loader.Dir, importPath, rest, loader.Path)
+	}
+	if loader.VendorDir != "" {
+		dir := filepath.Join(loader.VendorDir, filepath.FromSlash(importPath))
+		if isDir(dir) {
+			return dir, nil
+		}
+		return "", fmt.Errorf("cannot find package %q in vendor directory %s", importPath, loader.VendorDir)
+	}
+	modulePath, rest := "", ""
+	for path := range loader.Requires {
+		if pathRest, ok := cutModulePath(importPath, path); ok && len(path) > len(modulePath) {
+			modulePath, rest = path, pathRest
+		}
+	}
+	if modulePath == "" {
+		return "", fmt.Errorf("cannot find package %q: no module required by %s provides it", importPath, loader.Path)
+	}
+	version := loader.Requires[modulePath]
+	replace := loader.findReplace(modulePath, version)
+	if replace == nil {
+		return loader.cachedPackageDir(importPath, rest, modulePath, version)
+	}
+	if replace.newVersion != "" {
+		return loader.cachedPackageDir(importPath, rest, replace.newPath, replace.newVersion)
+	}
+	root := replace.newPath
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(loader.Dir, filepath.FromSlash(root))
+	}
+	if !isDir(root) {
+		return "", fmt.Errorf("cannot find package %q: replacement directory %s for module %s does not exist", importPath, root, modulePath)
+	}
+	return loader.packageDir(root, importPath, rest, modulePath)
+}
+
+// Find the replace directive for a module version, preferring one for that
+// specific version over one for all versions.
+func (loader *ModuleLoader) findReplace(modulePath string, version string) *moduleReplace {
+	var result *moduleReplace
+	for _, replace := range loader.Replaces {
+		if replace.oldPath != modulePath {
+			continue
+		}
+		if replace.oldVersion == version {
+			return replace
+		} else if replace.oldVersion == "" {
+			result = replace
+		}
+	}
+	return result
+}
+
+func (loader *ModuleLoader) cachedPackageDir(importPath string, rest string, modulePath string, version string) (string, error) {
+	moduleVersion := modulePath + "@" + version
+	if loader.CacheDir == "" {
+		return "", fmt.Errorf("cannot find package %q: no module cache to find %s in", importPath, moduleVersion)
+	}
+	root := filepath.Join(loader.CacheDir, filepath.FromSlash(escapeModulePath(modulePath)) + "@" + escapeModulePath(version))
+	if !isDir(root) {
+		return "", fmt.Errorf("cannot find package %q: module %s is not in the module cache (%s); it needs to be downloaded, e.g. with `go mod download`", importPath, moduleVersion, loader.CacheDir)
+	}
+	return loader.packageDir(root, importPath, rest, moduleVersion)
+}
+
+func (loader *ModuleLoader) packageDir(root string, importPath string, rest string, module string) (string, error) {
+	dir := filepath.Join(root, filepath.FromSlash(rest))
+	if !isDir(dir) {
+		return "", fmt.Errorf("cannot find package %q: module %s does not contain it (no directory %s)", importPath, module, dir)
+	}
+	return dir, nil
+}
+
+// Get the rest of the import path if it's a package in the given module.
+func cutModulePath(importPath string, modulePath string) (string, bool) {
+	if importPath == modulePath {
+		return "", true
+	}
+	if strings.HasPrefix(importPath, modulePath + "/") {
+		return importPath[len(modulePath) + 1:], true
+	}
+	return "", false
+}
+
+// The module cache stores paths with each uppercase letter replaced by an
+// exclamation mark followed by the lowercase letter, so that they work on
+// case-insensitive file systems.
+func escapeModulePath(path string) string {
+	var result strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			result.WriteByte('!')
+			result.WriteRune(unicode.ToLower(r))
+		} else {
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
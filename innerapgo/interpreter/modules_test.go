@@ -0,0 +1,209 @@
+package interpreter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const appGoMod = `module example.com/app
+
+go 1.21
+
+require (
+	example.com/lib v1.2.0
+	github.com/BurntSushi/toml v1.3.2 // indirect
+	example.com/local v0.1.0
+	example.com/pinned v1.0.0
+)
+
+require example.com/moved v2.0.0
+
+replace example.com/local => ../local
+
+// Only the version that's required is replaced.
+replace (
+	example.com/pinned v0.9.0 => ../old
+	example.com/pinned v1.0.0 => ../pinned
+	example.com/pinned => ../unused
+)
+
+replace example.com/moved => example.com/fork v2.1.0
+`
+
+// Load the module of a fixture, with the module cache in the fixture too.
+func loadFixtureModule(t *testing.T, dir string, subdir string) *ModuleLoader {
+	loader, err := LoadModule(filepath.Join(dir, filepath.FromSlash(subdir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loader == nil {
+		t.Fatal("Expected a module")
+	}
+	loader.CacheDir = filepath.Join(dir, "modcache")
+	return loader
+}
+
+func expectPackageDir(t *testing.T, loader *ModuleLoader, importPath string, expected string) {
+	dir, err := loader.FindPackageDir(importPath)
+	if err != nil {
+		t.Errorf("%s: %s", importPath, err)
+	} else if dir != expected {
+		t.Errorf("%s: expected %s, got %s", importPath, expected, dir)
+	}
+}
+
+func expectPackageError(t *testing.T, loader *ModuleLoader, importPath string, msg string) {
+	_, err := loader.FindPackageDir(importPath)
+	if err == nil {
+		t.Errorf("%s: expected error %q", importPath, msg)
+	} else if !strings.Contains(err.Error(), msg) {
+		t.Errorf("%s: expected error %q, got %q", importPath, msg, err)
+	}
+}
+
+func TestLoadModule(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app/go.mod": appGoMod,
+		"app/cmd/tool/main.go": "package main\n",
+	})
+	defer os.RemoveAll(dir)
+	// The go.mod is found in a parent directory.
+	loader := loadFixtureModule(t, dir, "app/cmd/tool")
+	if loader.Path != "example.com/app" {
+		t.Errorf("Unexpected module path %s", loader.Path)
+	}
+	if loader.Dir != filepath.Join(dir, "app") {
+		t.Errorf("Unexpected module directory %s", loader.Dir)
+	}
+	if len(loader.Requires) != 5 || loader.Requires["github.com/BurntSushi/toml"] != "v1.3.2" ||
+			loader.Requires["example.com/moved"] != "v2.0.0" {
+		t.Errorf("Unexpected requires %v", loader.Requires)
+	}
+	if len(loader.Replaces) != 5 {
+		t.Fatalf("Expected 5 replaces, got %d", len(loader.Replaces))
+	}
+	if replace := *loader.Replaces[0]; replace != (moduleReplace{"example.com/local", "", "../local", ""}) {
+		t.Errorf("Unexpected replace %v", replace)
+	}
+	if replace := *loader.Replaces[4]; replace != (moduleReplace{"example.com/moved", "", "example.com/fork", "v2.1.0"}) {
+		t.Errorf("Unexpected replace %v", replace)
+	}
+	if loader.VendorDir != "" {
+		t.Errorf("Unexpected vendor directory %s", loader.VendorDir)
+	}
+}
+
+func TestLoadModuleErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"nomodule/go.mod": "go 1.21\n",
+		"badreplace/go.mod": "module example.com/bad\n\nreplace example.com/a => example.com/b\n",
+		"badquote/go.mod": "module \"example.com/bad\n",
+	})
+	defer os.RemoveAll(dir)
+	for subdir, msg := range map[string]string{
+		"nomodule": "go.mod: no module declaration",
+		"badreplace": "go.mod:3: replacement module without version must be directory path",
+		"badquote": "go.mod:1: invalid quoted string",
+	} {
+		_, err := LoadModule(filepath.Join(dir, subdir))
+		if err == nil {
+			t.Errorf("%s: expected error %q", subdir, msg)
+		} else if !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: expected error %q, got %q", subdir, msg, err)
+		}
+	}
+}
+
+func TestModuleCacheDir(t *testing.T) {
+	defer os.Setenv("GOMODCACHE", os.Getenv("GOMODCACHE"))
+	os.Setenv("GOMODCACHE", "/cache/mod")
+	if dir := getModuleCacheDir(); dir != "/cache/mod" {
+		t.Errorf("Expected GOMODCACHE to be used, got %s", dir)
+	}
+	os.Setenv("GOMODCACHE", "")
+	if dir := getModuleCacheDir(); !strings.HasSuffix(dir, filepath.Join("pkg", "mod")) {
+		t.Errorf("Expected the module cache in GOPATH, got %s", dir)
+	}
+}
+
+func TestFindPackageDir(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app/go.mod": appGoMod,
+		"app/util/util.go": "package util\n",
+		"local/strs/strs.go": "package strs\n",
+		"pinned/pin.go": "package pinned\n",
+		"modcache/example.com/lib@v1.2.0/geom/geom.go": "package geom\n",
+		"modcache/github.com/!burnt!sushi/toml@v1.3.2/toml.go": "package toml\n",
+		"modcache/example.com/fork@v2.1.0/fork.go": "package fork\n",
+	})
+	defer os.RemoveAll(dir)
+	loader := loadFixtureModule(t, dir, "app")
+	// Packages in the main module.
+	expectPackageDir(t, loader, "example.com/app", filepath.Join(dir, "app"))
+	expectPackageDir(t, loader, "example.com/app/util", filepath.Join(dir, "app", "util"))
+	// Packages in the module cache, whose paths escape uppercase letters.
+	expectPackageDir(t, loader, "example.com/lib/geom",
+		filepath.Join(dir, "modcache", "example.com", "lib@v1.2.0", "geom"))
+	expectPackageDir(t, loader, "github.com/BurntSushi/toml",
+		filepath.Join(dir, "modcache", "github.com", "!burnt!sushi", "toml@v1.3.2"))
+	// Modules replaced by local directories or by other modules.
+	expectPackageDir(t, loader, "example.com/local/strs", filepath.Join(dir, "local", "strs"))
+	expectPackageDir(t, loader, "example.com/pinned", filepath.Join(dir, "pinned"))
+	expectPackageDir(t, loader, "example.com/moved",
+		filepath.Join(dir, "modcache", "example.com", "fork@v2.1.0"))
+}
+
+func TestFindPackageDirNotFound(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app/go.mod": appGoMod,
+		"modcache/example.com/lib@v1.2.0/geom/geom.go": "package geom\n",
+	})
+	defer os.RemoveAll(dir)
+	loader := loadFixtureModule(t, dir, "app")
+	expectPackageError(t, loader, "example.com/other/pkg",
+		"no module required by example.com/app provides it")
+	expectPackageError(t, loader, "example.com/app/missing",
+		"module example.com/app does not contain it")
+	expectPackageError(t, loader, "example.com/lib/missing",
+		"module example.com/lib@v1.2.0 does not contain it")
+	expectPackageError(t, loader, "github.com/BurntSushi/toml",
+		"module github.com/BurntSushi/toml@v1.3.2 is not in the module cache")
+	expectPackageError(t, loader, "example.com/local/strs",
+		"replacement directory " + filepath.Join(dir, "local") + " for module example.com/local does not exist")
+	loader.CacheDir = ""
+	expectPackageError(t, loader, "example.com/lib/geom",
+		"no module cache to find example.com/lib@v1.2.0 in")
+}
+
+func TestFindPackageDirVendor(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app/go.mod": appGoMod,
+		"app/util/util.go": "package util\n",
+		"app/vendor/modules.txt": "# example.com/lib v1.2.0\nexample.com/lib/geom\n",
+		"app/vendor/example.com/lib/geom/geom.go": "package geom\n",
+	})
+	defer os.RemoveAll(dir)
+	loader := loadFixtureModule(t, dir, "app")
+	if loader.VendorDir != filepath.Join(dir, "app", "vendor") {
+		t.Errorf("Unexpected vendor directory %s", loader.VendorDir)
+	}
+	// Vendored packages are used instead of the module cache and replaces.
+	expectPackageDir(t, loader, "example.com/lib/geom",
+		filepath.Join(dir, "app", "vendor", "example.com", "lib", "geom"))
+	expectPackageDir(t, loader, "example.com/app/util", filepath.Join(dir, "app", "util"))
+	expectPackageError(t, loader, "example.com/local/strs", "in vendor directory")
+}
+
+func TestEscapeModulePath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"example.com/lib": "example.com/lib",
+		"github.com/BurntSushi/toml": "github.com/!burnt!sushi/toml",
+		"v1.0.0-RC1": "v1.0.0-!r!c1",
+	} {
+		if escaped := escapeModulePath(path); escaped != expected {
+			t.Errorf("Expected %s to be escaped as %s, got %s", path, expected, escaped)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Command nativegen generates apruntime.NativePackage bindings for Go packages,
+// so that interpreted code can import them. It's run by go generate in the
+// stdlib package, with the import paths of the packages to bind:
+//
+//	nativegen -dir . -package stdlib bytes strings unicode/utf8
+//
+// Each package gets its own file, named after its import path, and
+// packages.go lists all of the packages.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/constant"
+	"go/format"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory to write the bindings to")
+	packageName := flag.String("package", "stdlib", "package name of the generated files")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("usage: nativegen [-dir dir] [-package name] importpath...")
+	}
+
+	// Type information comes from the packages' source, which works even
+	// when there's no compiled export data.
+	imp := importer.ForCompiler(token.NewFileSet(), "source", nil)
+	varNames := []string{}
+	for _, path := range flag.Args() {
+		pack, err := imp.Import(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		varName := bindingVarName(path)
+		fileName := strings.Replace(path, "/", "_", -1) + ".go"
+		writeSource(filepath.Join(*dir, fileName), generatePackage(*packageName, pack, varName))
+		varNames = append(varNames, varName)
+	}
+	writeSource(filepath.Join(*dir, "packages.go"), generatePackageList(*packageName, varNames))
+}
+
+// The variable for a package's binding is named after its import path, like
+// UnicodeUtf8Package for unicode/utf8, so that packages with the same name
+// don't conflict.
+func bindingVarName(path string) string {
+	name := ""
+	for _, part := range strings.FieldsFunc(path, isPathSeparator) {
+		name += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return name + "Package"
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '.' || r == '-' || r == '_'
+}
+
+const header = "// Code generated by nativegen; DO NOT EDIT.\n\n"
+
+func generatePackage(packageName string, pack *types.Package, varName string) []byte {
+	funcs, consts, vars, typeNames := []string{}, []string{}, []string{}, []string{}
+	untypedConsts := []string{}
+	scope := pack.Scope()
+	// Names are sorted, so the output is deterministic.
+	for _, name := range scope.Names() {
+		if !token.IsExported(name) {
+			continue
+		}
+		qualified := pack.Name() + "." + name
+		switch obj := scope.Lookup(name).(type) {
+		case *types.Func:
+			// Generic functions can't be used as values without
+			// instantiating them.
+			if obj.Type().(*types.Signature).TypeParams().Len() == 0 {
+				funcs = append(funcs, entry(name, qualified))
+			}
+		case *types.Const:
+			if expr, ok := constExpr(obj, qualified); ok {
+				consts = append(consts, entry(name, expr))
+			}
+			if isUntyped(obj.Type()) {
+				untypedConsts = append(untypedConsts, entry(name, "true"))
+			}
+		case *types.Var:
+			vars = append(vars, entry(name, "&" + qualified))
+		case *types.TypeName:
+			if !isGeneric(obj.Type()) {
+				typeNames = append(typeNames, entry(name,
+					fmt.Sprintf("reflect.TypeOf((*%s)(nil)).Elem()", qualified)))
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+	fmt.Fprintf(&buf, "import (\n%q\n%q\n%q\n)\n\n",
+		"github.com/alangpierce/apgo/apruntime", pack.Path(), "reflect")
+	fmt.Fprintf(&buf, "var %s = &apruntime.NativePackage{\n", varName)
+	fmt.Fprintf(&buf, "Path: %q,\n", pack.Path())
+	fmt.Fprintf(&buf, "Name: %q,\n", pack.Name())
+	writeMap(&buf, "Funcs", "interface{}", funcs)
+	writeMap(&buf, "Consts", "interface{}", consts)
+	writeMap(&buf, "UntypedConsts", "bool", untypedConsts)
+	writeMap(&buf, "Vars", "interface{}", vars)
+	writeMap(&buf, "Types", "reflect.Type", typeNames)
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func entry(name string, expr string) string {
+	return fmt.Sprintf("%q: %s,\n", name, expr)
+}
+
+func writeMap(buf *bytes.Buffer, fieldName string, valueType string, entries []string) {
+	fmt.Fprintf(buf, "%s: map[string]%s{\n", fieldName, valueType)
+	for _, entry := range entries {
+		buf.WriteString(entry)
+	}
+	buf.WriteString("},\n")
+}
+
+// Get the expression for a constant's value. Typed constants keep their type,
+// and untyped constants get their default type, like they would when assigned
+// to a variable, except for integers too big for an int, which are uint64.
+// Returns false if the constant doesn't fit in any Go type.
+func constExpr(obj *types.Const, qualified string) (string, bool) {
+	if !isUntyped(obj.Type()) || obj.Val().Kind() != constant.Int {
+		return qualified, true
+	}
+	if _, exact := constant.Int64Val(obj.Val()); exact {
+		return qualified, true
+	}
+	if _, exact := constant.Uint64Val(obj.Val()); exact {
+		return "uint64(" + qualified + ")", true
+	}
+	return "", false
+}
+
+func isUntyped(t types.Type) bool {
+	basic, ok := t.(*types.Basic)
+	return ok && basic.Info() & types.IsUntyped != 0
+}
+
+func isGeneric(t types.Type) bool {
+	generic, ok := t.(interface {
+		TypeParams() *types.TypeParamList
+	})
+	return ok && generic.TypeParams().Len() > 0
+}
+
+func generatePackageList(packageName string, varNames []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+	buf.WriteString("import \"github.com/alangpierce/apgo/apruntime\"\n\n")
+	buf.WriteString("// All packages with generated bindings.\n")
+	buf.WriteString("var Packages = []*apruntime.NativePackage{\n")
+	for _, varName := range varNames {
+		fmt.Fprintf(&buf, "%s,\n", varName)
+	}
+	buf.WriteString("}\n")
+	return buf.Bytes()
+}
+
+func writeSource(path string, src []byte) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		log.Fatal(fmt.Sprint("Generated invalid code for ", path, ": ", err))
+	}
+	if err := ioutil.WriteFile(path, formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
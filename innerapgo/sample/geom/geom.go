@@ -0,0 +1,63 @@
+// Package geom is imported by the sample to test interpreted imports.
+package geom
+
+type Vec struct {
+	X, Y int
+}
+
+func (v Vec) Add(other Vec) Vec {
+	return Vec{v.X + other.X, v.Y + other.Y}
+}
+
+func (v *Vec) Scale(factor int) {
+	v.X *= factor
+	v.Y *= factor
+}
+
+const Dimensions = 2
+
+type Direction int
+
+const (
+	North Direction = iota
+	East
+	South
+	West
+)
+
+var Origin = Vec{0, 0}
+
+var Unit Vec
+
+var InitCount int
+
+func init() {
+	Unit = Vec{1, 1}
+	InitCount++
+}
+
+func Sum(vecs ...Vec) Vec {
+	result := Origin
+	for i := 0; i < len(vecs); i++ {
+		result = result.Add(vecs[i])
+	}
+	return result
+}
+
+// Point has the same name as a type in the sample, but is a different type.
+type Point struct {
+	X, Y int
+}
+
+func (p Point) Sum() int {
+	return p.X + p.Y
+}
+
+type Rect struct {
+	Min, Max Point
+}
+
+func (r *Rect) Grow(n int) {
+	r.Max.X += n
+	r.Max.Y += n
+}
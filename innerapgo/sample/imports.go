@@ -0,0 +1,20 @@
+package main
+
+// Imports are per-file, so these are in their own file to check that they
+// don't affect sample.go.
+import (
+	f "fmt"
+	. "github.com/alangpierce/apgo/sample/shapes"
+	_ "github.com/alangpierce/apgo/sample/shapes/register"
+)
+
+func testImportForms() {
+	assertEqual("3", f.Sprint(3))
+	square := NewSquare(3)
+	assertEqual(9, square.Area())
+	var other Square = Square{2}
+	assertEqual(4, other.Area())
+	assertEqual(1, len(Registered))
+	assertEqual("register", Registered[0])
+	assertEqual(5, Larger(2, 5))
+}
@@ -1,7 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/alangpierce/apgo/sample/geom"
+	"image"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,6 +35,21 @@ func addOne(x int) int {
 
 func testMath() {
 	assertEqual(2, 1 + 1)
+	// Untyped constants take the type of the other operand.
+	n := 7
+	assertEqual(3, n / 2.0)
+	assertEqual(7, n * 1.0)
+	var f float32 = 3
+	assertEqual(float32(1.5), f / 2)
+	var b byte = 'a'
+	assertEqual(byte('b'), b + 1)
+	b += 2
+	assertEqual(byte(99), b)
+	x := 2.5
+	x++
+	assertEqual(-3.5, -x)
+	var i interface{} = int8(5)
+	assertEqual(false, i == 5)
 }
 
 func testFunctions() {
@@ -31,10 +57,49 @@ func testFunctions() {
 	assertEqual(2, addOne(1))
 }
 
+// Package-level variables are initialized after the variables they depend on,
+// including ones used by the functions they call.
+var initSum = initBase + 1
+var initBase = scaledStart()
+var initStart = 5
+
+func scaledStart() int {
+	return initStart * 2
+}
+
 func testVariables() {
+	assertEqual(11, initSum)
 	var x, y int
 	assertEqual(0, x)
 	assertEqual(0, y)
+
+	var quotient, remainder = divMod(7, 2)
+	assertEqual(3, quotient)
+	assertEqual(1, remainder)
+	var q, r int = divMod(9, 4)
+	assertEqual(2, q)
+	assertEqual(1, r)
+	var (
+		first = 1
+		second = first + 1
+	)
+	assertEqual(2, second)
+
+	ages := map[string]int{"ann": 30}
+	age, ok := ages["ann"]
+	assertEqual(30, age)
+	assertEqual(true, ok)
+	age, ok = ages["bob"]
+	assertEqual(0, age)
+	assertEqual(false, ok)
+	var anything interface{} = "str"
+	n, ok := anything.(int)
+	assertEqual(0, n)
+	assertEqual(false, ok)
+}
+
+func divMod(a int, b int) (int, int) {
+	return a / b, a % b
 }
 
 func testForLoop() {
@@ -119,6 +184,1348 @@ func testMethods() {
 	assertEqual(3, getFn())
 }
 
+type Tree struct {
+	Left, Right *Tree
+	Val int
+}
+
+// Nil trees are empty, so methods are called on nil pointers.
+func (t *Tree) Count() int {
+	if t == nil {
+		return 0
+	}
+	return 1 + t.Left.Count() + t.Right.Count()
+}
+
+func (t *Tree) Insert(val int) *Tree {
+	if t == nil {
+		return &Tree{nil, nil, val}
+	}
+	if val < t.Val {
+		t.Left = t.Left.Insert(val)
+	} else {
+		t.Right = t.Right.Insert(val)
+	}
+	return t
+}
+
+func findTree(t *Tree, val int) *Tree {
+	if t == nil || t.Val == val {
+		return t
+	}
+	return nil
+}
+
+func testNilReceivers() {
+	var root *Tree
+	assertEqual(0, root.Count())
+	root = root.Insert(5)
+	root.Insert(3)
+	root.Insert(8)
+	root.Insert(4)
+	assertEqual(4, root.Count())
+	assertEqual(4, root.Left.Right.Val)
+	assertEqual(0, findTree(root, 7).Count())
+	assertEqual(true, findTree(root, 7) == nil)
+	assertEqual(true, root.Left.Left == nil)
+	root.Left = nil
+	assertEqual(2, root.Count())
+	empty := new(Tree)
+	assertEqual(1, empty.Count())
+	assertEqual("<nil> 5", fmt.Sprint(root.Left, " ", root.Val))
+}
+
+type Celsius float64
+
+type Fahrenheit float64
+
+func (c Celsius) ToFahrenheit() Fahrenheit {
+	return Fahrenheit(c * 9 / 5 + 32)
+}
+
+func (f Fahrenheit) IsHot() bool {
+	return f > 80
+}
+
+type IntList []int
+
+func (l IntList) Get(i int) int {
+	return l[i]
+}
+
+type IntTransform func(int) int
+
+func (t IntTransform) Twice(x int) int {
+	return t(t(x))
+}
+
+func addThree(x int) int {
+	return x + 3
+}
+
+func testNamedTypes() {
+	boiling := Celsius(100)
+	assertEqual(Fahrenheit(212), boiling.ToFahrenheit())
+	assertEqual(true, boiling.ToFahrenheit().IsHot())
+	var freezing Celsius
+	assertEqual(Fahrenheit(32), freezing.ToFahrenheit())
+	assertEqual(false, freezing.ToFahrenheit().IsHot())
+	doubled := boiling * 2
+	assertEqual(Fahrenheit(392), doubled.ToFahrenheit())
+	assertEqual(150.0, float64(boiling + 50))
+
+	list := IntList{3, 5, 7}
+	assertEqual(5, list.Get(1))
+	list[1] = 6
+	assertEqual(6, list.Get(1))
+
+	var transform IntTransform = addThree
+	assertEqual(5, transform(2))
+	assertEqual(8, transform.Twice(2))
+	assertEqual(true, transform != nil)
+	var noTransform IntTransform
+	assertEqual(true, noTransform == nil)
+	assertEqual(10, IntTransform(addThree)(7))
+}
+
+type Named struct {
+	name string
+}
+
+func (n Named) Name() string {
+	return n.name
+}
+
+func (n *Named) Rename(name string) {
+	n.name = name
+}
+
+type Counter struct {
+	count int
+}
+
+func (c *Counter) Increment() {
+	c.count++
+}
+
+func (c Counter) Describe() string {
+	return "counter"
+}
+
+type Widget struct {
+	Named
+	*Counter
+	width, height int
+}
+
+// Shadows the promoted method from Counter.
+func (w Widget) Describe() string {
+	return "widget"
+}
+
+type Gadget struct {
+	Widget
+	name string
+}
+
+type Label struct{}
+
+func (Label) Name() string {
+	return "label"
+}
+
+// Name is promoted from both embedded types, so it's ambiguous.
+type Tag struct {
+	Named
+	Label
+}
+
+type NameHaver interface {
+	Name() string
+}
+
+type Incrementer interface {
+	Increment()
+}
+
+type Renamer interface {
+	NameHaver
+	Rename(name string)
+}
+
+func testEmbeddedStructs() {
+	w := Widget{Named{"knob"}, &Counter{}, 3, 4}
+	assertEqual("knob", w.name)
+	assertEqual("knob", w.Name())
+	assertEqual(12, w.width * w.height)
+	w.Increment()
+	w.Increment()
+	assertEqual(2, w.count)
+	assertEqual(2, w.Counter.count)
+	assertEqual("widget", w.Describe())
+	assertEqual("counter", w.Counter.Describe())
+	w.Rename("dial")
+	assertEqual("dial", w.Named.name)
+
+	var zero Widget
+	assertEqual("", zero.Name())
+	assertEqual(0, zero.width)
+
+	// The shallower name field shadows the promoted one.
+	g := Gadget{w, "gadget"}
+	assertEqual("gadget", g.name)
+	assertEqual("dial", g.Name())
+	assertEqual(2, g.count)
+
+	var i interface{} = w
+	assertEqual("dial", i.(NameHaver).Name())
+	// Increment is promoted through an embedded pointer, so it's in the
+	// method set of the value.
+	i.(Incrementer).Increment()
+	assertEqual(3, w.count)
+	var r Renamer = &w
+	r.Rename("switch")
+	assertEqual("switch", w.Name())
+
+	assertEqual("label", Label{}.Name())
+	nameHaver, ok := i.(NameHaver)
+	assertEqual(true, ok)
+	assertEqual("dial", nameHaver.Name())
+	var tag interface{} = Tag{}
+	_, ok = tag.(NameHaver)
+	assertEqual(false, ok)
+	_, ok = tag.(Incrementer)
+	assertEqual(false, ok)
+	widget, ok := i.(Widget)
+	assertEqual(true, ok)
+	assertEqual(3, widget.width)
+	gadget, ok := i.(Gadget)
+	assertEqual(false, ok)
+	assertEqual("", gadget.name)
+}
+
+func sum(nums ...int) int {
+	total := 0
+	for i := 0; i < len(nums); i++ {
+		total += nums[i]
+	}
+	return total
+}
+
+func joinWith(sep string, parts ...string) string {
+	result := ""
+	for i := 0; i < len(parts); i++ {
+		if i > 0 {
+			result += sep
+		}
+		result += parts[i]
+	}
+	return result
+}
+
+func variadicSlice(vals ...interface{}) []interface{} {
+	return vals
+}
+
+func testVariadic() {
+	assertEqual(0, sum())
+	assertEqual(6, sum(1, 2, 3))
+	nums := []int{4, 5, 6}
+	assertEqual(15, sum(nums...))
+	// Spreading a slice passes it through without copying.
+	nums[0] = 10
+	assertEqual(21, sum(nums...))
+	assertEqual("a-b-c", joinWith("-", "a", "b", "c"))
+	assertEqual("", joinWith("-"))
+	assertEqual(true, variadicSlice() == nil)
+	assertEqual(2, len(variadicSlice(1, "two")))
+
+	parts := []interface{}{1, "x", 2}
+	assertEqual("1x2", fmt.Sprint(parts...))
+	assertEqual("1x2", fmt.Sprint(variadicSlice(1, "x", 2)...))
+	assertEqual("3 4", fmt.Sprint(3, " ", 4))
+}
+
+func half(x float64) float64 {
+	return x / 2
+}
+
+func divmod(a, b int) (int, int) {
+	return a / b, a % b
+}
+
+func forwardResults() (int, int) {
+	return divmod(17, 5)
+}
+
+func describe(n int) (doubled int, description string) {
+	if n < 0 {
+		return
+	}
+	doubled = n * 2
+	description = "non-negative"
+	return
+}
+
+func floatResult() float64 {
+	return 3
+}
+
+func noError() error {
+	return nil
+}
+
+func testSignatures() {
+	assertEqual(1.5, half(3))
+	q, r := divmod(17, 5)
+	assertEqual(3, q)
+	assertEqual(2, r)
+	q, r = forwardResults()
+	assertEqual(3, q)
+	assertEqual(2, r)
+	d, desc := describe(-1)
+	assertEqual(0, d)
+	assertEqual("", desc)
+	d, desc = describe(4)
+	assertEqual(8, d)
+	assertEqual("non-negative", desc)
+	assertEqual(3.0, floatResult())
+	assertEqual(5, sum(divmod(17, 5)))
+	assertEqual(true, noError() == nil)
+	var err error
+	assertEqual(true, err == nil)
+}
+
+type Number interface {
+	~int | ~float64
+}
+
+func Sum[T Number](nums []T) T {
+	var total T
+	for i := 0; i < len(nums); i++ {
+		total += nums[i]
+	}
+	return total
+}
+
+func Map[T, U any](items []T, f func(T) U) []U {
+	result := make([]U, 0, len(items))
+	for i := 0; i < len(items); i++ {
+		result = append(result, f(items[i]))
+	}
+	return result
+}
+
+func Max[T int | float64 | string](first T, rest ...T) T {
+	result := first
+	for i := 0; i < len(rest); i++ {
+		if rest[i] > result {
+			result = rest[i]
+		}
+	}
+	return result
+}
+
+func Zero[T any]() T {
+	var zero T
+	return zero
+}
+
+func Last[S ~[]E, E any](s S) E {
+	return s[len(s)-1]
+}
+
+func halve(x int) float64 {
+	return float64(x) / 2
+}
+
+func describeCelsius(c Celsius) string {
+	return fmt.Sprint(float64(c), "C")
+}
+
+type Stack[T any] struct {
+	items []T
+	size  int
+}
+
+func (s *Stack[T]) Push(item T) {
+	if s.size < len(s.items) {
+		s.items[s.size] = item
+	} else {
+		s.items = append(s.items, item)
+	}
+	s.size++
+}
+
+func (s *Stack[T]) Pop() T {
+	s.size--
+	return s.items[s.size]
+}
+
+func (s Stack[T]) Len() int {
+	return s.size
+}
+
+type Pair[K, V any] struct {
+	Key K
+	Val V
+}
+
+func (p Pair[K, V]) Swap() Pair[V, K] {
+	return Pair[V, K]{p.Val, p.Key}
+}
+
+type Set[T comparable] map[T]bool
+
+func (s Set[T]) Add(item T) {
+	s[item] = true
+}
+
+func (s Set[E]) Has(item E) bool {
+	return s[item]
+}
+
+func NewSet[T comparable](items ...T) Set[T] {
+	result := make(Set[T])
+	for i := 0; i < len(items); i++ {
+		result.Add(items[i])
+	}
+	return result
+}
+
+func testGenerics() {
+	assertEqual(6, Sum([]int{1, 2, 3}))
+	assertEqual(4.5, Sum([]float64{1.5, 3}))
+	assertEqual(Celsius(30), Sum([]Celsius{10, 20}))
+	assertEqual(0, Sum[int](nil))
+
+	strs := Map([]Celsius{1.5, 2}, describeCelsius)
+	assertEqual("1.5C", strs[0])
+	assertEqual("2C", strs[1])
+	halves := Map[int, float64]([]int{1, 3}, halve)
+	assertEqual(1.5, halves[1])
+
+	assertEqual(7, Max(3, 7, 5))
+	assertEqual(7.5, Max(2, 7.5))
+	assertEqual("pear", Max("apple", "pear"))
+	assertEqual(0, Zero[int]())
+	assertEqual("", Zero[string]())
+	assertEqual(true, Zero[*Stack[int]]() == nil)
+	assertEqual(3, Last(IntList{1, 2, 3}))
+
+	var s Stack[string]
+	s.Push("a")
+	s.Push("b")
+	assertEqual(2, s.Len())
+	assertEqual("b", s.Pop())
+	assertEqual(1, s.Len())
+	nums := &Stack[int]{}
+	nums.Push(4)
+	assertEqual(4, nums.Pop())
+
+	p := Pair[string, int]{"x", 1}
+	swapped := p.Swap()
+	assertEqual(1, swapped.Key)
+	assertEqual("x", swapped.Val)
+	var anyPair interface{} = swapped
+	assertEqual("x", anyPair.(Pair[int, string]).Val)
+
+	set := NewSet("a", "b")
+	assertEqual(true, set.Has("a"))
+	assertEqual(false, set.Has("c"))
+	set.Add("c")
+	assertEqual(3, len(set))
+}
+
+type Tally int
+
+func (t *Tally) Add(n int) {
+	*t += Tally(n)
+}
+
+func (t Tally) Doubled() Tally {
+	return t * 2
+}
+
+func testMethodValues() {
+	sample := StructWithMethods{3}
+	getVal := StructWithMethods.getVal
+	assertEqual(3, getVal(sample))
+	setVal := (*StructWithMethods).correctlySetValToOne
+	setVal(&sample)
+	assertEqual(1, sample.val)
+	assertEqual(2, (*StructWithMethods).getValPlusOne(&sample))
+	// Value methods are also in the method set of the pointer type.
+	assertEqual(1, (*StructWithMethods).getVal(&sample))
+
+	// The receiver is dereferenced when the method value is created.
+	ptr := &sample
+	getFromPtr := ptr.getVal
+	ptr.val = 5
+	assertEqual(1, getFromPtr())
+	// Pointer methods take the address of the receiver, so later changes
+	// are visible to the method.
+	plusOne := sample.getValPlusOne
+	sample.val = 7
+	assertEqual(8, plusOne())
+
+	var t Tally
+	t.Add(2)
+	add := t.Add
+	add(3)
+	assertEqual(Tally(5), t)
+	assertEqual(Tally(10), Tally.Doubled(t))
+	(*Tally).Add(&t, 1)
+	assertEqual(Tally(6), t)
+
+	// Method values and expressions work through interfaces and embedded
+	// fields.
+	w := Widget{Named{"knob"}, &Counter{}, 3, 4}
+	var nh NameHaver = w
+	nameFn := nh.Name
+	assertEqual("knob", nameFn())
+	assertEqual("knob", Widget.Name(w))
+	increment := w.Increment
+	increment()
+	assertEqual(1, w.count)
+	(*Widget).Rename(&w, "dial")
+	assertEqual("dial", w.name)
+	// Interface method expressions call the method of the value they're
+	// given.
+	assertEqual("dial", NameHaver.Name(w))
+	getName := NameHaver.Name
+	assertEqual("plain", getName(Named{"plain"}))
+	Renamer.Rename(&w, "lever")
+	assertEqual("lever", Renamer.Name(&w))
+}
+
+type Point struct {
+	X, Y int
+}
+
+func testEquality() {
+	p := Point{1, 2}
+	q := Point{1, 2}
+	assertEqual(true, p == q)
+	q.Y = 3
+	assertEqual(true, p != q)
+	assertEqual(false, Point{} == p)
+
+	// Interfaces are equal if they hold equal values of the same type.
+	var a interface{} = p
+	var b interface{} = Point{1, 2}
+	assertEqual(true, a == b)
+	assertEqual(false, a == Pair[int, int]{1, 2})
+	assertEqual(true, Pair[int, string]{1, "a"} == Pair[int, string]{1, "a"})
+
+	var points [2]Point
+	var other [2]Point
+	assertEqual(true, points == other)
+
+	ptr := &p
+	assertEqual(true, ptr == &p)
+	assertEqual(false, ptr == &q)
+	assertEqual(true, ptr != nil)
+
+	counts := make(map[Point]int)
+	counts[p] = 1
+	counts[Point{1, 2}] += 2
+	counts[q] = 5
+	assertEqual(3, counts[Point{1, 2}])
+	assertEqual(2, len(counts))
+	delete(counts, Point{1, 3})
+	assertEqual(0, counts[q])
+
+	seen := make(map[interface{}]int)
+	seen[1] = 1
+	seen[Celsius(1)] = 2
+	seen[p] = 3
+	assertEqual(1, seen[1])
+	assertEqual(2, seen[Celsius(1)])
+	assertEqual(3, seen[Point{1, 2}])
+	assertEqual(3, len(seen))
+
+	// Arrays of structs are compared element by element as keys too.
+	byCorners := map[[2]Point]int{}
+	byCorners[[2]Point{{1, 2}, {3, 4}}] = 5
+	byCorners[[2]Point{{1, 2}, {3, 4}}]++
+	assertEqual(6, byCorners[[2]Point{{1, 2}, {3, 4}}])
+	assertEqual(1, len(byCorners))
+	grid := map[[2][2]Celsius]string{{{1, 2}, {3, 4}}: "a"}
+	assertEqual("a", grid[[2][2]Celsius{{1, 2}, {3, 4}}])
+	assertEqual(true, [2][2]Point{} == [2][2]Point{})
+}
+
+type Segment struct {
+	Start, End Point
+}
+
+func testCompositeLiterals() {
+	// Element types can be left out, including for pointers.
+	points := []Point{{1, 2}, {Y: 3}}
+	assertEqual(3, points[1].Y)
+	ptrs := []*Point{{5, 6}, nil}
+	assertEqual(6, ptrs[0].Y)
+	assertEqual(true, ptrs[1] == nil)
+	segments := [][]Segment{{{Point{1, 1}, Point{2, 2}}}, {{End: Point{Y: 4}}}}
+	assertEqual(4, segments[1][0].End.Y)
+
+	person := struct {
+		Name string
+		Age int
+	}{"Ann", 30}
+	assertEqual("Ann", person.Name)
+	person.Age++
+	assertEqual(31, person.Age)
+	var empty struct{ Count int }
+	assertEqual(0, empty.Count)
+
+	byName := map[string]Point{"a": {1, 2}, "b": {Y: 7}}
+	assertEqual(7, byName["b"].Y)
+	assertEqual(2, len(byName))
+	byPoint := map[Point]string{{1, 2}: "x"}
+	assertEqual("x", byPoint[Point{1, 2}])
+	temps := map[string]Celsius{"boiling": 100}
+	assertEqual(Celsius(100), temps["boiling"])
+
+	// Keyed elements set specific indices.
+	sparse := []string{2: "c", "d", 0: "a"}
+	assertEqual(4, len(sparse))
+	assertEqual("", sparse[1])
+	assertEqual("d", sparse[3])
+	padded := [4]int{1, 2}
+	assertEqual(0, padded[3])
+	assertEqual(4, len(padded))
+	counted := [...]Point{2: {1, 1}}
+	assertEqual(3, len(counted))
+	assertEqual(1, counted[2].X)
+
+	// Array elements can be assigned, and arrays are copied as values.
+	arr := [3]int{1, 2, 3}
+	arr[1] = 5
+	arr[2] += 10
+	assertEqual(5, arr[1])
+	assertEqual(13, arr[2])
+	arrCopy := arr
+	arrCopy[0] = 100
+	assertEqual(1, arr[0])
+	var readings [3]Celsius
+	readings[1] = 5
+	assertEqual(Celsius(5), readings[1])
+	assertEqual(Fahrenheit(41), readings[1].ToFahrenheit())
+	assertEqual(Celsius(0), readings[2])
+	var corners [2]Point
+	corners[0].X = 4
+	assertEqual(4, corners[0].X)
+	assertEqual(0, corners[1].Y)
+	var grid [2][2]int
+	grid[1][0] = 9
+	assertEqual(9, grid[1][0])
+	segment := struct{ Ends [2]Point }{}
+	segment.Ends[1].Y = 3
+	assertEqual(3, segment.Ends[1].Y)
+	arrPtr := &arr
+	arrPtr[0] = 7
+	assertEqual(7, arr[0])
+}
+
+func nextIndex(n *int) int {
+	*n++
+	return *n
+}
+
+func testAssignment() {
+	// The left side of an operator assignment is only evaluated once.
+	n := 0
+	nums := []int{0, 0, 0}
+	nums[nextIndex(&n)] += 5
+	assertEqual(1, n)
+	assertEqual(5, nums[1])
+	nums[nextIndex(&n)]++
+	assertEqual(2, n)
+	assertEqual(1, nums[2])
+
+	i, j := 0, 2
+	nums[i], nums[j] = nums[j], nums[i]
+	assertEqual(1, nums[0])
+	assertEqual(0, nums[2])
+	// Index expressions on the left use the values from before the
+	// assignment.
+	i, nums[i] = 1, 8
+	assertEqual(1, i)
+	assertEqual(8, nums[0])
+
+	counts := map[string]int{}
+	counts["a"] += 3
+	counts["a"]++
+	assertEqual(4, counts["a"])
+
+	// Structs are copied on assignment.
+	points := []Point{{1, 1}, {2, 2}}
+	points[0], points[1] = points[1], points[0]
+	points[0].X = 9
+	assertEqual(1, points[1].X)
+	copied := points[1]
+	copied.Y = 7
+	assertEqual(1, points[1].Y)
+	segment := Segment{Point{1, 1}, Point{2, 2}}
+	other := segment
+	other.Start.X = 4
+	assertEqual(1, segment.Start.X)
+
+	// Swaps work on array elements and struct fields too.
+	arr := [3]int{1, 2, 3}
+	arr[0], arr[2] = arr[2], arr[0]
+	assertEqual(3, arr[0])
+	assertEqual(1, arr[2])
+	corners := [2]Point{{1, 1}, {2, 2}}
+	corners[0], corners[1] = corners[1], corners[0]
+	corners[0].X = 9
+	assertEqual(1, corners[1].X)
+	p := Point{1, 2}
+	p.X, p.Y = p.Y, p.X
+	assertEqual(2, p.X)
+	assertEqual(1, p.Y)
+	segment.Start, segment.End = segment.End, segment.Start
+	segment.Start.X = 5
+	assertEqual(1, segment.End.X)
+	assertEqual(2, segment.Start.Y)
+
+	// Untyped constants take the type of the location they're assigned
+	// to, which is known at compile time.
+	currentTemp = 5
+	assertEqual(Celsius(5), currentTemp)
+	forecast := Forecast{High: 30}
+	assertEqual(Celsius(30), forecast.High)
+	forecast.Low = 10
+	coldest := forecast.Coldest()
+	coldest = 12
+	assertEqual(Celsius(12), coldest)
+	var any interface{} = Celsius(1)
+	any = 5
+	assertEqual(5, any)
+	var index byte = 2
+	assertEqual(30, []int{10, 20, 30}[index])
+}
+
+var currentTemp Celsius
+
+type Forecast struct {
+	High, Low Celsius
+}
+
+func (f Forecast) Coldest() Celsius {
+	return f.Low
+}
+
+func testShadowing() {
+	// Builtins can be shadowed like any other name.
+	cap := 10
+	assertEqual(10, cap)
+	assertEqual(3, len([]int{1, 2, 3}))
+	new := nextIndex
+	n := 2
+	assertEqual(3, new(&n))
+
+	// Variables declared in a block shadow outer ones only within it.
+	x := 1
+	{
+		x := 2
+		x++
+		assertEqual(3, x)
+	}
+	assertEqual(1, x)
+	for i := 0; i < 2; i++ {
+		x := i + 100
+		assertEqual(i + 100, x)
+	}
+	assertEqual(1, x)
+	{
+		nextIndex := 5
+		assertEqual(5, nextIndex)
+	}
+	assertEqual(4, nextIndex(&n))
+}
+
+func testImports() {
+	v := geom.Vec{2, 3}
+	assertEqual(3, v.Add(geom.Unit).X)
+	v.Scale(2)
+	assertEqual(6, v.Y)
+	assertEqual(geom.Vec{5, 7}, geom.Sum(v, geom.Unit))
+	assertEqual(1, geom.InitCount)
+	geom.Origin = geom.Vec{10, 0}
+	assertEqual(11, geom.Sum(geom.Unit).X)
+	geom.Origin = geom.Vec{}
+	var add func(geom.Vec, geom.Vec) geom.Vec = geom.Vec.Add
+	assertEqual(geom.Vec{3, 4}, add(geom.Unit, geom.Vec{2, 3}))
+}
+
+type LabeledPoint struct {
+	geom.Point
+	Label string
+}
+
+func testTypeIdentity() {
+	var a interface{} = Point{1, 2}
+	var b interface{} = geom.Point{1, 2}
+	assertEqual(false, a == b)
+	assertEqual(true, b == geom.Point{1, 2})
+	assertEqual(3, b.(geom.Point).Sum())
+	var r geom.Rect
+	r.Grow(2)
+	assertEqual(4, r.Max.Sum())
+	assertEqual(0, r.Min.Sum())
+	l := LabeledPoint{geom.Point{3, 4}, "a"}
+	assertEqual(7, l.Sum())
+	assertEqual(3, l.Point.X)
+}
+
+func testStdlib() {
+	assertEqual("GOPHER", strings.ToUpper("gopher"))
+	assertEqual("ababab", strings.Repeat("ab", 3))
+	assertEqual(true, strings.HasPrefix("interpreter", "inter"))
+	assertEqual("42", strconv.Itoa(42))
+	nums := []int{3, 1, 2}
+	sort.Ints(nums)
+	assertEqual(1, nums[0])
+	assertEqual(3, nums[2])
+}
+
+func testNativeGlobals() {
+	assertEqual(true, math.Pi > 3.14 && math.Pi < 3.15)
+	assertEqual(9223372036854775807, math.MaxInt64)
+	assertEqual(time.Millisecond * 1000, time.Second)
+	assertEqual(int64(2000000000), int64(2 * time.Second))
+	assertEqual(true, io.EOF != nil)
+	assertEqual(io.EOF, io.EOF)
+	savedArgs := os.Args
+	os.Args = []string{"a", "b"}
+	assertEqual(2, len(os.Args))
+	assertEqual("b", os.Args[1])
+	os.Args = savedArgs
+	assertEqual(true, len(os.Args) > 0)
+}
+
+func testNativeMethods() {
+	d := 90 * time.Second
+	assertEqual(1.5, d.Minutes())
+	assertEqual("1m30s", d.String())
+	assertEqual("bbnbnb", strings.NewReplacer("a", "b").Replace("banana"))
+	reader := strings.NewReader("abc")
+	b, _ := reader.ReadByte()
+	assertEqual(byte(97), b)
+	assertEqual(2, reader.Len())
+
+	p := image.Pt(1, 2)
+	p.X = 5
+	assertEqual(5, p.X)
+	assertEqual(image.Pt(6, 3), p.Add(image.Pt(1, 1)))
+	q := p
+	q.Y = 10
+	assertEqual(2, p.Y)
+	rect := image.Rect(0, 0, 4, 3)
+	rect.Min.Y = 1
+	assertEqual(2, rect.Dy())
+	rectPtr := &rect
+	rectPtr.Max.X = 9
+	assertEqual(9, rect.Dx())
+
+	// Pointer methods on a value in a variable see the same address each
+	// time.
+	buf := *bytes.NewBufferString("a")
+	buf.WriteString("b")
+	buf.WriteString("c")
+	assertEqual("abc", buf.String())
+}
+
+type Stopwatch struct {
+	Elapsed time.Duration
+	Laps map[string]time.Duration
+	log strings.Builder
+}
+
+func (s *Stopwatch) Lap(name string, d time.Duration) {
+	s.Elapsed += d
+	s.Laps[name] = d
+	s.log.WriteString(name)
+}
+
+func appendTo(buf *bytes.Buffer, s string) {
+	buf.WriteString(s)
+}
+
+func testNativeTypes() {
+	var sb strings.Builder
+	sb.WriteString("a")
+	sb.WriteString("b")
+	assertEqual("ab", sb.String())
+	assertEqual(5 * time.Second, time.Duration(5) * time.Second)
+	times := []time.Time{}
+	assertEqual(0, len(times))
+
+	buf := &bytes.Buffer{}
+	appendTo(buf, "x")
+	io.WriteString(buf, "y")
+	var buf2 bytes.Buffer
+	io.WriteString(&buf2, "z")
+	assertEqual("xy", buf.String())
+	assertEqual("z", buf2.String())
+
+	watch := Stopwatch{Laps: map[string]time.Duration{}}
+	watch.Lap("one", time.Second)
+	watch.Lap("two", time.Minute)
+	assertEqual(61.0, watch.Elapsed.Seconds())
+	assertEqual(time.Minute, watch.Laps["two"])
+	assertEqual("onetwo", watch.log.String())
+
+	rect := image.Rectangle{Max: image.Point{X: 3, Y: 4}}
+	assertEqual(12, rect.Dx() * rect.Dy())
+	points := []image.Point{{1, 2}, {3, 4}}
+	assertEqual(4, points[1].Y)
+	ints := sort.IntSlice{3, 1, 2}
+	ints.Sort()
+	assertEqual(3, ints[2])
+
+	var reader io.Reader = strings.NewReader("abc")
+	assertEqual(3, reader.(*strings.Reader).Len())
+	_, err := strconv.Atoi("x")
+	assertEqual("x", err.(*strconv.NumError).Num)
+}
+
+var fruits = []string{"pear", "fig", "banana"}
+
+func byLength(i int, j int) bool {
+	return len(fruits[i]) < len(fruits[j])
+}
+
+func shiftRune(r rune) rune {
+	return r + 1
+}
+
+func isComma(r rune) bool {
+	return string(r) == ","
+}
+
+type Threshold struct {
+	min int
+}
+
+func (t Threshold) reached(i int) bool {
+	return i >= t.min
+}
+
+func testCallbacks() {
+	sort.Slice(fruits, byLength)
+	assertEqual("fig pear banana", strings.Join(fruits, " "))
+	assertEqual("bcd", strings.Map(shiftRune, "abc"))
+	assertEqual(3, len(strings.FieldsFunc("a,b,,c", isComma)))
+	assertEqual(7, sort.Search(10, Threshold{7}.reached))
+}
+
+func makeCounter() func() int {
+	count := 0
+	return func() int {
+		count++
+		return count
+	}
+}
+
+func testClosures() {
+	nums := []int{5, 2, 8, 1}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	assertEqual(1, nums[0])
+	assertEqual(8, nums[3])
+	points := []Point{{3, 1}, {1, 2}, {2, 0}}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].X < points[j].X
+	})
+	assertEqual(Point{1, 2}, points[0])
+	assertEqual("BCD", strings.Map(func(r rune) rune { return r - 'a' + 'B' }, "abc"))
+
+	// Closures share the variables they refer to.
+	next := makeCounter()
+	next()
+	assertEqual(2, next())
+	assertEqual(1, makeCounter()())
+	total := 0
+	add := func(n int) {
+		total += n
+	}
+	add(3)
+	add(4)
+	assertEqual(7, total)
+	var fib func(n int) int
+	fib = func(n int) int {
+		if n < 2 {
+			return n
+		}
+		return fib(n - 1) + fib(n - 2)
+	}
+	assertEqual(55, fib(10))
+	scale := func(factor int) func(int) int {
+		return func(n int) int { return n * factor }
+	}
+	assertEqual(15, scale(3)(5))
+
+	// Each loop iteration has its own variables.
+	funcs := []func() int{}
+	for i := 0; i < 3; i++ {
+		square := i * i
+		funcs = append(funcs, func() int { return i + square })
+	}
+	assertEqual(0, funcs[0]())
+	assertEqual(6, funcs[2]())
+}
+
+type Version struct {
+	Major int
+	Minor int
+}
+
+func (v Version) String() string {
+	return "v" + strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)
+}
+
+type LookupError struct {
+	key string
+}
+
+func (e *LookupError) Error() string {
+	return "missing " + e.key
+}
+
+var ErrLookup = &LookupError{"k"}
+
+func lookup() error {
+	return ErrLookup
+}
+
+type ErrorCode int
+
+func (c ErrorCode) Error() string {
+	return fmt.Sprint("error ", int(c))
+}
+
+type WrappedError struct {
+	msg string
+	err error
+}
+
+func (e WrappedError) Error() string {
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e WrappedError) Unwrap() error {
+	return e.err
+}
+
+type ByLength []string
+
+func (b ByLength) Len() int {
+	return len(b)
+}
+
+func (b ByLength) Less(i int, j int) bool {
+	return len(b[i]) < len(b[j])
+}
+
+func (b ByLength) Swap(i int, j int) {
+	tmp := b[i]
+	b[i] = b[j]
+	b[j] = tmp
+}
+
+// Reads the given number of zero bytes.
+type Zeros struct {
+	left int
+}
+
+func (z *Zeros) Read(buf []byte) (int, error) {
+	if z.left == 0 {
+		return 0, io.EOF
+	}
+	z.left = z.left - 1
+	buf[0] = 48
+	return 1, nil
+}
+
+type Shout struct {
+	out *strings.Builder
+}
+
+func (s Shout) Write(buf []byte) (int, error) {
+	s.out.WriteString(strings.ToUpper(string(buf)))
+	return len(buf), nil
+}
+
+func testNativeInterfaces() {
+	assertEqual("v1.2", fmt.Sprint(Version{1, 2}))
+	err := lookup()
+	assertEqual("missing k", fmt.Sprint(err))
+	assertEqual(true, errors.Is(err, ErrLookup))
+	assertEqual(false, errors.Is(err, io.EOF))
+	var lookupErr *LookupError
+	assertEqual(true, errors.As(fmt.Errorf("wrapped: %w", err), &lookupErr))
+	assertEqual("k", lookupErr.key)
+	assertEqual(false, errors.As(io.EOF, &lookupErr))
+	var code ErrorCode
+	assertEqual(true, errors.As(WrappedError{"outer", ErrorCode(3)}, &code))
+	assertEqual(ErrorCode(3), code)
+	assertEqual(true, errors.As(errors.Join(io.EOF, ErrorCode(5)), &code))
+	assertEqual(ErrorCode(5), code)
+	// Errors that aren't pointers are compared by value.
+	assertEqual(true, errors.Is(fmt.Errorf("wrapped: %w", ErrorCode(3)), ErrorCode(3)))
+	assertEqual(false, errors.Is(fmt.Errorf("wrapped: %w", ErrorCode(3)), ErrorCode(4)))
+	assertEqual(true, errors.Is(WrappedError{"outer", ErrorCode(6)}, WrappedError{"outer", ErrorCode(6)}))
+	assertEqual(true, errors.Is(fmt.Errorf("wrapped: %w", WrappedError{"outer", io.EOF}), io.EOF))
+	words := ByLength{"banana", "fig", "pear"}
+	sort.Sort(words)
+	assertEqual("fig pear banana", strings.Join(words, " "))
+	var buf bytes.Buffer
+	n, copyErr := io.Copy(&buf, &Zeros{3})
+	assertEqual(int64(3), n)
+	assertEqual(nil, copyErr)
+	assertEqual("000", buf.String())
+	var sb strings.Builder
+	io.WriteString(Shout{&sb}, "hey")
+	assertEqual("HEY", sb.String())
+	stringers := []fmt.Stringer{Version{2, 0}}
+	assertEqual(Version{2, 0}, stringers[0].(Version))
+}
+
+type Limits struct {
+	Max int `json:"max"`
+}
+
+type ServerConfig struct {
+	Name string `json:"name"`
+	Port int `json:"port,omitempty"`
+	Tags []string `json:"tags"`
+	Limits
+	Backup *Limits `json:"backup,omitempty"`
+	secret string
+}
+
+type ListNode struct {
+	Val int `json:"val"`
+	Next *ListNode `json:"next,omitempty"`
+}
+
+type Category struct {
+	Name string
+	Children []Category
+}
+
+func testMaterializedStructs() {
+	cfg := ServerConfig{Name: "web", Tags: []string{"a"}, secret: "s"}
+	cfg.Max = 3
+	data, err := json.Marshal(cfg)
+	assertEqual(nil, err)
+	assertEqual(`{"name":"web","tags":["a"],"max":3}`, string(data))
+	var loaded ServerConfig
+	loaded.secret = "kept"
+	err = json.Unmarshal([]byte(`{"name":"db","port":5432,"tags":["x","y"],"max":9,"backup":{"max":1}}`), &loaded)
+	assertEqual(nil, err)
+	assertEqual("db", loaded.Name)
+	assertEqual(5432, loaded.Port)
+	assertEqual("y", loaded.Tags[1])
+	assertEqual(9, loaded.Max)
+	assertEqual(1, loaded.Backup.Max)
+	assertEqual("kept", loaded.secret)
+	data, _ = json.Marshal([]Limits{{1}, {2}})
+	assertEqual(`[{"max":1},{"max":2}]`, string(data))
+	data, _ = json.Marshal(struct {
+		ID int `json:"id"`
+	}{7})
+	assertEqual(`{"id":7}`, string(data))
+	assertEqual("{2} {1 a}", fmt.Sprint(Limits{2}, Pair[int, string]{1, "a"}))
+	var list ListNode
+	err = json.Unmarshal([]byte(`{"val":1,"next":{"val":2,"next":{"val":3}}}`), &list)
+	assertEqual(nil, err)
+	assertEqual(2, list.Next.Val)
+	assertEqual(3, list.Next.Next.Val)
+	assertEqual(true, list.Next.Next.Next == nil)
+	data, _ = json.Marshal(list)
+	assertEqual(`{"val":1,"next":{"val":2,"next":{"val":3}}}`, string(data))
+	err = json.Unmarshal([]byte(`{"next":null}`), &list)
+	assertEqual(nil, err)
+	assertEqual(1, list.Val)
+	assertEqual(true, list.Next == nil)
+	var root Category
+	json.Unmarshal([]byte(`{"Name":"a","Children":[{"Name":"b","Children":[{"Name":"c"}]}]}`), &root)
+	assertEqual("c", root.Children[0].Children[0].Name)
+	data, _ = json.Marshal(root.Children[0])
+	assertEqual(`{"Name":"b","Children":[{"Name":"c","Children":null}]}`, string(data))
+}
+
+type Reading float64
+
+func (r Reading) String() string {
+	return fmt.Sprintf("%.1fC", float64(r))
+}
+
+func testFormatting() {
+	seg := Segment{Point{1, 2}, Point{3, 4}}
+	assertEqual("{{1 2} {3 4}}", fmt.Sprint(seg))
+	assertEqual("{Start:{X:1 Y:2} End:{X:3 Y:4}}", fmt.Sprintf("%+v", seg))
+	assertEqual("main.Segment{Start:main.Point{X:1, Y:2}, End:main.Point{X:3, Y:4}}", fmt.Sprintf("%#v", seg))
+	assertEqual("&{1 2}", fmt.Sprint(&Point{1, 2}))
+	assertEqual("main.Point *main.Point main.Tally []main.Point", fmt.Sprintf("%T %T %T %T", Point{}, &Point{}, Tally(1), []Point{{1, 2}}))
+	assertEqual("3 3", fmt.Sprintf("%v %#v", Tally(3), Tally(3)))
+	assertEqual("[v1.0 v2.5] missing k", fmt.Sprint([]Version{{1, 0}, {2, 5}}, " ", ErrLookup))
+	assertEqual("  1.5C|1.5C  |2.0C", fmt.Sprintf("%6v|%-6s|%v", Reading(1.5), Reading(1.5), Reading(2)))
+	assertEqual("map[{1 2}:a {2 0}:b]", fmt.Sprint(map[Point]string{{2, 0}: "b", {1, 2}: "a"}))
+	assertEqual("[1 {1 2} <nil>]", fmt.Sprint([]interface{}{1, Point{1, 2}, nil}))
+	assertEqual("{3}", fmt.Sprint(LookupError{"3"}))
+	p := &Point{}
+	q := p
+	assertEqual(fmt.Sprintf("%p", p), fmt.Sprintf("%p", q))
+	assertEqual(false, fmt.Sprintf("%p", p) == fmt.Sprintf("%p", &Point{}))
+	err := fmt.Errorf("wrapped: %w", ErrLookup)
+	assertEqual("wrapped: missing k", err.Error())
+	assertEqual(true, errors.Is(err, ErrLookup))
+	extraFormat := "%d"
+	assertEqual("1%!(EXTRA main.Point={1 2})", fmt.Sprintf(extraFormat, 1, Point{1, 2}))
+}
+
+func testUntypedArguments() {
+	assertEqual(2.0, math.Sqrt(4))
+	assertEqual(2.5, math.Max(1, 2.5))
+	assertEqual("101", strconv.FormatInt(5, 2))
+	assertEqual("1.5", strconv.FormatFloat(1.5, 'f', 1, 64))
+	assertEqual(2001, time.Unix(1e9, 0).UTC().Year())
+	assertEqual(time.Millisecond, time.Duration(1000) * time.Microsecond)
+	start := time.Now()
+	time.Sleep(100)
+	assertEqual(true, time.Since(start) > 0)
+	assertEqual(2, strings.IndexByte("abc", 'c'))
+	assertEqual(true, strings.ContainsRune("abc", 'b'))
+	r := 'a'
+	assertEqual(true, r == 97)
+	assertEqual("bc", strings.Map(nextRune, "ab"))
+	assertEqual("<nil>", fmt.Sprint(nil))
+}
+
+func nextRune(r rune) rune {
+	return r + 1
+}
+
+type Sizer interface {
+	Len() int
+}
+
+type TaggedBuffer struct {
+	*bytes.Buffer
+	tag string
+}
+
+func testNativeMethodDispatch() {
+	var buf bytes.Buffer
+	write := buf.WriteString
+	write("ab")
+	var sizer Sizer = &buf
+	size := sizer.Len
+	buf.WriteString("c")
+	assertEqual(3, size())
+	assertEqual(3, (*bytes.Buffer).Len(&buf))
+	var sb strings.Builder
+	var w io.Writer = &sb
+	fmt.Fprint(w, "x")
+	io.StringWriter.WriteString(&sb, "y")
+	assertEqual("xy", sb.String())
+	assertEqual(true, w.(*strings.Builder) == &sb)
+	var any interface{} = &buf
+	assertEqual(3, any.(Sizer).Len())
+	// Method expressions on interpreted interfaces dispatch to native
+	// methods too.
+	getSize := Sizer.Len
+	assertEqual(3, getSize(&buf))
+	assertEqual("bad", error.Error(errors.New("bad")))
+	tagged := TaggedBuffer{&bytes.Buffer{}, "t"}
+	tagged.WriteString("hi")
+	assertEqual(2, TaggedBuffer.Len(tagged))
+	assertEqual(1.5, time.Duration.Minutes(90 * time.Second))
+}
+
+func testBuiltins() {
+	made := make([]Point, 2, 5)
+	assertEqual(2, len(made))
+	assertEqual(5, cap(made))
+	assertEqual(0, made[1].X)
+	var nilSlice []int
+	assertEqual(0, cap(nilSlice))
+	counter := new(int)
+	*counter += 2
+	assertEqual(2, *counter)
+	origin := new(Point)
+	origin.Y = 4
+	assertEqual(4, origin.Y)
+
+	// Appending copies structs, like assignment does.
+	p := Point{1, 2}
+	points := append([]Point{}, p)
+	p.X = 9
+	assertEqual(1, points[0].X)
+	more := append([]Point{}, points...)
+	more[0].X = 5
+	assertEqual(1, points[0].X)
+	values := []interface{}{p}
+	p.Y = 7
+	assertEqual(Point{9, 2}, values[0])
+	assertEqual(3, len(append([]int{1}, 2, 3)))
+
+	byName := map[string]Point{"a": {1, 2}}
+	byName["b"] = Point{3, 4}
+	assertEqual(3, byName["b"].X)
+	delete(byName, "a")
+	assertEqual(1, len(byName))
+	delete(byName, "missing")
+	// Missing keys give the zero value of the element type.
+	assertEqual(0, byName["missing"].X)
+	var nilMap map[string]Point
+	assertEqual(0, nilMap["x"].Y)
+	temps := make(map[string]Celsius)
+	temps["warm"] = 20
+	assertEqual(Fahrenheit(68), temps["warm"].ToFahrenheit())
+	assertEqual(Fahrenheit(32), temps["missing"].ToFahrenheit())
+}
+
+type Weekday int
+
+const (
+	Sunday Weekday = iota
+	Monday
+	Tuesday
+)
+
+const (
+	KB = 1 << (10 * (iota + 1))
+	MB
+)
+
+const Boiling Celsius = 100
+
+// The array length refers to a constant that's declared later.
+type Board [BoardSize * BoardSize]bool
+
+const BoardSize = 3
+
+func testConstants() {
+	assertEqual(Weekday(2), Tuesday)
+	assertEqual(1048576, MB)
+	var board Board
+	assertEqual(9, len(board))
+	assertEqual(Fahrenheit(212), Boiling.ToFahrenheit())
+	assertEqual(geom.Direction(3), geom.West)
+	assertEqual(2, geom.Dimensions)
+	const half = BoardSize / 2
+	assertEqual(1, half)
+	assertEqual(1.5, BoardSize / 2.0)
+	assertEqual(7200.0, (2 * time.Hour).Seconds())
+	assertEqual(true, math.Pi / 2 > 1.57)
+}
+
 func main() {
 	start := time.Now()
 	testMath()
@@ -128,6 +1535,33 @@ func main() {
 	testSlices()
 	testStruct()
 	testMethods()
+	testNilReceivers()
+	testNamedTypes()
+	testEmbeddedStructs()
+	testVariadic()
+	testSignatures()
+	testGenerics()
+	testMethodValues()
+	testEquality()
+	testCompositeLiterals()
+	testAssignment()
+	testShadowing()
+	testImports()
+	testTypeIdentity()
+	testImportForms()
+	testStdlib()
+	testNativeGlobals()
+	testNativeMethods()
+	testNativeTypes()
+	testCallbacks()
+	testClosures()
+	testNativeInterfaces()
+	testMaterializedStructs()
+	testFormatting()
+	testUntypedArguments()
+	testNativeMethodDispatch()
+	testBuiltins()
+	testConstants()
 	fmt.Println("Pass!")
 	fmt.Println("Took ", time.Since(start))
 }
\ No newline at end of file
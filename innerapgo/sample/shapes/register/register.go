@@ -0,0 +1,9 @@
+// Package register is blank-imported by the sample, so it's only used for its
+// init function.
+package register
+
+import "github.com/alangpierce/apgo/sample/shapes"
+
+func init() {
+	shapes.Registered = append(shapes.Registered, "register")
+}
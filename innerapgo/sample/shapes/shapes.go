@@ -0,0 +1,24 @@
+// Package shapes is dot-imported by the sample.
+package shapes
+
+type Square struct {
+	Side int
+}
+
+func (s Square) Area() int {
+	return s.Side * s.Side
+}
+
+func NewSquare(side int) Square {
+	return Square{side}
+}
+
+// Names of the packages that registered themselves from their init functions.
+var Registered []string
+
+func Larger[T int | float64](a T, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -1,14 +1,27 @@
 package main
 
 import (
+	"fmt"
 	"github.com/alangpierce/apgo/interpreter"
 	"github.com/alangpierce/apgo/apruntime"
+	"github.com/alangpierce/apgo/stdlib"
+	"os"
 )
 
 func main() {
 	interp := interpreter.NewInterpreter()
 	interp.LoadNativePackage(apruntime.FmtPackage)
-	interp.LoadNativePackage(apruntime.TimePackage)
-	interp.LoadPackage("sample")
-	interp.RunMain()
+	for _, pack := range stdlib.Packages {
+		interp.LoadNativePackage(pack)
+	}
+	if err := interp.LoadPackage("sample"); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	exitCode, err := interp.RunMain()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(exitCode)
 }
\ No newline at end of file
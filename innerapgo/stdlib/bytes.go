@@ -0,0 +1,90 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"bytes"
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+)
+
+var BytesPackage = &apruntime.NativePackage{
+	Path: "bytes",
+	Name: "bytes",
+	Funcs: map[string]interface{}{
+		"Clone":           bytes.Clone,
+		"Compare":         bytes.Compare,
+		"Contains":        bytes.Contains,
+		"ContainsAny":     bytes.ContainsAny,
+		"ContainsFunc":    bytes.ContainsFunc,
+		"ContainsRune":    bytes.ContainsRune,
+		"Count":           bytes.Count,
+		"Cut":             bytes.Cut,
+		"CutLast":         bytes.CutLast,
+		"CutPrefix":       bytes.CutPrefix,
+		"CutSuffix":       bytes.CutSuffix,
+		"Equal":           bytes.Equal,
+		"EqualFold":       bytes.EqualFold,
+		"Fields":          bytes.Fields,
+		"FieldsFunc":      bytes.FieldsFunc,
+		"FieldsFuncSeq":   bytes.FieldsFuncSeq,
+		"FieldsSeq":       bytes.FieldsSeq,
+		"HasPrefix":       bytes.HasPrefix,
+		"HasSuffix":       bytes.HasSuffix,
+		"Index":           bytes.Index,
+		"IndexAny":        bytes.IndexAny,
+		"IndexByte":       bytes.IndexByte,
+		"IndexFunc":       bytes.IndexFunc,
+		"IndexRune":       bytes.IndexRune,
+		"Join":            bytes.Join,
+		"LastIndex":       bytes.LastIndex,
+		"LastIndexAny":    bytes.LastIndexAny,
+		"LastIndexByte":   bytes.LastIndexByte,
+		"LastIndexFunc":   bytes.LastIndexFunc,
+		"Lines":           bytes.Lines,
+		"Map":             bytes.Map,
+		"NewBuffer":       bytes.NewBuffer,
+		"NewBufferString": bytes.NewBufferString,
+		"NewReader":       bytes.NewReader,
+		"Repeat":          bytes.Repeat,
+		"Replace":         bytes.Replace,
+		"ReplaceAll":      bytes.ReplaceAll,
+		"Runes":           bytes.Runes,
+		"Split":           bytes.Split,
+		"SplitAfter":      bytes.SplitAfter,
+		"SplitAfterN":     bytes.SplitAfterN,
+		"SplitAfterSeq":   bytes.SplitAfterSeq,
+		"SplitN":          bytes.SplitN,
+		"SplitSeq":        bytes.SplitSeq,
+		"Title":           bytes.Title,
+		"ToLower":         bytes.ToLower,
+		"ToLowerSpecial":  bytes.ToLowerSpecial,
+		"ToTitle":         bytes.ToTitle,
+		"ToTitleSpecial":  bytes.ToTitleSpecial,
+		"ToUpper":         bytes.ToUpper,
+		"ToUpperSpecial":  bytes.ToUpperSpecial,
+		"ToValidUTF8":     bytes.ToValidUTF8,
+		"Trim":            bytes.Trim,
+		"TrimFunc":        bytes.TrimFunc,
+		"TrimLeft":        bytes.TrimLeft,
+		"TrimLeftFunc":    bytes.TrimLeftFunc,
+		"TrimPrefix":      bytes.TrimPrefix,
+		"TrimRight":       bytes.TrimRight,
+		"TrimRightFunc":   bytes.TrimRightFunc,
+		"TrimSpace":       bytes.TrimSpace,
+		"TrimSuffix":      bytes.TrimSuffix,
+	},
+	Consts: map[string]interface{}{
+		"MinRead": bytes.MinRead,
+	},
+	UntypedConsts: map[string]bool{
+		"MinRead": true,
+	},
+	Vars: map[string]interface{}{
+		"ErrTooLarge": &bytes.ErrTooLarge,
+	},
+	Types: map[string]reflect.Type{
+		"Buffer": reflect.TypeOf((*bytes.Buffer)(nil)).Elem(),
+		"Reader": reflect.TypeOf((*bytes.Reader)(nil)).Elem(),
+	},
+}
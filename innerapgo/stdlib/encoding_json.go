@@ -0,0 +1,60 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"encoding/json"
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+)
+
+var EncodingJsonPackage = &apruntime.NativePackage{
+	Path: "encoding/json",
+	Name: "json",
+	Funcs: map[string]interface{}{
+		"CallMethodsWithLegacySemantics":  json.CallMethodsWithLegacySemantics,
+		"Compact":                         json.Compact,
+		"DefaultOptionsV1":                json.DefaultOptionsV1,
+		"FormatByteArrayAsArray":          json.FormatByteArrayAsArray,
+		"FormatBytesWithLegacySemantics":  json.FormatBytesWithLegacySemantics,
+		"FormatDurationAsNano":            json.FormatDurationAsNano,
+		"HTMLEscape":                      json.HTMLEscape,
+		"Indent":                          json.Indent,
+		"Marshal":                         json.Marshal,
+		"MarshalIndent":                   json.MarshalIndent,
+		"MatchCaseSensitiveDelimiter":     json.MatchCaseSensitiveDelimiter,
+		"MergeWithLegacySemantics":        json.MergeWithLegacySemantics,
+		"NewDecoder":                      json.NewDecoder,
+		"NewEncoder":                      json.NewEncoder,
+		"OmitEmptyWithLegacySemantics":    json.OmitEmptyWithLegacySemantics,
+		"ParseBytesWithLooseRFC4648":      json.ParseBytesWithLooseRFC4648,
+		"ParseTimeWithLooseRFC3339":       json.ParseTimeWithLooseRFC3339,
+		"ReportErrorsWithLegacySemantics": json.ReportErrorsWithLegacySemantics,
+		"StringifyWithLegacySemantics":    json.StringifyWithLegacySemantics,
+		"Unmarshal":                       json.Unmarshal,
+		"UnmarshalArrayFromAnyLength":     json.UnmarshalArrayFromAnyLength,
+		"Valid":                           json.Valid,
+	},
+	Consts:        map[string]interface{}{},
+	UntypedConsts: map[string]bool{},
+	Vars:          map[string]interface{}{},
+	Types: map[string]reflect.Type{
+		"Decoder":               reflect.TypeOf((*json.Decoder)(nil)).Elem(),
+		"Delim":                 reflect.TypeOf((*json.Delim)(nil)).Elem(),
+		"Encoder":               reflect.TypeOf((*json.Encoder)(nil)).Elem(),
+		"InvalidUTF8Error":      reflect.TypeOf((*json.InvalidUTF8Error)(nil)).Elem(),
+		"InvalidUnmarshalError": reflect.TypeOf((*json.InvalidUnmarshalError)(nil)).Elem(),
+		"Marshaler":             reflect.TypeOf((*json.Marshaler)(nil)).Elem(),
+		"MarshalerError":        reflect.TypeOf((*json.MarshalerError)(nil)).Elem(),
+		"Number":                reflect.TypeOf((*json.Number)(nil)).Elem(),
+		"Options":               reflect.TypeOf((*json.Options)(nil)).Elem(),
+		"RawMessage":            reflect.TypeOf((*json.RawMessage)(nil)).Elem(),
+		"SyntaxError":           reflect.TypeOf((*json.SyntaxError)(nil)).Elem(),
+		"Token":                 reflect.TypeOf((*json.Token)(nil)).Elem(),
+		"UnmarshalFieldError":   reflect.TypeOf((*json.UnmarshalFieldError)(nil)).Elem(),
+		"UnmarshalTypeError":    reflect.TypeOf((*json.UnmarshalTypeError)(nil)).Elem(),
+		"Unmarshaler":           reflect.TypeOf((*json.Unmarshaler)(nil)).Elem(),
+		"UnsupportedTypeError":  reflect.TypeOf((*json.UnsupportedTypeError)(nil)).Elem(),
+		"UnsupportedValueError": reflect.TypeOf((*json.UnsupportedValueError)(nil)).Elem(),
+	},
+}
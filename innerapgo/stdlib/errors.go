@@ -0,0 +1,27 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"errors"
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+)
+
+var ErrorsPackage = &apruntime.NativePackage{
+	Path: "errors",
+	Name: "errors",
+	Funcs: map[string]interface{}{
+		"As":     errors.As,
+		"Is":     errors.Is,
+		"Join":   errors.Join,
+		"New":    errors.New,
+		"Unwrap": errors.Unwrap,
+	},
+	Consts:        map[string]interface{}{},
+	UntypedConsts: map[string]bool{},
+	Vars: map[string]interface{}{
+		"ErrUnsupported": &errors.ErrUnsupported,
+	},
+	Types: map[string]reflect.Type{},
+}
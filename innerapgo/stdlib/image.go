@@ -0,0 +1,74 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"image"
+	"reflect"
+)
+
+var ImagePackage = &apruntime.NativePackage{
+	Path: "image",
+	Name: "image",
+	Funcs: map[string]interface{}{
+		"Decode":         image.Decode,
+		"DecodeConfig":   image.DecodeConfig,
+		"NewAlpha":       image.NewAlpha,
+		"NewAlpha16":     image.NewAlpha16,
+		"NewCMYK":        image.NewCMYK,
+		"NewGray":        image.NewGray,
+		"NewGray16":      image.NewGray16,
+		"NewNRGBA":       image.NewNRGBA,
+		"NewNRGBA64":     image.NewNRGBA64,
+		"NewNYCbCrA":     image.NewNYCbCrA,
+		"NewPaletted":    image.NewPaletted,
+		"NewRGBA":        image.NewRGBA,
+		"NewRGBA64":      image.NewRGBA64,
+		"NewUniform":     image.NewUniform,
+		"NewYCbCr":       image.NewYCbCr,
+		"Pt":             image.Pt,
+		"Rect":           image.Rect,
+		"RegisterFormat": image.RegisterFormat,
+	},
+	Consts: map[string]interface{}{
+		"YCbCrSubsampleRatio410": image.YCbCrSubsampleRatio410,
+		"YCbCrSubsampleRatio411": image.YCbCrSubsampleRatio411,
+		"YCbCrSubsampleRatio420": image.YCbCrSubsampleRatio420,
+		"YCbCrSubsampleRatio422": image.YCbCrSubsampleRatio422,
+		"YCbCrSubsampleRatio440": image.YCbCrSubsampleRatio440,
+		"YCbCrSubsampleRatio444": image.YCbCrSubsampleRatio444,
+	},
+	UntypedConsts: map[string]bool{},
+	Vars: map[string]interface{}{
+		"Black":       &image.Black,
+		"ErrFormat":   &image.ErrFormat,
+		"Opaque":      &image.Opaque,
+		"Transparent": &image.Transparent,
+		"White":       &image.White,
+		"ZP":          &image.ZP,
+		"ZR":          &image.ZR,
+	},
+	Types: map[string]reflect.Type{
+		"Alpha":               reflect.TypeOf((*image.Alpha)(nil)).Elem(),
+		"Alpha16":             reflect.TypeOf((*image.Alpha16)(nil)).Elem(),
+		"CMYK":                reflect.TypeOf((*image.CMYK)(nil)).Elem(),
+		"Config":              reflect.TypeOf((*image.Config)(nil)).Elem(),
+		"Gray":                reflect.TypeOf((*image.Gray)(nil)).Elem(),
+		"Gray16":              reflect.TypeOf((*image.Gray16)(nil)).Elem(),
+		"Image":               reflect.TypeOf((*image.Image)(nil)).Elem(),
+		"NRGBA":               reflect.TypeOf((*image.NRGBA)(nil)).Elem(),
+		"NRGBA64":             reflect.TypeOf((*image.NRGBA64)(nil)).Elem(),
+		"NYCbCrA":             reflect.TypeOf((*image.NYCbCrA)(nil)).Elem(),
+		"Paletted":            reflect.TypeOf((*image.Paletted)(nil)).Elem(),
+		"PalettedImage":       reflect.TypeOf((*image.PalettedImage)(nil)).Elem(),
+		"Point":               reflect.TypeOf((*image.Point)(nil)).Elem(),
+		"RGBA":                reflect.TypeOf((*image.RGBA)(nil)).Elem(),
+		"RGBA64":              reflect.TypeOf((*image.RGBA64)(nil)).Elem(),
+		"RGBA64Image":         reflect.TypeOf((*image.RGBA64Image)(nil)).Elem(),
+		"Rectangle":           reflect.TypeOf((*image.Rectangle)(nil)).Elem(),
+		"Uniform":             reflect.TypeOf((*image.Uniform)(nil)).Elem(),
+		"YCbCr":               reflect.TypeOf((*image.YCbCr)(nil)).Elem(),
+		"YCbCrSubsampleRatio": reflect.TypeOf((*image.YCbCrSubsampleRatio)(nil)).Elem(),
+	},
+}
@@ -0,0 +1,79 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"io"
+	"reflect"
+)
+
+var IoPackage = &apruntime.NativePackage{
+	Path: "io",
+	Name: "io",
+	Funcs: map[string]interface{}{
+		"Copy":             io.Copy,
+		"CopyBuffer":       io.CopyBuffer,
+		"CopyN":            io.CopyN,
+		"LimitReader":      io.LimitReader,
+		"MultiReader":      io.MultiReader,
+		"MultiWriter":      io.MultiWriter,
+		"NewOffsetWriter":  io.NewOffsetWriter,
+		"NewSectionReader": io.NewSectionReader,
+		"NopCloser":        io.NopCloser,
+		"Pipe":             io.Pipe,
+		"ReadAll":          io.ReadAll,
+		"ReadAtLeast":      io.ReadAtLeast,
+		"ReadFull":         io.ReadFull,
+		"TeeReader":        io.TeeReader,
+		"WriteString":      io.WriteString,
+	},
+	Consts: map[string]interface{}{
+		"SeekCurrent": io.SeekCurrent,
+		"SeekEnd":     io.SeekEnd,
+		"SeekStart":   io.SeekStart,
+	},
+	UntypedConsts: map[string]bool{
+		"SeekCurrent": true,
+		"SeekEnd":     true,
+		"SeekStart":   true,
+	},
+	Vars: map[string]interface{}{
+		"Discard":          &io.Discard,
+		"EOF":              &io.EOF,
+		"ErrClosedPipe":    &io.ErrClosedPipe,
+		"ErrNoProgress":    &io.ErrNoProgress,
+		"ErrShortBuffer":   &io.ErrShortBuffer,
+		"ErrShortWrite":    &io.ErrShortWrite,
+		"ErrUnexpectedEOF": &io.ErrUnexpectedEOF,
+	},
+	Types: map[string]reflect.Type{
+		"ByteReader":      reflect.TypeOf((*io.ByteReader)(nil)).Elem(),
+		"ByteScanner":     reflect.TypeOf((*io.ByteScanner)(nil)).Elem(),
+		"ByteWriter":      reflect.TypeOf((*io.ByteWriter)(nil)).Elem(),
+		"Closer":          reflect.TypeOf((*io.Closer)(nil)).Elem(),
+		"LimitedReader":   reflect.TypeOf((*io.LimitedReader)(nil)).Elem(),
+		"OffsetWriter":    reflect.TypeOf((*io.OffsetWriter)(nil)).Elem(),
+		"PipeReader":      reflect.TypeOf((*io.PipeReader)(nil)).Elem(),
+		"PipeWriter":      reflect.TypeOf((*io.PipeWriter)(nil)).Elem(),
+		"ReadCloser":      reflect.TypeOf((*io.ReadCloser)(nil)).Elem(),
+		"ReadSeekCloser":  reflect.TypeOf((*io.ReadSeekCloser)(nil)).Elem(),
+		"ReadSeeker":      reflect.TypeOf((*io.ReadSeeker)(nil)).Elem(),
+		"ReadWriteCloser": reflect.TypeOf((*io.ReadWriteCloser)(nil)).Elem(),
+		"ReadWriteSeeker": reflect.TypeOf((*io.ReadWriteSeeker)(nil)).Elem(),
+		"ReadWriter":      reflect.TypeOf((*io.ReadWriter)(nil)).Elem(),
+		"Reader":          reflect.TypeOf((*io.Reader)(nil)).Elem(),
+		"ReaderAt":        reflect.TypeOf((*io.ReaderAt)(nil)).Elem(),
+		"ReaderFrom":      reflect.TypeOf((*io.ReaderFrom)(nil)).Elem(),
+		"RuneReader":      reflect.TypeOf((*io.RuneReader)(nil)).Elem(),
+		"RuneScanner":     reflect.TypeOf((*io.RuneScanner)(nil)).Elem(),
+		"SectionReader":   reflect.TypeOf((*io.SectionReader)(nil)).Elem(),
+		"Seeker":          reflect.TypeOf((*io.Seeker)(nil)).Elem(),
+		"StringWriter":    reflect.TypeOf((*io.StringWriter)(nil)).Elem(),
+		"WriteCloser":     reflect.TypeOf((*io.WriteCloser)(nil)).Elem(),
+		"WriteSeeker":     reflect.TypeOf((*io.WriteSeeker)(nil)).Elem(),
+		"Writer":          reflect.TypeOf((*io.Writer)(nil)).Elem(),
+		"WriterAt":        reflect.TypeOf((*io.WriterAt)(nil)).Elem(),
+		"WriterTo":        reflect.TypeOf((*io.WriterTo)(nil)).Elem(),
+	},
+}
@@ -0,0 +1,149 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"math"
+	"reflect"
+)
+
+var MathPackage = &apruntime.NativePackage{
+	Path: "math",
+	Name: "math",
+	Funcs: map[string]interface{}{
+		"Abs":             math.Abs,
+		"Acos":            math.Acos,
+		"Acosh":           math.Acosh,
+		"Asin":            math.Asin,
+		"Asinh":           math.Asinh,
+		"Atan":            math.Atan,
+		"Atan2":           math.Atan2,
+		"Atanh":           math.Atanh,
+		"Cbrt":            math.Cbrt,
+		"Ceil":            math.Ceil,
+		"Copysign":        math.Copysign,
+		"Cos":             math.Cos,
+		"Cosh":            math.Cosh,
+		"Dim":             math.Dim,
+		"Erf":             math.Erf,
+		"Erfc":            math.Erfc,
+		"Erfcinv":         math.Erfcinv,
+		"Erfinv":          math.Erfinv,
+		"Exp":             math.Exp,
+		"Exp2":            math.Exp2,
+		"Expm1":           math.Expm1,
+		"FMA":             math.FMA,
+		"Float32bits":     math.Float32bits,
+		"Float32frombits": math.Float32frombits,
+		"Float64bits":     math.Float64bits,
+		"Float64frombits": math.Float64frombits,
+		"Floor":           math.Floor,
+		"Frexp":           math.Frexp,
+		"Gamma":           math.Gamma,
+		"Hypot":           math.Hypot,
+		"Ilogb":           math.Ilogb,
+		"Inf":             math.Inf,
+		"IsInf":           math.IsInf,
+		"IsNaN":           math.IsNaN,
+		"J0":              math.J0,
+		"J1":              math.J1,
+		"Jn":              math.Jn,
+		"Ldexp":           math.Ldexp,
+		"Lgamma":          math.Lgamma,
+		"Log":             math.Log,
+		"Log10":           math.Log10,
+		"Log1p":           math.Log1p,
+		"Log2":            math.Log2,
+		"Logb":            math.Logb,
+		"Max":             math.Max,
+		"Min":             math.Min,
+		"Mod":             math.Mod,
+		"Modf":            math.Modf,
+		"NaN":             math.NaN,
+		"Nextafter":       math.Nextafter,
+		"Nextafter32":     math.Nextafter32,
+		"Pow":             math.Pow,
+		"Pow10":           math.Pow10,
+		"Remainder":       math.Remainder,
+		"Round":           math.Round,
+		"RoundToEven":     math.RoundToEven,
+		"Signbit":         math.Signbit,
+		"Sin":             math.Sin,
+		"Sincos":          math.Sincos,
+		"Sinh":            math.Sinh,
+		"Sqrt":            math.Sqrt,
+		"Tan":             math.Tan,
+		"Tanh":            math.Tanh,
+		"Trunc":           math.Trunc,
+		"Y0":              math.Y0,
+		"Y1":              math.Y1,
+		"Yn":              math.Yn,
+	},
+	Consts: map[string]interface{}{
+		"E":                      math.E,
+		"Ln10":                   math.Ln10,
+		"Ln2":                    math.Ln2,
+		"Log10E":                 math.Log10E,
+		"Log2E":                  math.Log2E,
+		"MaxFloat32":             math.MaxFloat32,
+		"MaxFloat64":             math.MaxFloat64,
+		"MaxInt":                 math.MaxInt,
+		"MaxInt16":               math.MaxInt16,
+		"MaxInt32":               math.MaxInt32,
+		"MaxInt64":               math.MaxInt64,
+		"MaxInt8":                math.MaxInt8,
+		"MaxUint":                uint64(math.MaxUint),
+		"MaxUint16":              math.MaxUint16,
+		"MaxUint32":              math.MaxUint32,
+		"MaxUint64":              uint64(math.MaxUint64),
+		"MaxUint8":               math.MaxUint8,
+		"MinInt":                 math.MinInt,
+		"MinInt16":               math.MinInt16,
+		"MinInt32":               math.MinInt32,
+		"MinInt64":               math.MinInt64,
+		"MinInt8":                math.MinInt8,
+		"Phi":                    math.Phi,
+		"Pi":                     math.Pi,
+		"SmallestNonzeroFloat32": math.SmallestNonzeroFloat32,
+		"SmallestNonzeroFloat64": math.SmallestNonzeroFloat64,
+		"Sqrt2":                  math.Sqrt2,
+		"SqrtE":                  math.SqrtE,
+		"SqrtPhi":                math.SqrtPhi,
+		"SqrtPi":                 math.SqrtPi,
+	},
+	UntypedConsts: map[string]bool{
+		"E":                      true,
+		"Ln10":                   true,
+		"Ln2":                    true,
+		"Log10E":                 true,
+		"Log2E":                  true,
+		"MaxFloat32":             true,
+		"MaxFloat64":             true,
+		"MaxInt":                 true,
+		"MaxInt16":               true,
+		"MaxInt32":               true,
+		"MaxInt64":               true,
+		"MaxInt8":                true,
+		"MaxUint":                true,
+		"MaxUint16":              true,
+		"MaxUint32":              true,
+		"MaxUint64":              true,
+		"MaxUint8":               true,
+		"MinInt":                 true,
+		"MinInt16":               true,
+		"MinInt32":               true,
+		"MinInt64":               true,
+		"MinInt8":                true,
+		"Phi":                    true,
+		"Pi":                     true,
+		"SmallestNonzeroFloat32": true,
+		"SmallestNonzeroFloat64": true,
+		"Sqrt2":                  true,
+		"SqrtE":                  true,
+		"SqrtPhi":                true,
+		"SqrtPi":                 true,
+	},
+	Vars:  map[string]interface{}{},
+	Types: map[string]reflect.Type{},
+}
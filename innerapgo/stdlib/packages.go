@@ -0,0 +1,22 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import "github.com/alangpierce/apgo/apruntime"
+
+// All packages with generated bindings.
+var Packages = []*apruntime.NativePackage{
+	BytesPackage,
+	EncodingJsonPackage,
+	ErrorsPackage,
+	ImagePackage,
+	IoPackage,
+	MathPackage,
+	OsPackage,
+	SortPackage,
+	StrconvPackage,
+	StringsPackage,
+	TimePackage,
+	UnicodePackage,
+	UnicodeUtf8Package,
+}
@@ -0,0 +1,43 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+	"sort"
+)
+
+var SortPackage = &apruntime.NativePackage{
+	Path: "sort",
+	Name: "sort",
+	Funcs: map[string]interface{}{
+		"Find":              sort.Find,
+		"Float64s":          sort.Float64s,
+		"Float64sAreSorted": sort.Float64sAreSorted,
+		"Ints":              sort.Ints,
+		"IntsAreSorted":     sort.IntsAreSorted,
+		"IsSorted":          sort.IsSorted,
+		"Reverse":           sort.Reverse,
+		"Search":            sort.Search,
+		"SearchFloat64s":    sort.SearchFloat64s,
+		"SearchInts":        sort.SearchInts,
+		"SearchStrings":     sort.SearchStrings,
+		"Slice":             sort.Slice,
+		"SliceIsSorted":     sort.SliceIsSorted,
+		"SliceStable":       sort.SliceStable,
+		"Sort":              sort.Sort,
+		"Stable":            sort.Stable,
+		"Strings":           sort.Strings,
+		"StringsAreSorted":  sort.StringsAreSorted,
+	},
+	Consts:        map[string]interface{}{},
+	UntypedConsts: map[string]bool{},
+	Vars:          map[string]interface{}{},
+	Types: map[string]reflect.Type{
+		"Float64Slice": reflect.TypeOf((*sort.Float64Slice)(nil)).Elem(),
+		"IntSlice":     reflect.TypeOf((*sort.IntSlice)(nil)).Elem(),
+		"Interface":    reflect.TypeOf((*sort.Interface)(nil)).Elem(),
+		"StringSlice":  reflect.TypeOf((*sort.StringSlice)(nil)).Elem(),
+	},
+}
@@ -0,0 +1,14 @@
+// Package stdlib has generated bindings for standard library packages, which
+// can be registered with Interpreter.LoadNativePackage. To add a package, add
+// its import path below and run go generate.
+package stdlib
+
+import "github.com/alangpierce/apgo/apruntime"
+
+//go:generate go run ../nativegen -dir . -package stdlib bytes encoding/json errors image io math os sort strconv strings time unicode unicode/utf8
+
+func init() {
+	// os.Exit would end the interpreter's own process, so it's replaced with
+	// one that only ends the interpreted program.
+	OsPackage.Funcs["Exit"] = apruntime.OsPackage.Funcs["Exit"]
+}
@@ -0,0 +1,63 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+	"strconv"
+)
+
+var StrconvPackage = &apruntime.NativePackage{
+	Path: "strconv",
+	Name: "strconv",
+	Funcs: map[string]interface{}{
+		"AppendBool":               strconv.AppendBool,
+		"AppendFloat":              strconv.AppendFloat,
+		"AppendInt":                strconv.AppendInt,
+		"AppendQuote":              strconv.AppendQuote,
+		"AppendQuoteRune":          strconv.AppendQuoteRune,
+		"AppendQuoteRuneToASCII":   strconv.AppendQuoteRuneToASCII,
+		"AppendQuoteRuneToGraphic": strconv.AppendQuoteRuneToGraphic,
+		"AppendQuoteToASCII":       strconv.AppendQuoteToASCII,
+		"AppendQuoteToGraphic":     strconv.AppendQuoteToGraphic,
+		"AppendUint":               strconv.AppendUint,
+		"Atoi":                     strconv.Atoi,
+		"CanBackquote":             strconv.CanBackquote,
+		"FormatBool":               strconv.FormatBool,
+		"FormatComplex":            strconv.FormatComplex,
+		"FormatFloat":              strconv.FormatFloat,
+		"FormatInt":                strconv.FormatInt,
+		"FormatUint":               strconv.FormatUint,
+		"IsGraphic":                strconv.IsGraphic,
+		"IsPrint":                  strconv.IsPrint,
+		"Itoa":                     strconv.Itoa,
+		"ParseBool":                strconv.ParseBool,
+		"ParseComplex":             strconv.ParseComplex,
+		"ParseFloat":               strconv.ParseFloat,
+		"ParseInt":                 strconv.ParseInt,
+		"ParseUint":                strconv.ParseUint,
+		"Quote":                    strconv.Quote,
+		"QuoteRune":                strconv.QuoteRune,
+		"QuoteRuneToASCII":         strconv.QuoteRuneToASCII,
+		"QuoteRuneToGraphic":       strconv.QuoteRuneToGraphic,
+		"QuoteToASCII":             strconv.QuoteToASCII,
+		"QuoteToGraphic":           strconv.QuoteToGraphic,
+		"QuotedPrefix":             strconv.QuotedPrefix,
+		"Unquote":                  strconv.Unquote,
+		"UnquoteChar":              strconv.UnquoteChar,
+	},
+	Consts: map[string]interface{}{
+		"IntSize": strconv.IntSize,
+	},
+	UntypedConsts: map[string]bool{
+		"IntSize": true,
+	},
+	Vars: map[string]interface{}{
+		"ErrRange":  &strconv.ErrRange,
+		"ErrSyntax": &strconv.ErrSyntax,
+	},
+	Types: map[string]reflect.Type{
+		"NumError": reflect.TypeOf((*strconv.NumError)(nil)).Elem(),
+	},
+}
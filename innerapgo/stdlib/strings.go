@@ -0,0 +1,82 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+	"strings"
+)
+
+var StringsPackage = &apruntime.NativePackage{
+	Path: "strings",
+	Name: "strings",
+	Funcs: map[string]interface{}{
+		"Clone":          strings.Clone,
+		"Compare":        strings.Compare,
+		"Contains":       strings.Contains,
+		"ContainsAny":    strings.ContainsAny,
+		"ContainsFunc":   strings.ContainsFunc,
+		"ContainsRune":   strings.ContainsRune,
+		"Count":          strings.Count,
+		"Cut":            strings.Cut,
+		"CutLast":        strings.CutLast,
+		"CutPrefix":      strings.CutPrefix,
+		"CutSuffix":      strings.CutSuffix,
+		"EqualFold":      strings.EqualFold,
+		"Fields":         strings.Fields,
+		"FieldsFunc":     strings.FieldsFunc,
+		"FieldsFuncSeq":  strings.FieldsFuncSeq,
+		"FieldsSeq":      strings.FieldsSeq,
+		"HasPrefix":      strings.HasPrefix,
+		"HasSuffix":      strings.HasSuffix,
+		"Index":          strings.Index,
+		"IndexAny":       strings.IndexAny,
+		"IndexByte":      strings.IndexByte,
+		"IndexFunc":      strings.IndexFunc,
+		"IndexRune":      strings.IndexRune,
+		"Join":           strings.Join,
+		"LastIndex":      strings.LastIndex,
+		"LastIndexAny":   strings.LastIndexAny,
+		"LastIndexByte":  strings.LastIndexByte,
+		"LastIndexFunc":  strings.LastIndexFunc,
+		"Lines":          strings.Lines,
+		"Map":            strings.Map,
+		"NewReader":      strings.NewReader,
+		"NewReplacer":    strings.NewReplacer,
+		"Repeat":         strings.Repeat,
+		"Replace":        strings.Replace,
+		"ReplaceAll":     strings.ReplaceAll,
+		"Split":          strings.Split,
+		"SplitAfter":     strings.SplitAfter,
+		"SplitAfterN":    strings.SplitAfterN,
+		"SplitAfterSeq":  strings.SplitAfterSeq,
+		"SplitN":         strings.SplitN,
+		"SplitSeq":       strings.SplitSeq,
+		"Title":          strings.Title,
+		"ToLower":        strings.ToLower,
+		"ToLowerSpecial": strings.ToLowerSpecial,
+		"ToTitle":        strings.ToTitle,
+		"ToTitleSpecial": strings.ToTitleSpecial,
+		"ToUpper":        strings.ToUpper,
+		"ToUpperSpecial": strings.ToUpperSpecial,
+		"ToValidUTF8":    strings.ToValidUTF8,
+		"Trim":           strings.Trim,
+		"TrimFunc":       strings.TrimFunc,
+		"TrimLeft":       strings.TrimLeft,
+		"TrimLeftFunc":   strings.TrimLeftFunc,
+		"TrimPrefix":     strings.TrimPrefix,
+		"TrimRight":      strings.TrimRight,
+		"TrimRightFunc":  strings.TrimRightFunc,
+		"TrimSpace":      strings.TrimSpace,
+		"TrimSuffix":     strings.TrimSuffix,
+	},
+	Consts:        map[string]interface{}{},
+	UntypedConsts: map[string]bool{},
+	Vars:          map[string]interface{}{},
+	Types: map[string]reflect.Type{
+		"Builder":  reflect.TypeOf((*strings.Builder)(nil)).Elem(),
+		"Reader":   reflect.TypeOf((*strings.Reader)(nil)).Elem(),
+		"Replacer": reflect.TypeOf((*strings.Replacer)(nil)).Elem(),
+	},
+}
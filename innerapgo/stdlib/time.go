@@ -0,0 +1,116 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+	"time"
+)
+
+var TimePackage = &apruntime.NativePackage{
+	Path: "time",
+	Name: "time",
+	Funcs: map[string]interface{}{
+		"After":                  time.After,
+		"AfterFunc":              time.AfterFunc,
+		"Date":                   time.Date,
+		"FixedZone":              time.FixedZone,
+		"LoadLocation":           time.LoadLocation,
+		"LoadLocationFromTZData": time.LoadLocationFromTZData,
+		"NewTicker":              time.NewTicker,
+		"NewTimer":               time.NewTimer,
+		"Now":                    time.Now,
+		"Parse":                  time.Parse,
+		"ParseDuration":          time.ParseDuration,
+		"ParseInLocation":        time.ParseInLocation,
+		"Since":                  time.Since,
+		"Sleep":                  time.Sleep,
+		"Tick":                   time.Tick,
+		"Unix":                   time.Unix,
+		"UnixMicro":              time.UnixMicro,
+		"UnixMilli":              time.UnixMilli,
+		"Until":                  time.Until,
+	},
+	Consts: map[string]interface{}{
+		"ANSIC":       time.ANSIC,
+		"April":       time.April,
+		"August":      time.August,
+		"DateOnly":    time.DateOnly,
+		"DateTime":    time.DateTime,
+		"December":    time.December,
+		"February":    time.February,
+		"Friday":      time.Friday,
+		"Hour":        time.Hour,
+		"January":     time.January,
+		"July":        time.July,
+		"June":        time.June,
+		"Kitchen":     time.Kitchen,
+		"Layout":      time.Layout,
+		"March":       time.March,
+		"May":         time.May,
+		"Microsecond": time.Microsecond,
+		"Millisecond": time.Millisecond,
+		"Minute":      time.Minute,
+		"Monday":      time.Monday,
+		"Nanosecond":  time.Nanosecond,
+		"November":    time.November,
+		"October":     time.October,
+		"RFC1123":     time.RFC1123,
+		"RFC1123Z":    time.RFC1123Z,
+		"RFC3339":     time.RFC3339,
+		"RFC3339Nano": time.RFC3339Nano,
+		"RFC822":      time.RFC822,
+		"RFC822Z":     time.RFC822Z,
+		"RFC850":      time.RFC850,
+		"RubyDate":    time.RubyDate,
+		"Saturday":    time.Saturday,
+		"Second":      time.Second,
+		"September":   time.September,
+		"Stamp":       time.Stamp,
+		"StampMicro":  time.StampMicro,
+		"StampMilli":  time.StampMilli,
+		"StampNano":   time.StampNano,
+		"Sunday":      time.Sunday,
+		"Thursday":    time.Thursday,
+		"TimeOnly":    time.TimeOnly,
+		"Tuesday":     time.Tuesday,
+		"UnixDate":    time.UnixDate,
+		"Wednesday":   time.Wednesday,
+	},
+	UntypedConsts: map[string]bool{
+		"ANSIC":       true,
+		"DateOnly":    true,
+		"DateTime":    true,
+		"Kitchen":     true,
+		"Layout":      true,
+		"RFC1123":     true,
+		"RFC1123Z":    true,
+		"RFC3339":     true,
+		"RFC3339Nano": true,
+		"RFC822":      true,
+		"RFC822Z":     true,
+		"RFC850":      true,
+		"RubyDate":    true,
+		"Stamp":       true,
+		"StampMicro":  true,
+		"StampMilli":  true,
+		"StampNano":   true,
+		"TimeOnly":    true,
+		"UnixDate":    true,
+	},
+	Vars: map[string]interface{}{
+		"Local": &time.Local,
+		"UTC":   &time.UTC,
+	},
+	Types: map[string]reflect.Type{
+		"Duration":   reflect.TypeOf((*time.Duration)(nil)).Elem(),
+		"Location":   reflect.TypeOf((*time.Location)(nil)).Elem(),
+		"Month":      reflect.TypeOf((*time.Month)(nil)).Elem(),
+		"ParseError": reflect.TypeOf((*time.ParseError)(nil)).Elem(),
+		"Ticker":     reflect.TypeOf((*time.Ticker)(nil)).Elem(),
+		"Time":       reflect.TypeOf((*time.Time)(nil)).Elem(),
+		"Timer":      reflect.TypeOf((*time.Timer)(nil)).Elem(),
+		"Weekday":    reflect.TypeOf((*time.Weekday)(nil)).Elem(),
+	},
+}
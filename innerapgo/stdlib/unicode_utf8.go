@@ -0,0 +1,45 @@
+// Code generated by nativegen; DO NOT EDIT.
+
+package stdlib
+
+import (
+	"github.com/alangpierce/apgo/apruntime"
+	"reflect"
+	"unicode/utf8"
+)
+
+var UnicodeUtf8Package = &apruntime.NativePackage{
+	Path: "unicode/utf8",
+	Name: "utf8",
+	Funcs: map[string]interface{}{
+		"AppendRune":             utf8.AppendRune,
+		"DecodeLastRune":         utf8.DecodeLastRune,
+		"DecodeLastRuneInString": utf8.DecodeLastRuneInString,
+		"DecodeRune":             utf8.DecodeRune,
+		"DecodeRuneInString":     utf8.DecodeRuneInString,
+		"EncodeRune":             utf8.EncodeRune,
+		"FullRune":               utf8.FullRune,
+		"FullRuneInString":       utf8.FullRuneInString,
+		"RuneCount":              utf8.RuneCount,
+		"RuneCountInString":      utf8.RuneCountInString,
+		"RuneLen":                utf8.RuneLen,
+		"RuneStart":              utf8.RuneStart,
+		"Valid":                  utf8.Valid,
+		"ValidRune":              utf8.ValidRune,
+		"ValidString":            utf8.ValidString,
+	},
+	Consts: map[string]interface{}{
+		"MaxRune":   utf8.MaxRune,
+		"RuneError": utf8.RuneError,
+		"RuneSelf":  utf8.RuneSelf,
+		"UTFMax":    utf8.UTFMax,
+	},
+	UntypedConsts: map[string]bool{
+		"MaxRune":   true,
+		"RuneError": true,
+		"RuneSelf":  true,
+		"UTFMax":    true,
+	},
+	Vars:  map[string]interface{}{},
+	Types: map[string]reflect.Type{},
+}